@@ -0,0 +1,159 @@
+package backoff
+
+import (
+	"time"
+)
+
+// Backoff computes the delays between successive attempts.
+type Backoff interface {
+	// Next returns the delay before the next attempt and advances the backoff.
+	Next() time.Duration
+
+	// Reset takes the backoff back to its initial state.
+	Reset()
+
+	// Clone creates a backoff with the same configuration in its initial state.
+	Clone() Backoff
+}
+
+// NewConstant creates a backoff whose delays are all the specified delay.
+func NewConstant(delay time.Duration) Backoff {
+	return &constant{delay: delay}
+}
+
+type constant struct {
+	delay time.Duration
+}
+
+func (b *constant) Next() time.Duration {
+	return b.delay
+}
+
+func (b *constant) Reset() {}
+
+func (b *constant) Clone() Backoff {
+	return &constant{delay: b.delay}
+}
+
+// NewLinear creates a backoff whose delays start with the initial one and
+// grow by the specified increment until they saturate at the maximum one.
+func NewLinear(initial, increment, max time.Duration) Backoff {
+	return &linear{initial: initial, increment: increment, max: max, current: initial}
+}
+
+type linear struct {
+	initial   time.Duration
+	increment time.Duration
+	max       time.Duration
+	current   time.Duration
+}
+
+func (b *linear) Next() time.Duration {
+	delay := b.current
+	if b.current = delay + b.increment; b.current > b.max || b.current < delay {
+		b.current = b.max
+	}
+	return delay
+}
+
+func (b *linear) Reset() {
+	b.current = b.initial
+}
+
+func (b *linear) Clone() Backoff {
+	return NewLinear(b.initial, b.increment, b.max)
+}
+
+// NewExponential creates a backoff whose delays start with the initial one
+// and double until they saturate at the maximum one.
+func NewExponential(initial, max time.Duration) Backoff {
+	return &exponential{initial: initial, max: max, current: initial}
+}
+
+type exponential struct {
+	initial time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func (b *exponential) Next() time.Duration {
+	delay := b.current
+	b.current = double(delay, b.max)
+	return delay
+}
+
+func (b *exponential) Reset() {
+	b.current = b.initial
+}
+
+func (b *exponential) Clone() Backoff {
+	return NewExponential(b.initial, b.max)
+}
+
+// NewCyclicExponential creates a backoff whose delays start with the initial
+// one and double until they exceed the cap, after which they start over from
+// the initial one.
+func NewCyclicExponential(initial, cap time.Duration) Backoff {
+	return &cyclicExponential{initial: initial, cap: cap, current: initial}
+}
+
+type cyclicExponential struct {
+	initial time.Duration
+	cap     time.Duration
+	current time.Duration
+}
+
+func (b *cyclicExponential) Next() time.Duration {
+	delay := b.current
+	if b.current += b.current; b.current > b.cap || b.current < delay {
+		b.current = b.initial
+	}
+	return delay
+}
+
+func (b *cyclicExponential) Reset() {
+	b.current = b.initial
+}
+
+func (b *cyclicExponential) Clone() Backoff {
+	return NewCyclicExponential(b.initial, b.cap)
+}
+
+// NewFibonacci creates a backoff whose delays are the initial one multiplied
+// by the Fibonacci numbers (1, 1, 2, 3, 5, 8, etc.) until they saturate at
+// the maximum one; they grow slower than exponential delays do.
+func NewFibonacci(initial, max time.Duration) Backoff {
+	return &fibonacci{initial: initial, max: max, current: initial}
+}
+
+type fibonacci struct {
+	initial  time.Duration
+	max      time.Duration
+	previous time.Duration
+	current  time.Duration
+}
+
+func (b *fibonacci) Next() time.Duration {
+	delay := b.current
+	if b.current = b.previous + delay; b.current > b.max || b.current < delay {
+		b.current = b.max
+	}
+	b.previous = delay
+	return delay
+}
+
+func (b *fibonacci) Reset() {
+	b.previous, b.current = 0, b.initial
+}
+
+func (b *fibonacci) Clone() Backoff {
+	return NewFibonacci(b.initial, b.max)
+}
+
+// double doubles the specified delay, which saturates at the maximum one.
+func double(delay, max time.Duration) time.Duration {
+	if delay > max/2 {
+		return max
+	}
+	return delay + delay
+}
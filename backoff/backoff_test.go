@@ -0,0 +1,48 @@
+package backoff
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestBackoffs(t *testing.T) {
+	cases := []struct {
+		id       string
+		backoff  Backoff
+		expected []time.Duration
+	}{
+		{"constant", NewConstant(3), []time.Duration{3, 3, 3, 3}},
+		{"linear", NewLinear(1, 3, 8), []time.Duration{1, 4, 7, 8, 8}},
+		{"exponential", NewExponential(1, 10), []time.Duration{1, 2, 4, 8, 10, 10}},
+		{"cyclic exponential", NewCyclicExponential(1, 10), []time.Duration{1, 2, 4, 8, 1, 2}},
+		{"fibonacci", NewFibonacci(1, 10), []time.Duration{1, 1, 2, 3, 5, 8, 10, 10}},
+		{"exponential overflow", NewExponential(math.MaxInt64/2+1, math.MaxInt64),
+			[]time.Duration{math.MaxInt64/2 + 1, math.MaxInt64, math.MaxInt64}},
+		{"linear overflow", NewLinear(math.MaxInt64-1, 2, math.MaxInt64),
+			[]time.Duration{math.MaxInt64 - 1, math.MaxInt64}},
+	}
+
+	for _, c := range cases {
+		for _, b := range []Backoff{c.backoff, c.backoff.Clone()} {
+			for i := 0; i < 2; i++ { // to verify that resetting starts over
+				actual := make([]time.Duration, len(c.expected))
+				for j := range actual {
+					actual[j] = b.Next()
+				}
+				assert.For(t, c.id, i).ThatActual(actual).Equals(c.expected).ThenDiffOnFail()
+				b.Reset()
+			}
+		}
+	}
+}
+
+func TestClone_isIndependent(t *testing.T) {
+	b := NewExponential(1, 10)
+	b.Next()
+	clone := b.Clone()
+	assert.For(t, "clone").ThatActual(clone.Next()).Equals(time.Duration(1))
+	assert.For(t, "original").ThatActual(b.Next()).Equals(time.Duration(2))
+}
@@ -0,0 +1,21 @@
+/*
+Package backoff provides strategies to compute the delays between successive
+attempts, like polls of a resource or retries of a failed call.
+
+A Backoff is stateful: each call to Next advances it, and Reset takes it back
+to its initial state (e.g., after a successful attempt). Backoffs are not safe
+for concurrent use; Clone creates an independent backoff with the same
+configuration for each sequence of attempts (e.g., for each request being
+retried). For example,
+
+	b := backoff.NewExponential(time.Second, time.Minute)
+
+creates a backoff whose delays are 1s, 2s, 4s, etc. until they saturate at
+1 minute, whereas
+
+	b := backoff.NewFullJitter(time.Second, time.Minute)
+
+creates one whose delays are random durations up to those ones, which spreads
+out attempts made by many clients at once.
+*/
+package backoff
@@ -0,0 +1,103 @@
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+type jitterKind int
+
+const (
+	fullJitter jitterKind = iota
+	equalJitter
+	decorrelatedJitter
+)
+
+// Jitter represents a backoff that randomizes exponential delays to spread
+// out attempts made by many clients at once (see https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/).
+// By default, it uses the math/rand package's global source, which is safe
+// for concurrent use; WithRandom configures another one.
+type Jitter struct {
+	kind     jitterKind
+	initial  time.Duration
+	max      time.Duration
+	ceiling  time.Duration // of the exponential delay to randomize
+	previous time.Duration // for decorrelated jitter
+	random   func() float64
+}
+
+// NewFullJitter creates a backoff whose delays are random durations between
+// zero and exponential delays that start with the initial one and saturate
+// at the maximum one.
+func NewFullJitter(initial, max time.Duration) *Jitter {
+	return newJitter(fullJitter, initial, max)
+}
+
+// NewEqualJitter creates a backoff whose delays are half of exponential delays
+// that start with the initial one and saturate at the maximum one, plus
+// random durations between zero and the other half; that is, unlike full
+// jitter, delays are at least half the exponential ones.
+func NewEqualJitter(initial, max time.Duration) *Jitter {
+	return newJitter(equalJitter, initial, max)
+}
+
+// NewDecorrelatedJitter creates a backoff whose delays are random durations
+// between the initial one and three times the previous delay, up to
+// the maximum one; each delay depends on the previous one rather than
+// the number of attempts.
+func NewDecorrelatedJitter(initial, max time.Duration) *Jitter {
+	return newJitter(decorrelatedJitter, initial, max)
+}
+
+func newJitter(kind jitterKind, initial, max time.Duration) *Jitter {
+	return &Jitter{kind: kind, initial: initial, max: max, ceiling: initial, previous: initial, random: rand.Float64}
+}
+
+// WithRandom configures the source of random numbers in [0, 1) that
+// the backoff uses (e.g., a seeded *rand.Rand's Float64 method); it has to be
+// safe for concurrent use if the backoff's clones are used concurrently.
+func (b *Jitter) WithRandom(random func() float64) *Jitter {
+	b.random = random
+	return b
+}
+
+// Next returns the delay before the next attempt and advances the backoff.
+func (b *Jitter) Next() time.Duration {
+	switch b.kind {
+	case fullJitter:
+		delay := b.randomBetween(0, b.ceiling)
+		b.ceiling = double(b.ceiling, b.max)
+		return delay
+	case equalJitter:
+		half := b.ceiling / 2
+		delay := half + b.randomBetween(0, b.ceiling-half)
+		b.ceiling = double(b.ceiling, b.max)
+		return delay
+	default:
+		upper := b.previous * 3
+		if upper > b.max || upper < b.previous {
+			upper = b.max
+		}
+		b.previous = b.randomBetween(b.initial, upper)
+		return b.previous
+	}
+}
+
+// Reset takes the backoff back to its initial state.
+func (b *Jitter) Reset() {
+	b.ceiling, b.previous = b.initial, b.initial
+}
+
+// Clone creates a backoff with the same configuration in its initial state.
+func (b *Jitter) Clone() Backoff {
+	return newJitter(b.kind, b.initial, b.max).WithRandom(b.random)
+}
+
+// randomBetween returns a random duration in [lower, upper), or lower if
+// the range is empty.
+func (b *Jitter) randomBetween(lower, upper time.Duration) time.Duration {
+	if upper <= lower {
+		return lower
+	}
+	return lower + time.Duration(b.random()*float64(upper-lower))
+}
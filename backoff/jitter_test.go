@@ -0,0 +1,47 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestJitter(t *testing.T) {
+	cases := []struct {
+		id       string
+		backoff  *Jitter
+		random   float64
+		expected []time.Duration
+	}{
+		{"full, lowest", NewFullJitter(10, 100), 0, []time.Duration{0, 0, 0, 0, 0, 0}},
+		{"full, middle", NewFullJitter(10, 100), 0.5, []time.Duration{5, 10, 20, 40, 50, 50}},
+		{"equal, lowest", NewEqualJitter(10, 100), 0, []time.Duration{5, 10, 20, 40, 50, 50}},
+		{"equal, middle", NewEqualJitter(10, 100), 0.5, []time.Duration{7, 15, 30, 60, 75, 75}},
+		{"decorrelated, lowest", NewDecorrelatedJitter(10, 100), 0, []time.Duration{10, 10, 10}},
+		{"decorrelated, middle", NewDecorrelatedJitter(10, 100), 0.5, []time.Duration{20, 35, 55, 55}},
+	}
+
+	for _, c := range cases {
+		random := c.random
+		c.backoff.WithRandom(func() float64 { return random })
+		for _, b := range []Backoff{c.backoff, c.backoff.Clone()} {
+			for i := 0; i < 2; i++ { // to verify that resetting starts over
+				actual := make([]time.Duration, len(c.expected))
+				for j := range actual {
+					actual[j] = b.Next()
+				}
+				assert.For(t, c.id, i).ThatActual(actual).Equals(c.expected).ThenDiffOnFail()
+				b.Reset()
+			}
+		}
+	}
+}
+
+func TestJitter_defaultRandom(t *testing.T) {
+	b := NewFullJitter(time.Millisecond, time.Second)
+	for i := 0; i < 100; i++ {
+		delay := b.Next()
+		assert.For(t, i).ThatActual(delay >= 0 && delay < time.Second).IsTrue()
+	}
+}
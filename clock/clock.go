@@ -0,0 +1,67 @@
+package clock
+
+import (
+	"time"
+)
+
+// Clock tells time and waits for it to pass.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Sleep pauses the current goroutine for at least the specified duration.
+	Sleep(duration time.Duration)
+
+	// After waits for the specified duration to elapse and then sends
+	// the current time on the returned channel.
+	After(duration time.Duration) <-chan time.Time
+
+	// NewTimer creates a timer that sends the current time on its channel
+	// after at least the specified duration.
+	NewTimer(duration time.Duration) Timer
+}
+
+// Timer represents a single event, like time.Timer does.
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing; it returns false if the timer has
+	// already fired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after the specified duration; it returns
+	// true if the timer had been active.
+	Reset(duration time.Duration) bool
+}
+
+// New creates a clock that tells real time using the time package.
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+func (realClock) After(duration time.Duration) <-chan time.Time {
+	return time.After(duration)
+}
+
+func (realClock) NewTimer(duration time.Duration) Timer {
+	return &realTimer{time.NewTimer(duration)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (timer *realTimer) C() <-chan time.Time {
+	return timer.Timer.C
+}
@@ -0,0 +1,21 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestRealClock(t *testing.T) {
+	clock := New()
+	start := clock.Now()
+	clock.Sleep(time.Millisecond)
+	<-clock.After(time.Millisecond)
+	timer := clock.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.For(t).ThatActual(clock.Now().Sub(start) >= 3*time.Millisecond).IsTrue()
+	assert.For(t).ThatActual(timer.Stop()).IsFalse()
+	assert.For(t).ThatActual(timer.Reset(time.Hour)).IsFalse()
+	assert.For(t).ThatActual(timer.Stop()).IsTrue()
+}
@@ -0,0 +1,16 @@
+/*
+Package clock provides an abstraction of time, so that time-based components
+(like pollers and expirable sets) can be tested deterministically.
+
+Components accept a Clock instead of calling the time package directly; they
+use the real clock (see New) by default, whereas tests use a fake one that's
+advanced manually (see testutil.FakeClock). For example,
+
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	set := sets.NewExpirableSetWithClock(0, time.Minute, fake)
+	set.Add("a")
+	fake.Advance(time.Minute)
+
+expires the element that was added without waiting for a minute to pass.
+*/
+package clock
@@ -2,12 +2,15 @@ package sets
 
 import (
 	"time"
+
+	"github.com/voicera/gooseberry/clock"
 )
 
 type expirableSet struct {
 	initialCapacity int
 	underlying      map[interface{}]time.Time
 	ttl             time.Duration
+	clock           clock.Clock
 }
 
 // NewExpirableSet creates a new set. The initial capacity does not bound
@@ -15,15 +18,22 @@ type expirableSet struct {
 // TTL (time to live) specifies the duration after which an element expires.
 // TODO(Geish): shrink the size on expiry
 func NewExpirableSet(initialCapacity int, ttl time.Duration) Set {
+	return NewExpirableSetWithClock(initialCapacity, ttl, clock.New())
+}
+
+// NewExpirableSetWithClock creates a new set like NewExpirableSet does, using
+// the specified clock to tell whether or not elements have expired.
+func NewExpirableSetWithClock(initialCapacity int, ttl time.Duration, clock clock.Clock) Set {
 	return &expirableSet{
 		initialCapacity: initialCapacity,
 		underlying:      make(map[interface{}]time.Time, initialCapacity),
 		ttl:             ttl,
+		clock:           clock,
 	}
 }
 
 func (s *expirableSet) Add(element interface{}) {
-	s.underlying[element] = time.Now().UTC()
+	s.underlying[element] = s.clock.Now().UTC()
 }
 
 func (s *expirableSet) Clear() {
@@ -32,7 +42,7 @@ func (s *expirableSet) Clear() {
 
 func (s *expirableSet) Contains(element interface{}) bool {
 	timestamp, found := s.underlying[element]
-	return found && time.Now().UTC().Before(timestamp.Add(s.ttl))
+	return found && s.clock.Now().UTC().Before(timestamp.Add(s.ttl))
 }
 
 func (s *expirableSet) Remove(element interface{}) {
@@ -46,7 +56,7 @@ func (s *expirableSet) Size() int {
 func (s *expirableSet) ToSlice() []interface{} {
 	slice := make([]interface{}, 0, s.Size())
 	for element, timestamp := range s.underlying {
-		if time.Now().UTC().Before(timestamp.Add(s.ttl)) {
+		if s.clock.Now().UTC().Before(timestamp.Add(s.ttl)) {
 			slice = append(slice, element)
 		}
 	}
@@ -56,7 +66,7 @@ func (s *expirableSet) ToSlice() []interface{} {
 func (s *expirableSet) ToStringSlice() []string {
 	slice := make([]string, 0, s.Size())
 	for element, timestamp := range s.underlying {
-		if time.Now().UTC().Before(timestamp.Add(s.ttl)) {
+		if s.clock.Now().UTC().Before(timestamp.Add(s.ttl)) {
 			slice = append(slice, element.(string)) // TODO(Geish): check type and call fmt.Sprint if not a string?
 		}
 	}
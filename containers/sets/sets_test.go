@@ -1,10 +1,12 @@
 package sets_test
 
 import (
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/voicera/gooseberry/containers/sets"
+	"github.com/voicera/gooseberry/testutil"
 	"github.com/voicera/tester/assert"
 )
 
@@ -85,3 +87,27 @@ func remove(set sets.Set) {
 	set.Add(1)
 	set.Remove(0)
 }
+
+func TestExpirableSet_expiry(t *testing.T) {
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	for _, set := range []sets.Set{
+		sets.NewExpirableSetWithClock(5, time.Minute, fake),
+		sets.NewThreadSafeExpirableSetWithClock(5, time.Minute, fake),
+	} {
+		set.Add("foo")
+		fake.Advance(30 * time.Second)
+		set.Add("bar")
+		assert.For(t).ThatActual(set.Contains("foo")).IsTrue()
+		elements := set.ToStringSlice()
+		sort.Strings(elements)
+		assert.For(t).ThatActual(elements).Equals([]string{"bar", "foo"}).ThenDiffOnFail()
+
+		fake.Advance(30 * time.Second)
+		assert.For(t).ThatActual(set.Contains("foo")).IsFalse()
+		assert.For(t).ThatActual(set.Contains("bar")).IsTrue()
+		assert.For(t).ThatActual(set.ToSlice()).Equals([]interface{}{"bar"}).ThenDiffOnFail()
+
+		fake.Advance(30 * time.Second)
+		assert.For(t).ThatActual(set.ToSlice()).Equals([]interface{}{}).ThenDiffOnFail()
+	}
+}
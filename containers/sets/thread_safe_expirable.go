@@ -3,6 +3,8 @@ package sets
 import (
 	"sync"
 	"time"
+
+	"github.com/voicera/gooseberry/clock"
 )
 
 type threadSafeExpirableSet struct {
@@ -16,7 +18,14 @@ type threadSafeExpirableSet struct {
 // the number of elements to store.
 // TTL (time to live) specifies the duration after which an element expires.
 func NewThreadSafeExpirableSet(initialCapacity int, ttl time.Duration) Set {
-	return &threadSafeExpirableSet{underlying: NewExpirableSet(initialCapacity, ttl)}
+	return NewThreadSafeExpirableSetWithClock(initialCapacity, ttl, clock.New())
+}
+
+// NewThreadSafeExpirableSetWithClock creates a new thread-safe set like
+// NewThreadSafeExpirableSet does, using the specified clock to tell whether
+// or not elements have expired.
+func NewThreadSafeExpirableSetWithClock(initialCapacity int, ttl time.Duration, clock clock.Clock) Set {
+	return &threadSafeExpirableSet{underlying: NewExpirableSetWithClock(initialCapacity, ttl, clock)}
 }
 
 func (s *threadSafeExpirableSet) Add(element interface{}) {
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/voicera/gooseberry/polling"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+)
+
+// NewUpstreamCheck creates a check that determines whether or not an upstream
+// service is reachable by making a GET request to the specified URL (relative
+// to the client's base URL, if any) using the specified client, so that
+// the check uses the client's transport chain (auth, logging, etc.).
+// The upstream is considered unreachable if the request fails or if it
+// responds with a server error (5xx); other responses mean it's reachable.
+// The request is made with the check's context; for clients that don't support
+// request options (see rest.RequestOptionsDoer), the check stops waiting for
+// the request once the context is done.
+func NewUpstreamCheck(client rest.Client, url string) CheckFunc {
+	return func(ctx context.Context) error {
+		_, err := rest.DoWithOptions(client, http.MethodGet, url, nil, nil, rest.WithRequestContext(ctx))
+		if err == rest.ErrRequestOptionsNotSupported {
+			err = getUntilDone(ctx, client, url)
+		}
+		if httpError, ok := err.(*web.HTTPError); ok && httpError.StatusCode < http.StatusInternalServerError {
+			return nil
+		}
+		return err
+	}
+}
+
+// getUntilDone makes a GET request in the background, and returns its error
+// or the context's error, whichever comes first.
+func getUntilDone(ctx context.Context, client rest.Client, url string) error {
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.Get(url, nil, nil)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewPollerCheck creates a check that determines whether or not the specified
+// poller is alive: it fails if the poller's last successful call to its
+// receiver happened longer than maxAge ago (or if it has not happened within
+// maxAge of creating the check). As pollers relax between empty-handed calls,
+// maxAge should be longer than the poller's backoff cap.
+func NewPollerCheck(poller polling.Poller, maxAge time.Duration) CheckFunc {
+	createdAt := time.Now()
+	return func(context.Context) error {
+		lastSuccessfulReceiveTime := poller.GetLastSuccessfulReceiveTime()
+		if lastSuccessfulReceiveTime.Before(createdAt) {
+			lastSuccessfulReceiveTime = createdAt
+		}
+		if age := time.Since(lastSuccessfulReceiveTime); age > maxAge {
+			return fmt.Errorf("poller %s has not received successfully for %s", poller.GetName(), age)
+		}
+		return nil
+	}
+}
+
+// NewDiskSpaceCheck creates a check that fails if the file system containing
+// the specified path has less than minFreeBytes available.
+func NewDiskSpaceCheck(path string, minFreeBytes uint64) CheckFunc {
+	return func(context.Context) error {
+		freeBytes, err := getAvailableDiskSpace(path)
+		if err != nil {
+			return err
+		}
+		if freeBytes < minFreeBytes {
+			return fmt.Errorf("%s has %d bytes available; expected at least %d", path, freeBytes, minFreeBytes)
+		}
+		return nil
+	}
+}
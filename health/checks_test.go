@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/polling"
+	"github.com/voicera/gooseberry/web/rest"
+	"github.com/voicera/tester/assert"
+)
+
+func TestUpstreamCheck(t *testing.T) {
+	cases := []struct {
+		statusCode int
+		healthy    bool
+	}{
+		{200, true},
+		{404, true},
+		{503, false},
+	}
+
+	for _, c := range cases {
+		statusCode := c.statusCode
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			writer.WriteHeader(statusCode)
+		}))
+		check := NewUpstreamCheck(rest.NewJSONClient(http.DefaultClient).WithBaseURL(server.URL), "ping")
+		err := check(context.Background())
+		assert.For(t, c.statusCode).ThatActual(err == nil).Equals(c.healthy)
+		server.Close()
+	}
+
+	check := NewUpstreamCheck(rest.NewJSONClient(http.DefaultClient), "http://127.0.0.1:0/unreachable")
+	assert.For(t).ThatActual(check(context.Background())).IsNotNil()
+}
+
+func TestUpstreamCheck_hungUpstream(t *testing.T) {
+	released := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-released
+	}))
+	defer server.Close()
+	defer close(released)
+
+	client := rest.NewJSONClient(http.DefaultClient).WithBaseURL(server.URL)
+	for _, c := range []struct {
+		id     string
+		client rest.Client
+	}{
+		{"context", client},
+		{"no request options", &plainClient{client}},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		start := time.Now()
+		err := NewUpstreamCheck(c.client, "ping")(ctx)
+		cancel()
+		assert.For(t, c.id).ThatActual(err).IsNotNil()
+		assert.For(t, c.id).ThatActual(time.Since(start) < time.Second).IsTrue()
+	}
+}
+
+// plainClient hides the request options support of the client it wraps.
+type plainClient struct {
+	rest.Client
+}
+
+func TestPollerCheck(t *testing.T) {
+	poller := &fakePoller{}
+	check := NewPollerCheck(poller, 10*time.Millisecond)
+	assert.For(t).ThatActual(check(context.Background())).IsNil()
+
+	time.Sleep(20 * time.Millisecond)
+	err := check(context.Background())
+	if assert.For(t).ThatActual(err).IsNotNil().Passed() {
+		assert.For(t).ThatActual(err.Error()[:45]).Equals("poller fake has not received successfully for")
+	}
+
+	poller.lastSuccessfulReceiveTime = time.Now()
+	assert.For(t).ThatActual(check(context.Background())).IsNil()
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	assert.For(t).ThatActual(NewDiskSpaceCheck(".", 1)(context.Background())).IsNil()
+	assert.For(t).ThatActual(NewDiskSpaceCheck(".", 1<<62)(context.Background())).IsNotNil()
+	assert.For(t).ThatActual(NewDiskSpaceCheck("/no/such/path", 1)(context.Background())).IsNotNil()
+}
+
+type fakePoller struct {
+	polling.Poller
+	lastSuccessfulReceiveTime time.Time
+}
+
+func (*fakePoller) GetName() string { return "fake" }
+func (poller *fakePoller) GetLastSuccessfulReceiveTime() time.Time {
+	return poller.lastSuccessfulReceiveTime
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !freebsd && !linux
+// +build !darwin,!freebsd,!linux
+
+package health
+
+import (
+	"errors"
+	"runtime"
+)
+
+func getAvailableDiskSpace(path string) (uint64, error) {
+	return 0, errors.New("disk space checks are not supported on " + runtime.GOOS)
+}
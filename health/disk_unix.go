@@ -0,0 +1,16 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package health
+
+import (
+	"syscall"
+)
+
+func getAvailableDiskSpace(path string) (uint64, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
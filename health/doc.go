@@ -0,0 +1,23 @@
+/*
+Package health provides health checks that are aggregated into liveness and
+readiness HTTP endpoints (e.g., for Kubernetes probes).
+
+Components register named checks with a registry; each check has a timeout,
+a criticality, and an optional TTL for caching its results (so that frequent
+probes don't overwhelm what's being checked). For example,
+
+	registry := health.NewRegistry()
+	registry.RegisterReadinessCheck(&health.Check{
+	    Name:     "twilio",
+	    Check:    health.NewUpstreamCheck(twilioClient, "Calls.json"),
+	    Timeout:  time.Second,
+	    Critical: true,
+	    CacheTTL: 10 * time.Second,
+	})
+	http.Handle("/ready", registry.ReadinessHandler())
+
+serves the aggregated status of readiness checks as JSON, responding with
+503 (Service Unavailable) when any critical check fails. Failing non-critical
+checks degrade the status without failing the probe.
+*/
+package health
@@ -0,0 +1,248 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/validate"
+)
+
+const (
+	// StatusUp denotes that a check passed, or that all checks passed.
+	StatusUp Status = "up"
+
+	// StatusDegraded denotes that some non-critical checks failed.
+	StatusDegraded Status = "degraded"
+
+	// StatusDown denotes that a check failed, or that a critical check failed.
+	StatusDown Status = "down"
+
+	defaultTimeout = 5 * time.Second
+)
+
+var (
+	errTimedOut = errors.New("check timed out")
+)
+
+// Status represents the status of a check or of a group of checks.
+type Status string
+
+// CheckFunc checks the health of a component; it returns nil if healthy.
+// Checks should honor the cancellation of the specified context.
+type CheckFunc func(ctx context.Context) error
+
+// Check represents a named health check.
+type Check struct {
+	// Name identifies the check; it must be unique per registry.
+	Name string
+
+	// Check is the function that checks health.
+	Check CheckFunc
+
+	// Timeout is the duration after which the check is considered failed
+	// (5 seconds if not positive).
+	Timeout time.Duration
+
+	// Critical denotes whether or not a failure of the check fails the group
+	// of checks it belongs to, rather than just degrading it.
+	Critical bool
+
+	// CacheTTL is the duration for which a check's result is reused instead
+	// of running the check again; results are not cached if not positive.
+	CacheTTL time.Duration
+}
+
+// Result represents the result of running a check.
+type Result struct {
+	Status    Status    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Report represents the aggregated results of a group of checks.
+type Report struct {
+	Status Status             `json:"status"`
+	Checks map[string]*Result `json:"checks"`
+}
+
+// Registry aggregates liveness and readiness checks; it's safe for
+// concurrent use.
+type Registry struct {
+	mutex     sync.RWMutex
+	liveness  map[string]*registeredCheck
+	readiness map[string]*registeredCheck
+}
+
+type registeredCheck struct {
+	*Check
+	mutex      sync.Mutex
+	lastResult *Result
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{liveness: map[string]*registeredCheck{}, readiness: map[string]*registeredCheck{}}
+}
+
+// RegisterLivenessCheck registers the specified check as a liveness check;
+// liveness checks determine whether or not the process should be restarted.
+func (registry *Registry) RegisterLivenessCheck(check *Check) error {
+	return registry.register(registry.liveness, check)
+}
+
+// RegisterReadinessCheck registers the specified check as a readiness check;
+// readiness checks determine whether or not the process can serve traffic.
+func (registry *Registry) RegisterReadinessCheck(check *Check) error {
+	return registry.register(registry.readiness, check)
+}
+
+// CheckLiveness runs the liveness checks (concurrently) and aggregates their
+// results.
+func (registry *Registry) CheckLiveness(ctx context.Context) *Report {
+	return registry.run(ctx, registry.liveness)
+}
+
+// CheckReadiness runs the readiness checks (concurrently) and aggregates their
+// results.
+func (registry *Registry) CheckReadiness(ctx context.Context) *Report {
+	return registry.run(ctx, registry.readiness)
+}
+
+// LivenessHandler creates a handler that serves the liveness report as JSON.
+func (registry *Registry) LivenessHandler() http.Handler {
+	return newReportHandler(registry.CheckLiveness)
+}
+
+// ReadinessHandler creates a handler that serves the readiness report as JSON.
+func (registry *Registry) ReadinessHandler() http.Handler {
+	return newReportHandler(registry.CheckReadiness)
+}
+
+func (registry *Registry) register(checks map[string]*registeredCheck, check *Check) error {
+	if check == nil || check.Check == nil {
+		return validate.NewValidationError(errors.New("check function is required"), "check")
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, found := checks[check.Name]; found {
+		return validate.NewValidationError(errors.New("check is already registered: "+check.Name), "check")
+	}
+	checks[check.Name] = &registeredCheck{Check: check}
+	return nil
+}
+
+func (registry *Registry) run(ctx context.Context, checks map[string]*registeredCheck) *Report {
+	registry.mutex.RLock()
+	toRun := make([]*registeredCheck, 0, len(checks))
+	for _, check := range checks {
+		toRun = append(toRun, check)
+	}
+	registry.mutex.RUnlock()
+	sort.Slice(toRun, func(i, j int) bool { return toRun[i].Name < toRun[j].Name })
+
+	results := make([]*Result, len(toRun))
+	waitGroup := sync.WaitGroup{}
+	for i, check := range toRun {
+		waitGroup.Add(1)
+		go func(i int, check *registeredCheck) {
+			defer waitGroup.Done()
+			results[i] = check.run(ctx)
+		}(i, check)
+	}
+	waitGroup.Wait()
+
+	report := &Report{Status: StatusUp, Checks: make(map[string]*Result, len(toRun))}
+	for i, check := range toRun {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status == StatusDown {
+			if check.Critical {
+				report.Status = StatusDown
+			} else if report.Status == StatusUp {
+				report.Status = StatusDegraded
+			}
+		}
+	}
+	return report
+}
+
+func (check *registeredCheck) run(ctx context.Context) *Result {
+	check.mutex.Lock()
+	defer check.mutex.Unlock()
+	if check.lastResult != nil && check.CacheTTL > 0 && time.Since(check.lastResult.CheckedAt) < check.CacheTTL {
+		return check.lastResult
+	}
+
+	start := time.Now()
+	err := check.runWithTimeout(ctx)
+	result := &Result{
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	check.logTransition(result)
+	check.lastResult = result
+	return result
+}
+
+func (check *registeredCheck) runWithTimeout(ctx context.Context) error {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	checkErrors := make(chan error, 1)
+	go func() { checkErrors <- check.Check.Check(ctx) }()
+	select {
+	case err := <-checkErrors:
+		return err
+	case <-ctx.Done(): // for checks that don't honor cancellation
+		return errTimedOut
+	}
+}
+
+func (check *registeredCheck) logTransition(result *Result) {
+	previousStatus := StatusUp // checks are presumed healthy until proven otherwise
+	if check.lastResult != nil {
+		previousStatus = check.lastResult.Status
+	}
+	if previousStatus == result.Status {
+		return
+	}
+	if result.Status == StatusDown {
+		gooseberry.Logger.Warn("Health check failed",
+			"check", check.Name, "critical", check.Critical, "err", result.Error)
+	} else {
+		gooseberry.Logger.Info("Health check recovered", "check", check.Name, "critical", check.Critical)
+	}
+}
+
+func newReportHandler(check func(context.Context) *Report) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		report := check(request.Context())
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("Cache-Control", "no-cache")
+		if report.Status == StatusDown {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			writer.WriteHeader(http.StatusOK)
+		}
+		if err := json.NewEncoder(writer).Encode(report); err != nil {
+			gooseberry.Logger.Error("Error writing health report", "err", err)
+		}
+	})
+}
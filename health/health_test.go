@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/tester/assert"
+)
+
+func TestRegistry_register(t *testing.T) {
+	registry := NewRegistry()
+	check := &Check{Name: "check", Check: func(context.Context) error { return nil }}
+	assert.For(t).ThatActual(registry.RegisterLivenessCheck(check)).IsNil()
+	assert.For(t).ThatActual(registry.RegisterReadinessCheck(check)).IsNil()
+	assert.For(t).ThatActual(registry.RegisterLivenessCheck(check)).IsNotNil()
+	assert.For(t).ThatActual(registry.RegisterLivenessCheck(&Check{Name: "nil"})).IsNotNil()
+}
+
+func TestRegistry_aggregation(t *testing.T) {
+	pass := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("failed successfully") }
+	cases := []struct {
+		id       string
+		checks   []*Check
+		expected Status
+	}{
+		{"no checks", nil, StatusUp},
+		{"all pass", []*Check{{Name: "a", Check: pass, Critical: true}, {Name: "b", Check: pass}}, StatusUp},
+		{"non-critical fails", []*Check{{Name: "a", Check: pass, Critical: true}, {Name: "b", Check: fail}}, StatusDegraded},
+		{"critical fails", []*Check{{Name: "a", Check: fail, Critical: true}, {Name: "b", Check: fail}}, StatusDown},
+	}
+
+	for _, c := range cases {
+		registry := NewRegistry()
+		for _, check := range c.checks {
+			assert.For(t, c.id).ThatActual(registry.RegisterReadinessCheck(check)).IsNil()
+		}
+		report := registry.CheckReadiness(context.Background())
+		assert.For(t, c.id).ThatActual(report.Status).Equals(c.expected)
+		assert.For(t, c.id).ThatActual(len(report.Checks)).Equals(len(c.checks))
+	}
+}
+
+func TestRegistry_timeout(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterLivenessCheck(&Check{
+		Name:     "stuck",
+		Check:    func(context.Context) error { select {} },
+		Timeout:  time.Millisecond,
+		Critical: true,
+	})
+	report := registry.CheckLiveness(context.Background())
+	assert.For(t).ThatActual(report.Status).Equals(StatusDown)
+	assert.For(t).ThatActualString(report.Checks["stuck"].Error).Equals("check timed out")
+}
+
+func TestRegistry_caching(t *testing.T) {
+	callCount := 0
+	registry := NewRegistry()
+	registry.RegisterLivenessCheck(&Check{
+		Name:     "cached",
+		Check:    func(context.Context) error { callCount++; return nil },
+		CacheTTL: time.Hour,
+	})
+	for i := 0; i < 3; i++ {
+		registry.CheckLiveness(context.Background())
+	}
+	assert.For(t).ThatActual(callCount).Equals(1)
+}
+
+func TestRegistry_logsTransitions(t *testing.T) {
+	originalLogger := gooseberry.Logger
+	defer func() { gooseberry.Logger = originalLogger }()
+	logger := testutil.NewLogCapturer(false)
+	gooseberry.Logger = logger
+
+	var err error
+	registry := NewRegistry()
+	registry.RegisterReadinessCheck(&Check{Name: "flaky", Check: func(context.Context) error { return err }})
+	for _, err = range []error{nil, errors.New("down"), errors.New("still down"), nil, nil} {
+		registry.CheckReadiness(context.Background())
+	}
+
+	assert.For(t).ThatActual(logger.WarnCaptures).Equals([]*testutil.CapturedLogEntry{
+		{Message: "Health check failed", Arguments: []interface{}{"check", "flaky", "critical", false, "err", "down"}},
+	}).ThenDiffOnFail()
+	assert.For(t).ThatActual(logger.InfoCaptures).Equals([]*testutil.CapturedLogEntry{
+		{Message: "Health check recovered", Arguments: []interface{}{"check", "flaky", "critical", false}},
+	}).ThenDiffOnFail()
+}
+
+func TestHandlers(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterLivenessCheck(&Check{Name: "alive", Check: func(context.Context) error { return nil }})
+	registry.RegisterReadinessCheck(&Check{
+		Name:     "upstream",
+		Check:    func(context.Context) error { return errors.New("unreachable") },
+		Critical: true,
+	})
+
+	cases := []struct {
+		id                 string
+		recorder           *httptest.ResponseRecorder
+		handlerStatusCode  int
+		expectedStatus     Status
+		expectedCheckError string
+		checkName          string
+	}{
+		{"liveness", httptest.NewRecorder(), 200, StatusUp, "", "alive"},
+		{"readiness", httptest.NewRecorder(), 503, StatusDown, "unreachable", "upstream"},
+	}
+
+	for _, c := range cases {
+		handler := registry.LivenessHandler()
+		if c.id == "readiness" {
+			handler = registry.ReadinessHandler()
+		}
+		handler.ServeHTTP(c.recorder, httptest.NewRequest("GET", "/"+c.id, nil))
+		assert.For(t, c.id).ThatActual(c.recorder.Code).Equals(c.handlerStatusCode)
+		report := &Report{}
+		if assert.For(t, c.id).ThatActual(json.Unmarshal(c.recorder.Body.Bytes(), report)).IsNil().Passed() {
+			assert.For(t, c.id).ThatActual(report.Status).Equals(c.expectedStatus)
+			assert.For(t, c.id).ThatActualString(report.Checks[c.checkName].Error).Equals(c.expectedCheckError)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package log
+
+// Level represents a logging level of a LeveledLogger; it's useful when
+// the level to log at is configurable.
+type Level int8
+
+const (
+	// DebugLevel logs using LeveledLogger.Debug.
+	DebugLevel Level = iota
+
+	// InfoLevel logs using LeveledLogger.Info.
+	InfoLevel
+
+	// WarnLevel logs using LeveledLogger.Warn.
+	WarnLevel
+
+	// ErrorLevel logs using LeveledLogger.Error.
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+// Log logs a message using the specified logger at this level.
+// It accepts varargs of alternating key and value parameters.
+func (level Level) Log(logger LeveledLogger, message string, args ...interface{}) {
+	switch {
+	case level <= DebugLevel:
+		logger.Debug(message, args...)
+	case level == InfoLevel:
+		logger.Info(message, args...)
+	case level == WarnLevel:
+		logger.Warn(message, args...)
+	default:
+		logger.Error(message, args...)
+	}
+}
+
+func (level Level) String() string {
+	if name, found := levelNames[level]; found {
+		return name
+	}
+	return "unknown"
+}
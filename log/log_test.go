@@ -31,3 +31,15 @@ func ExampleLeveledLogger_withPrefix() {
 	// [Warn] prefix:message: [answer 42]
 	// [Error] prefix:message: [answer 42]
 }
+
+func ExampleLevel_Log() {
+	logger := &testutil.Logger{InDebugMode: true}
+	for _, level := range []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel} {
+		level.Log(logger, "message", "level", level.String())
+	}
+	// Output:
+	// [Debug] message: [level debug]
+	// [Info] message: [level info]
+	// [Warn] message: [level warn]
+	// [Error] message: [level error]
+}
@@ -0,0 +1,271 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/clock"
+)
+
+var (
+	// ErrMessageNotInFlight is returned when acknowledging a message that
+	// isn't in flight (anymore); e.g., it was acknowledged, or it was
+	// redelivered after its visibility timed out.
+	ErrMessageNotInFlight = errors.New("message is not in flight")
+)
+
+// Message represents a payload that's received with at-least-once semantics:
+// it's redelivered unless it's acknowledged in time.
+type Message interface {
+	// Payload returns the payload of the message.
+	Payload() interface{}
+
+	// DeliveryCount returns the number of times the message has been
+	// delivered, including this time.
+	DeliveryCount() int
+
+	// Ack acknowledges that the message has been processed, so that it's
+	// never redelivered.
+	Ack() error
+
+	// Nack signals that the message has not been processed, so that it's
+	// redelivered after the specified delay.
+	Nack(delay time.Duration) error
+}
+
+// AckReceiver represents receiving messages that must be acknowledged; use
+// NewMessageReceiver to poll one.
+type AckReceiver interface {
+	// ReceiveMessage receives a message from the resource being polled,
+	// returning the received message (if any), a flag to indicate whether or
+	// not a message was found, and an error if encountered.
+	ReceiveMessage(ctx context.Context) (Message, bool, error)
+}
+
+// NewMessageReceiver adapts the specified AckReceiver to a ContextReceiver
+// whose payloads are the received Messages; so, it can be polled by a poller
+// whose consumers settle (i.e., Ack or Nack) the messages.
+func NewMessageReceiver(receiver AckReceiver) Receiver {
+	return &messageReceiver{receiver}
+}
+
+type messageReceiver struct {
+	AckReceiver
+}
+
+func (receiver *messageReceiver) Receive() (interface{}, bool, error) {
+	return receiver.ReceiveContext(context.Background())
+}
+
+func (receiver *messageReceiver) ReceiveContext(ctx context.Context) (interface{}, bool, error) {
+	message, found, err := receiver.ReceiveMessage(ctx)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	return message, true, nil
+}
+
+// DeadLetterSink represents where messages go after they're delivered too
+// many times without being acknowledged.
+type DeadLetterSink interface {
+	// PutDeadLetter puts the specified message in the sink.
+	PutDeadLetter(message Message) error
+}
+
+// InFlightTracker adds at-least-once semantics to a receiver: it's an
+// AckReceiver whose messages wrap the receiver's payloads, and are tracked as
+// in flight until they're acknowledged. Messages that aren't acknowledged
+// within the visibility timeout, and ones that are negatively acknowledged,
+// are redelivered before new payloads are received; messages that have been
+// delivered the maximum number of times are put in the dead-letter sink (or
+// dropped if there's none) instead of being redelivered.
+//
+// The tracker is also a ContextReceiver whose payloads are Messages; so, it
+// can be polled by a poller whose consumers acknowledge the messages. It's
+// configured using its With* methods, which return the modified tracker
+// to allow chaining, before it's used; it's safe for concurrent use.
+type InFlightTracker struct {
+	mutex             sync.Mutex
+	receiver          ContextReceiver
+	visibilityTimeout time.Duration
+	maxDeliveries     int
+	deadLetterSink    DeadLetterSink
+	inFlight          map[uint64]*trackedMessage
+	lastID            uint64
+	clock             clock.Clock
+}
+
+type trackedMessage struct {
+	id         uint64
+	payload    interface{}
+	deliveries int
+	visibleAt  time.Time
+}
+
+// NewInFlightTracker creates a tracker of messages whose payloads are received
+// from the specified receiver and that are redelivered if they're not
+// acknowledged within the specified visibility timeout. By default, messages
+// are redelivered indefinitely.
+func NewInFlightTracker(receiver Receiver, visibilityTimeout time.Duration) *InFlightTracker {
+	return &InFlightTracker{
+		receiver:          toContextReceiver(receiver),
+		visibilityTimeout: visibilityTimeout,
+		inFlight:          map[uint64]*trackedMessage{},
+		clock:             clock.New(),
+	}
+}
+
+// WithMaxDeliveries configures the maximum number of times a message is
+// delivered; a non-positive number means no limit.
+func (tracker *InFlightTracker) WithMaxDeliveries(maxDeliveries int) *InFlightTracker {
+	tracker.maxDeliveries = maxDeliveries
+	return tracker
+}
+
+// WithClock configures the clock that the tracker uses to tell time
+// (e.g., a fake one in tests); by default, it uses the real clock.
+func (tracker *InFlightTracker) WithClock(clock clock.Clock) *InFlightTracker {
+	tracker.clock = clock
+	return tracker
+}
+
+// WithDeadLetterSink configures the sink where messages go after they're
+// delivered the maximum number of times.
+func (tracker *InFlightTracker) WithDeadLetterSink(sink DeadLetterSink) *InFlightTracker {
+	tracker.deadLetterSink = sink
+	return tracker
+}
+
+// InFlight returns the number of messages that are in flight.
+func (tracker *InFlightTracker) InFlight() int {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	return len(tracker.inFlight)
+}
+
+// ReceiveMessage redelivers the in-flight message that's been invisible
+// the longest if any is visible, or else receives a new one.
+func (tracker *InFlightTracker) ReceiveMessage(ctx context.Context) (Message, bool, error) {
+	if message := tracker.redeliver(); message != nil {
+		return message, true, nil
+	}
+
+	payload, found, err := tracker.receiver.ReceiveContext(ctx)
+	if !found || err != nil {
+		return nil, false, err
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.lastID++
+	message := &trackedMessage{id: tracker.lastID, payload: payload}
+	tracker.inFlight[message.id] = message
+	return tracker.deliver(message), true, nil
+}
+
+// Receive receives a Message like ReceiveMessage does, using a background
+// context.
+func (tracker *InFlightTracker) Receive() (interface{}, bool, error) {
+	return tracker.ReceiveContext(context.Background())
+}
+
+// ReceiveContext receives a Message like ReceiveMessage does.
+func (tracker *InFlightTracker) ReceiveContext(ctx context.Context) (interface{}, bool, error) {
+	return (&messageReceiver{tracker}).ReceiveContext(ctx)
+}
+
+// redeliver finds the visible message that's been invisible the longest,
+// dead-lettering ones that have been delivered too many times, and delivers
+// it again; it returns nil if there's none.
+func (tracker *InFlightTracker) redeliver() Message {
+	var deadLetters []Message
+	defer func() {
+		for _, deadLetter := range deadLetters {
+			tracker.putDeadLetter(deadLetter)
+		}
+	}()
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for {
+		var next *trackedMessage
+		now := tracker.clock.Now()
+		for _, message := range tracker.inFlight {
+			if !message.visibleAt.After(now) && (next == nil || message.visibleAt.Before(next.visibleAt) ||
+				(message.visibleAt.Equal(next.visibleAt) && message.id < next.id)) {
+				next = message
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		if tracker.maxDeliveries <= 0 || next.deliveries < tracker.maxDeliveries {
+			return tracker.deliver(next)
+		}
+		delete(tracker.inFlight, next.id)
+		deadLetters = append(deadLetters, &delivery{tracker: tracker, trackedMessage: next, count: next.deliveries})
+	}
+}
+
+// deliver makes the message invisible for the visibility timeout and creates
+// a handle for the delivery; the tracker must be locked.
+func (tracker *InFlightTracker) deliver(message *trackedMessage) Message {
+	message.deliveries++
+	message.visibleAt = tracker.clock.Now().Add(tracker.visibilityTimeout)
+	return &delivery{tracker: tracker, trackedMessage: message, count: message.deliveries}
+}
+
+func (tracker *InFlightTracker) putDeadLetter(message Message) {
+	if tracker.deadLetterSink == nil {
+		gooseberry.Logger.Warn("Dropped message", "deliveryCount", message.DeliveryCount())
+		return
+	}
+	if err := tracker.deadLetterSink.PutDeadLetter(message); err != nil {
+		gooseberry.Logger.Error("Error putting dead letter", "err", err)
+	}
+}
+
+// settle acknowledges the specified delivery, positively (if delay is
+// negative) or negatively.
+func (tracker *InFlightTracker) settle(delivery *delivery, delay time.Duration) error {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	message, found := tracker.inFlight[delivery.id]
+	if !found || message.deliveries != delivery.count {
+		return ErrMessageNotInFlight
+	}
+	if delay < 0 {
+		delete(tracker.inFlight, message.id)
+	} else {
+		message.visibleAt = tracker.clock.Now().Add(delay)
+	}
+	return nil
+}
+
+// delivery represents a delivery of a tracked message.
+type delivery struct {
+	*trackedMessage
+	tracker *InFlightTracker
+	count   int
+}
+
+func (delivery *delivery) Payload() interface{} {
+	return delivery.payload
+}
+
+func (delivery *delivery) DeliveryCount() int {
+	return delivery.count
+}
+
+func (delivery *delivery) Ack() error {
+	return delivery.tracker.settle(delivery, -1)
+}
+
+func (delivery *delivery) Nack(delay time.Duration) error {
+	if delay < 0 {
+		delay = 0
+	}
+	return delivery.tracker.settle(delivery, delay)
+}
@@ -0,0 +1,141 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/tester/assert"
+)
+
+func newTestTracker(payloads ...interface{}) (*InFlightTracker, *InMemoryQueue, *testutil.FakeClock) {
+	queue, clock := NewInMemoryQueue(payloads...), testutil.NewFakeClock(time.Unix(0, 0))
+	return NewInFlightTracker(queue, time.Minute).WithClock(clock), queue, clock
+}
+
+func receiveMessage(t *testing.T, tracker *InFlightTracker, id interface{}) Message {
+	message, found, err := tracker.ReceiveMessage(context.Background())
+	assert.For(t, id).ThatActual(err).IsNil()
+	assert.For(t, id).ThatActual(found).IsTrue()
+	return message
+}
+
+func TestInFlightTracker_ack(t *testing.T) {
+	tracker, _, _ := newTestTracker("a", "b")
+	a, b := receiveMessage(t, tracker, "a"), receiveMessage(t, tracker, "b")
+	assert.For(t).ThatActual(a.Payload()).Equals("a")
+	assert.For(t).ThatActual(a.DeliveryCount()).Equals(1)
+	assert.For(t).ThatActual(b.Payload()).Equals("b")
+	assert.For(t).ThatActual(tracker.InFlight()).Equals(2)
+
+	assert.For(t).ThatActual(a.Ack()).IsNil()
+	assert.For(t).ThatActual(a.Ack()).Equals(ErrMessageNotInFlight)
+	assert.For(t).ThatActual(a.Nack(0)).Equals(ErrMessageNotInFlight)
+	assert.For(t).ThatActual(tracker.InFlight()).Equals(1)
+
+	_, found, err := tracker.ReceiveMessage(context.Background())
+	assert.For(t).ThatActual(found).IsFalse()
+	assert.For(t).ThatActual(err).IsNil()
+}
+
+func TestInFlightTracker_visibilityTimeout(t *testing.T) {
+	tracker, queue, clock := newTestTracker("a")
+	first := receiveMessage(t, tracker, "first")
+	clock.Advance(time.Minute - time.Nanosecond)
+	_, found, _ := tracker.ReceiveMessage(context.Background())
+	assert.For(t).ThatActual(found).IsFalse()
+
+	queue.Send("b")
+	clock.Advance(time.Nanosecond)
+	second := receiveMessage(t, tracker, "second")
+	assert.For(t).ThatActual(second.Payload()).Equals("a")
+	assert.For(t).ThatActual(second.DeliveryCount()).Equals(2)
+	assert.For(t).ThatActual(first.Ack()).Equals(ErrMessageNotInFlight)
+	assert.For(t).ThatActual(second.Ack()).IsNil()
+	assert.For(t).ThatActual(receiveMessage(t, tracker, "third").Payload()).Equals("b")
+}
+
+func TestInFlightTracker_nack(t *testing.T) {
+	tracker, _, clock := newTestTracker("a", "b")
+	a := receiveMessage(t, tracker, "a")
+	assert.For(t).ThatActual(a.Nack(time.Second)).IsNil()
+	assert.For(t).ThatActual(receiveMessage(t, tracker, "b").Payload()).Equals("b")
+
+	clock.Advance(time.Second)
+	redelivered := receiveMessage(t, tracker, "redelivered")
+	assert.For(t).ThatActual(redelivered.Payload()).Equals("a")
+	assert.For(t).ThatActual(redelivered.DeliveryCount()).Equals(2)
+}
+
+func TestInFlightTracker_deadLetters(t *testing.T) {
+	tracker, _, _ := newTestTracker("a", "b")
+	deadLetters := NewInMemoryQueue()
+	tracker.WithMaxDeliveries(2).WithDeadLetterSink(deadLetters)
+	for i := 1; i <= 2; i++ {
+		a := receiveMessage(t, tracker, i)
+		assert.For(t, i).ThatActual(a.Payload()).Equals("a")
+		assert.For(t, i).ThatActual(a.Nack(0)).IsNil()
+	}
+
+	assert.For(t).ThatActual(receiveMessage(t, tracker, "b").Payload()).Equals("b")
+	assert.For(t).ThatActual(deadLetters.Len()).Equals(1)
+	payload, _, _ := deadLetters.Receive()
+	assert.For(t).ThatActual(payload).Equals("a")
+	assert.For(t).ThatActual(tracker.InFlight()).Equals(1)
+}
+
+func TestInFlightTracker_receiverError(t *testing.T) {
+	errReceive := errors.New("failed")
+	tracker := NewInFlightTracker(ContextReceiverFunc(func(context.Context) (interface{}, bool, error) {
+		return nil, false, errReceive
+	}), time.Minute)
+	payload, found, err := tracker.Receive()
+	assert.For(t).ThatActual(payload).IsNil()
+	assert.For(t).ThatActual(found).IsFalse()
+	assert.For(t).ThatActual(err).Equals(errReceive)
+	assert.For(t).ThatActual(tracker.InFlight()).Equals(0)
+}
+
+func TestInFlightTracker_polled(t *testing.T) {
+	queue := NewInMemoryQueue(1, 2, 3)
+	tracker := NewInFlightTracker(queue, time.Minute)
+	poller, err := NewPoller(tracker, WithBackoff(backoff.NewConstant(time.Millisecond)))
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+	poller.Start(context.Background())
+	defer poller.Stop()
+
+	sum := 0
+	for i := 0; i < 3; i++ {
+		message := (<-poller.Channel()).(Message)
+		sum += message.Payload().(int)
+		assert.For(t, i).ThatActual(message.Ack()).IsNil()
+	}
+	assert.For(t).ThatActual(sum).Equals(6)
+	assert.For(t).ThatActual(tracker.InFlight()).Equals(0)
+}
+
+func TestNewMessageReceiver_polled(t *testing.T) {
+	tracker, _, _ := newTestTracker(1, 2, 3)
+	// hides the tracker's Receiver methods to poll it as a native AckReceiver
+	receiver := NewMessageReceiver(struct{ AckReceiver }{tracker})
+	poller, err := NewPoller(receiver, WithBackoff(backoff.NewConstant(time.Millisecond)))
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+	poller.Start(context.Background())
+	defer poller.Stop()
+
+	sum := 0
+	for i := 0; i < 3; i++ {
+		message := (<-poller.Channel()).(Message)
+		sum += message.Payload().(int)
+		assert.For(t, i).ThatActual(message.Ack()).IsNil()
+	}
+	assert.For(t).ThatActual(sum).Equals(6)
+	assert.For(t).ThatActual(tracker.InFlight()).Equals(0)
+}
@@ -0,0 +1,203 @@
+package polling
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/gooseberry/validate"
+)
+
+const (
+	defaultAdaptiveDecreaseFactor = 0.5
+	defaultAdaptiveIncrementSteps = 16
+	defaultAdaptiveHalfLife       = time.Minute
+)
+
+// ReceiptObserver is implemented by backoffs that adapt to the outcomes of
+// calls; pollers pass the receipt of every call to their backoffs that
+// implement it, before they determine whether or not to relax.
+type ReceiptObserver interface {
+	// ObserveReceipt observes the receipt of a call.
+	ObserveReceipt(receipt *Receipt)
+}
+
+// AdaptiveBackoff is a backoff whose delays (polling intervals) adapt to
+// the arrival rate of payloads, using additive-increase/multiplicative-decrease
+// (AIMD): the interval increases by a fixed increment after every call that's
+// empty-handed (or fails), and it's multiplied by a factor less than 1 after
+// every call that finds a payload.
+//
+// The interval stays between a floor, which is the minimum interval or the one
+// that the call budget allows (whichever is longer), and a ceiling, which is
+// the shortest of the maximum interval, twice the target latency (as payloads
+// wait for half an interval on average), and the expected time between
+// arrivals. The arrival rate is estimated as an exponentially weighted moving
+// average of found payloads over time; so, the interval doesn't grow much
+// longer than the time between arrivals while payloads keep arriving, and it
+// grows up to the other limits once they stop.
+//
+// AdaptiveBackoff is configured using its With* methods, which return
+// the modified backoff to allow chaining, before it's used. It's a
+// ReceiptObserver; what it learns isn't discarded by Reset. Like other
+// backoffs, it's not safe for concurrent use; pollers clone it per worker.
+type AdaptiveBackoff struct {
+	min            time.Duration
+	max            time.Duration
+	targetLatency  time.Duration
+	budgetInterval time.Duration
+	increment      time.Duration
+	decreaseFactor float64
+	halfLife       time.Duration
+	interval       time.Duration
+	rate           float64 // payloads per second
+	lastReceipt    time.Time
+}
+
+// NewAdaptiveBackoff creates an adaptive backoff whose intervals are between
+// the specified minimum and maximum; it starts with the minimum interval.
+// By default, the interval increases by 1/16 of the range between them, is
+// halved after a payload is found, and the arrival rate's half-life is
+// 1 minute.
+func NewAdaptiveBackoff(min, max time.Duration) *AdaptiveBackoff {
+	return &AdaptiveBackoff{
+		min:            min,
+		max:            max,
+		increment:      (max - min) / defaultAdaptiveIncrementSteps,
+		decreaseFactor: defaultAdaptiveDecreaseFactor,
+		halfLife:       defaultAdaptiveHalfLife,
+		interval:       min,
+	}
+}
+
+// NewAdaptivePoller creates a polling channel that adapts its polling interval
+// to the arrival rate of payloads, between the specified minimum and maximum;
+// see AdaptiveBackoff. It's an alternative to
+// NewBernoulliExponentialBackoffPoller that doesn't need to be tuned by hand.
+func NewAdaptivePoller(receiver Receiver, entityName string, min, max time.Duration) (Poller, error) {
+	return NewPoller(receiver, WithName(entityName), WithBackoff(NewAdaptiveBackoff(min, max)))
+}
+
+// WithTargetLatency configures the latency that payloads should not exceed
+// on average, which caps the interval at twice the latency.
+func (b *AdaptiveBackoff) WithTargetLatency(latency time.Duration) *AdaptiveBackoff {
+	b.targetLatency = latency
+	return b
+}
+
+// WithCallBudget configures the maximum number of calls per the specified
+// period (of each worker), which limits how short the interval gets.
+func (b *AdaptiveBackoff) WithCallBudget(calls int, period time.Duration) *AdaptiveBackoff {
+	b.budgetInterval = 0
+	if calls > 0 {
+		b.budgetInterval = period / time.Duration(calls)
+	}
+	return b
+}
+
+// WithIncrement configures the additive increase of the interval after
+// a call that's empty-handed or fails.
+func (b *AdaptiveBackoff) WithIncrement(increment time.Duration) *AdaptiveBackoff {
+	b.increment = increment
+	return b
+}
+
+// WithDecreaseFactor configures the factor, which must be in (0, 1),
+// by which the interval is multiplied after a call that finds a payload.
+func (b *AdaptiveBackoff) WithDecreaseFactor(factor float64) *AdaptiveBackoff {
+	b.decreaseFactor = factor
+	return b
+}
+
+// WithHalfLife configures how fast the estimate of the arrival rate forgets
+// past arrivals: their weight halves every half-life.
+func (b *AdaptiveBackoff) WithHalfLife(halfLife time.Duration) *AdaptiveBackoff {
+	b.halfLife = halfLife
+	return b
+}
+
+// ObserveReceipt updates the estimate of the arrival rate, and increases or
+// decreases the interval.
+func (b *AdaptiveBackoff) ObserveReceipt(receipt *Receipt) {
+	if !b.lastReceipt.IsZero() {
+		if elapsed := receipt.Time.Sub(b.lastReceipt).Seconds(); elapsed > 0 {
+			weight := 1 - math.Exp2(-elapsed/b.halfLife.Seconds())
+			sample := 0.0
+			if receipt.succeeded() {
+				sample = 1 / elapsed
+			}
+			b.rate = weight*sample + (1-weight)*b.rate
+		}
+	}
+	b.lastReceipt = receipt.Time
+
+	if receipt.succeeded() {
+		b.interval = time.Duration(float64(b.interval) * b.decreaseFactor)
+	} else {
+		b.interval += b.increment
+	}
+	if floor := b.floor(); b.interval < floor {
+		b.interval = floor
+	} else if ceiling := b.ceiling(); b.interval > ceiling {
+		b.interval = ceiling
+	}
+}
+
+// Next returns the current interval.
+func (b *AdaptiveBackoff) Next() time.Duration {
+	if floor := b.floor(); b.interval < floor { // e.g., before any receipt is observed
+		return floor
+	}
+	return b.interval
+}
+
+// Reset does nothing, so that the backoff keeps what it has learned.
+func (b *AdaptiveBackoff) Reset() {
+}
+
+// Clone creates an adaptive backoff with the same configuration that hasn't
+// learned anything yet.
+func (b *AdaptiveBackoff) Clone() backoff.Backoff {
+	clone := *b
+	clone.interval, clone.rate, clone.lastReceipt = b.min, 0, time.Time{}
+	return &clone
+}
+
+func (b *AdaptiveBackoff) floor() time.Duration {
+	if b.budgetInterval > b.min {
+		return b.budgetInterval
+	}
+	return b.min
+}
+
+func (b *AdaptiveBackoff) ceiling() time.Duration {
+	ceiling := b.max
+	if b.targetLatency > 0 && 2*b.targetLatency < ceiling {
+		ceiling = 2 * b.targetLatency
+	}
+	if b.rate > 0 {
+		if expected := time.Duration(float64(time.Second) / b.rate); expected < ceiling {
+			ceiling = expected
+		}
+	}
+	if floor := b.floor(); ceiling < floor {
+		return floor
+	}
+	return ceiling
+}
+
+func (b *AdaptiveBackoff) validate() error {
+	switch {
+	case b.min <= 0 || b.max < b.min:
+		return validate.NewValidationError(
+			errors.New("min must be positive, and max must be at least min"), "interval")
+	case b.increment < 0:
+		return validate.NewValidationError(errors.New("increment is negative"), "increment")
+	case b.decreaseFactor <= 0 || b.decreaseFactor >= 1:
+		return validate.NewValidationError(errors.New("decrease factor must be in (0, 1)"), "decreaseFactor")
+	case b.halfLife <= 0:
+		return validate.NewValidationError(errors.New("half-life must be positive"), "halfLife")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/tester/assert"
+)
+
+// observe makes the backoff observe receipts, one per second starting at
+// the Unix epoch, that are found as denoted by the specified string ('f' for
+// found, 'e' for empty-handed, and 'x' for failed), returning the intervals
+// after each one.
+func observe(b *AdaptiveBackoff, receipts string) []time.Duration {
+	intervals := make([]time.Duration, len(receipts))
+	for i, r := range receipts {
+		receipt := &Receipt{Found: r == 'f', Time: time.Unix(int64(i), 0)}
+		if r == 'x' {
+			receipt.Err = errors.New("failed")
+		}
+		b.ObserveReceipt(receipt)
+		intervals[i] = b.Next()
+	}
+	return intervals
+}
+
+func TestAdaptiveBackoff_aimd(t *testing.T) {
+	b := NewAdaptiveBackoff(time.Second, 6*time.Second).WithIncrement(time.Second).WithHalfLife(time.Hour)
+	assert.For(t).ThatActual(b.Next()).Equals(time.Second)
+	assert.For(t).ThatActual(observe(b, "eexeeeef")).Equals([]time.Duration{
+		2 * time.Second, 3 * time.Second, 4 * time.Second, 5 * time.Second,
+		6 * time.Second, 6 * time.Second, 6 * time.Second, 3 * time.Second,
+	}).ThenDiffOnFail()
+
+	b.Reset()
+	assert.For(t).ThatActual(b.Next()).Equals(3 * time.Second)
+	clone := b.Clone().(*AdaptiveBackoff)
+	assert.For(t).ThatActual(clone.Next()).Equals(time.Second)
+	assert.For(t).ThatActual(clone.rate).Equals(0.0)
+}
+
+func TestAdaptiveBackoff_limits(t *testing.T) {
+	cases := []struct {
+		id       string
+		backoff  *AdaptiveBackoff
+		expected time.Duration
+	}{
+		{"max", NewAdaptiveBackoff(time.Second, 8*time.Second), 8 * time.Second},
+		{"target latency", NewAdaptiveBackoff(time.Second, 8*time.Second).WithTargetLatency(2 * time.Second), 4 * time.Second},
+		{"call budget", NewAdaptiveBackoff(time.Second, 8*time.Second).WithCallBudget(4, time.Minute), 15 * time.Second},
+	}
+
+	for _, c := range cases {
+		intervals := observe(c.backoff, "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+		assert.For(t, c.id).ThatActual(intervals[len(intervals)-1]).Equals(c.expected)
+	}
+
+	b := NewAdaptiveBackoff(time.Second, 8*time.Second).WithCallBudget(4, time.Minute)
+	assert.For(t).ThatActual(b.Next()).Equals(15 * time.Second)
+	assert.For(t).ThatActual(observe(b, "f")).Equals([]time.Duration{15 * time.Second})
+}
+
+func TestAdaptiveBackoff_arrivalRate(t *testing.T) {
+	b := NewAdaptiveBackoff(100*time.Millisecond, time.Minute).WithIncrement(time.Second).WithHalfLife(10 * time.Second)
+	intervals := observe(b, "efefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefe")
+	assert.For(t).ThatActual(b.rate > 0.45 && b.rate < 0.55).IsTrue()
+	assert.For(t).ThatActual(intervals[len(intervals)-1] <= 2200*time.Millisecond).IsTrue()
+
+	intervals = observe(b, "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+	assert.For(t).ThatActual(b.rate < 0.1).IsTrue()
+	assert.For(t).ThatActual(intervals[len(intervals)-1] > 10*time.Second).IsTrue()
+}
+
+func TestAdaptiveBackoff_invalid(t *testing.T) {
+	cases := []struct {
+		id                   string
+		backoff              *AdaptiveBackoff
+		expectedArgumentName string
+	}{
+		{"zero min", NewAdaptiveBackoff(0, time.Second), "interval"},
+		{"max less than min", NewAdaptiveBackoff(time.Second, time.Millisecond), "interval"},
+		{"negative increment", NewAdaptiveBackoff(time.Second, time.Minute).WithIncrement(-1), "increment"},
+		{"factor too big", NewAdaptiveBackoff(time.Second, time.Minute).WithDecreaseFactor(1), "decreaseFactor"},
+		{"zero half-life", NewAdaptiveBackoff(time.Second, time.Minute).WithHalfLife(0), "halfLife"},
+	}
+
+	for _, c := range cases {
+		_, err := NewPoller(&alwaysEmptyHandedReceiver{}, WithBackoff(c.backoff))
+		if assert.For(t, c.id).ThatActual(err).IsNotNil().Passed() {
+			assert.For(t, c.id).ThatActual(err.(*validate.ValidationError).ArgumentName).Equals(c.expectedArgumentName)
+		}
+	}
+}
+
+func TestNewAdaptivePoller(t *testing.T) {
+	poller, err := NewAdaptivePoller(&neverEmptyHandedReceiver{}, "adaptive", time.Millisecond, time.Second)
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActualString(poller.GetName()).Equals("adaptive")
+		assert.For(t).ThatActual(poller.Reconfigure(WithBackoff(NewAdaptiveBackoff(0, 0)))).IsNotNil()
+	}
+
+	_, err = NewAdaptivePoller(&neverEmptyHandedReceiver{}, "adaptive", time.Second, time.Millisecond)
+	assert.For(t).ThatActual(err).IsNotNil()
+}
+
+func TestPoller_adaptive(t *testing.T) {
+	poller := newScriptedPoller("adaptive", []error{nil, nil, nil, errFound, nil},
+		WithBackoff(NewAdaptiveBackoff(time.Second, time.Minute).WithIncrement(time.Second)))
+	recorder := poller.(*pollingChannel).clock.(*sleepRecorder)
+	assert.For(t).ThatActual(poller.Run(context.Background())).IsNil()
+	assert.For(t).ThatActual(recorder.recorded()).Equals(
+		[]time.Duration{2 * time.Second, 3 * time.Second, 4 * time.Second, 3 * time.Second}).ThenDiffOnFail()
+}
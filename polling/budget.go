@@ -0,0 +1,177 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/clock"
+	"github.com/voicera/gooseberry/validate"
+)
+
+const (
+	defaultPacingThreshold = 0.75
+)
+
+// CallBudget caps the number of calls to receivers per time window (e.g.,
+// to cap the cost of polling resources whose providers charge by the call);
+// it can be shared by pollers (see WithCallBudget), which then share the cap.
+// Windows are consecutive and start when the budget is created.
+//
+// Once the fraction of the window's calls that are used reaches the pacing
+// threshold, the remaining calls are spread evenly over the rest of
+// the window; once they're all used, pollers wait for the next window
+// (rather than fail). CallBudget is configured using its With* methods,
+// which return the modified budget to allow chaining, before it's used;
+// it's safe for concurrent use.
+type CallBudget struct {
+	mutex           sync.Mutex
+	calls           int
+	window          time.Duration
+	pacingThreshold float64
+	clock           clock.Clock
+	windowStart     time.Time
+	used            int
+	nextCall        time.Time // when paced
+}
+
+// BudgetStats represents a snapshot of the state of a call budget.
+type BudgetStats struct {
+	// Calls is the number of calls allowed per window.
+	Calls int `json:"calls"`
+
+	// Window is the duration of the windows.
+	Window time.Duration `json:"window"`
+
+	// Used is the number of calls made in the current window.
+	Used int `json:"used"`
+
+	// Remaining is the number of calls left in the current window.
+	Remaining int `json:"remaining"`
+
+	// WindowEnd is when the current window ends.
+	WindowEnd time.Time `json:"windowEnd"`
+
+	// Paced denotes whether or not the remaining calls are being spread over
+	// the rest of the window.
+	Paced bool `json:"paced"`
+}
+
+// NewCallBudget creates a budget of the specified number of calls per window,
+// whose remaining calls are paced once 75% of them are used.
+func NewCallBudget(calls int, window time.Duration) *CallBudget {
+	budget := &CallBudget{calls: calls, window: window, pacingThreshold: defaultPacingThreshold}
+	return budget.WithClock(clock.New())
+}
+
+// WithPacingThreshold configures the fraction, which must be in [0, 1],
+// of a window's calls after which the remaining ones are paced.
+func (budget *CallBudget) WithPacingThreshold(threshold float64) *CallBudget {
+	budget.pacingThreshold = threshold
+	return budget
+}
+
+// WithClock configures the clock that the budget uses to tell time and to
+// wait (e.g., a fake one in tests); by default, it uses the real clock.
+// The current window starts over.
+func (budget *CallBudget) WithClock(clock clock.Clock) *CallBudget {
+	budget.clock = clock
+	budget.windowStart, budget.used, budget.nextCall = clock.Now(), 0, time.Time{}
+	return budget
+}
+
+// Stats returns a snapshot of the budget's state.
+func (budget *CallBudget) Stats() BudgetStats {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	budget.advance(budget.clock.Now())
+	return BudgetStats{
+		Calls:     budget.calls,
+		Window:    budget.window,
+		Used:      budget.used,
+		Remaining: budget.calls - budget.used,
+		WindowEnd: budget.windowStart.Add(budget.window),
+		Paced:     budget.paced(),
+	}
+}
+
+// MarshalJSON marshals the stats into JSON, with the window formatted as
+// a string (e.g., "1h0m0s").
+func (stats BudgetStats) MarshalJSON() ([]byte, error) {
+	type plainBudgetStats BudgetStats
+	return json.Marshal(&struct {
+		plainBudgetStats
+		Window string `json:"window"`
+	}{plainBudgetStats(stats), stats.Window.String()})
+}
+
+// acquire waits until a call is allowed and uses it up; it returns false if
+// the specified context is done first.
+func (budget *CallBudget) acquire(ctx context.Context, poller string) bool {
+	for {
+		wait, exhausted := budget.reserve()
+		if wait <= 0 {
+			return true
+		}
+		if exhausted {
+			gooseberry.Logger.Debug("Waiting for the next call budget window", "poller", poller, "wait", wait)
+		}
+		timer := budget.clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// reserve uses up a call if one is allowed now; otherwise, it returns how
+// long to wait before trying again, and whether or not the window's calls
+// are exhausted.
+func (budget *CallBudget) reserve() (time.Duration, bool) {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	now := budget.clock.Now()
+	budget.advance(now)
+	windowEnd := budget.windowStart.Add(budget.window)
+	if budget.used >= budget.calls {
+		return windowEnd.Sub(now), true
+	}
+	if budget.paced() {
+		if now.Before(budget.nextCall) {
+			return budget.nextCall.Sub(now), false
+		}
+		budget.nextCall = now.Add(windowEnd.Sub(now) / time.Duration(budget.calls-budget.used))
+	}
+	budget.used++
+	return 0, false
+}
+
+// advance starts the window that the specified time is in, if it's not
+// the current one; the budget must be locked.
+func (budget *CallBudget) advance(now time.Time) {
+	if elapsed := now.Sub(budget.windowStart); budget.window > 0 && elapsed >= budget.window {
+		budget.windowStart = budget.windowStart.Add(elapsed - elapsed%budget.window)
+		budget.used, budget.nextCall = 0, time.Time{}
+	}
+}
+
+// paced determines whether or not the remaining calls are paced; the budget
+// must be locked.
+func (budget *CallBudget) paced() bool {
+	return float64(budget.used) >= budget.pacingThreshold*float64(budget.calls)
+}
+
+func (budget *CallBudget) validate() error {
+	switch {
+	case budget.calls <= 0:
+		return validate.NewValidationError(errors.New("calls must be positive"), "calls")
+	case budget.window <= 0:
+		return validate.NewValidationError(errors.New("window must be positive"), "window")
+	}
+	return validate.InRange(budget.pacingThreshold, 0, 1, "pacingThreshold")
+}
@@ -0,0 +1,117 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/tester/assert"
+)
+
+func TestCallBudget_exhausted(t *testing.T) {
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	budget := NewCallBudget(3, time.Minute).WithPacingThreshold(1).WithClock(fake)
+	for i := 0; i < 3; i++ {
+		wait, exhausted := budget.reserve()
+		assert.For(t, i).ThatActual(wait).Equals(time.Duration(0))
+		assert.For(t, i).ThatActual(exhausted).IsFalse()
+	}
+
+	fake.Advance(20 * time.Second)
+	wait, exhausted := budget.reserve()
+	assert.For(t).ThatActual(wait).Equals(40 * time.Second)
+	assert.For(t).ThatActual(exhausted).IsTrue()
+	assert.For(t).ThatActual(budget.Stats()).Equals(BudgetStats{
+		Calls: 3, Window: time.Minute, Used: 3, WindowEnd: time.Unix(60, 0), Paced: true}).ThenDiffOnFail()
+
+	fake.Advance(100 * time.Second) // skips a window
+	wait, _ = budget.reserve()
+	assert.For(t).ThatActual(wait).Equals(time.Duration(0))
+	assert.For(t).ThatActual(budget.Stats()).Equals(BudgetStats{
+		Calls: 3, Window: time.Minute, Used: 1, Remaining: 2, WindowEnd: time.Unix(180, 0)}).ThenDiffOnFail()
+}
+
+func TestCallBudget_pacing(t *testing.T) {
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	budget := NewCallBudget(10, 100*time.Second).WithPacingThreshold(0.5).WithClock(fake)
+	var waits []time.Duration
+	for i := 0; i < 7; i++ {
+		wait, _ := budget.reserve()
+		waits = append(waits, wait)
+	}
+	assert.For(t).ThatActual(waits).Equals([]time.Duration{0, 0, 0, 0, 0, 0, 20 * time.Second}).ThenDiffOnFail()
+
+	fake.Advance(20 * time.Second)
+	wait, _ := budget.reserve()
+	assert.For(t).ThatActual(wait).Equals(time.Duration(0))
+	fake.Advance(10 * time.Second)
+	wait, _ = budget.reserve()
+	assert.For(t).ThatActual(wait).Equals(10 * time.Second)
+}
+
+func TestCallBudget_acquire(t *testing.T) {
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	budget := NewCallBudget(1, time.Minute).WithClock(fake)
+	assert.For(t).ThatActual(budget.acquire(context.Background(), "test")).IsTrue()
+
+	acquired := make(chan bool)
+	go func() { acquired <- budget.acquire(context.Background(), "test") }()
+	fake.WaitForTimers(1)
+	fake.Advance(time.Minute)
+	assert.For(t).ThatActual(<-acquired).IsTrue()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.For(t).ThatActual(budget.acquire(ctx, "test")).IsFalse()
+}
+
+func TestPoller_sharedCallBudget(t *testing.T) {
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	budget := NewCallBudget(3, time.Hour).WithPacingThreshold(1).WithClock(fake)
+	var pollers []Poller
+	for _, name := range []string{"calls", "texts"} {
+		poller, err := NewPoller(&neverEmptyHandedReceiver{}, WithName(name), WithBufferSize(3), WithCallBudget(budget))
+		if !assert.For(t, name).ThatActual(err).IsNil().Passed() {
+			return
+		}
+		poller.Start(context.Background())
+		pollers = append(pollers, poller)
+	}
+
+	fake.WaitForTimers(2) // both pollers wait for the next window
+	payloads := len(pollers[0].Channel()) + len(pollers[1].Channel())
+	assert.For(t).ThatActual(payloads).Equals(3)
+	for _, poller := range pollers {
+		stats := poller.Stats()
+		assert.For(t, poller.GetName()).ThatActual(stats.Budget.Remaining).Equals(0)
+		poller.Stop()
+		<-poller.Done()
+	}
+
+	marshalled, _ := json.Marshal(pollers[0].Stats())
+	var actual map[string]interface{}
+	json.Unmarshal(marshalled, &actual)
+	assert.For(t).ThatActual(actual["budget"].(map[string]interface{})["window"]).Equals("1h0m0s")
+}
+
+func TestNewPoller_invalidCallBudget(t *testing.T) {
+	cases := []struct {
+		id                   string
+		budget               *CallBudget
+		expectedArgumentName string
+	}{
+		{"no calls", NewCallBudget(0, time.Hour), "calls"},
+		{"no window", NewCallBudget(1, 0), "window"},
+		{"threshold", NewCallBudget(1, time.Hour).WithPacingThreshold(2), "pacingThreshold"},
+	}
+
+	for _, c := range cases {
+		_, err := NewPoller(&alwaysEmptyHandedReceiver{}, WithCallBudget(c.budget))
+		if assert.For(t, c.id).ThatActual(err).IsNotNil().Passed() {
+			assert.For(t, c.id).ThatActual(err.(*validate.ValidationError).ArgumentName).Equals(c.expectedArgumentName)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/voicera/gooseberry/backoff"
 	"github.com/voicera/gooseberry/validate"
 )
 
@@ -11,52 +12,259 @@ func init() {
 	rand.Seed(time.Now().UnixNano()) // for Bernoulli sampling
 }
 
-// relaxationCondition represents a condition that can be composed in runtime;
-// for example, it can be config-drived or created via dependency injection.
-type relaxationCondition interface {
-	shouldRelax(lastReceiptSucceeded bool) bool
+// Condition determines whether or not a poller relaxes (backs off) after
+// a call to its receiver; it can be composed in runtime; for example, it can
+// be config-drived or created via dependency injection. Conditions are
+// evaluated by the goroutine that polls; they may be shared across pollers,
+// so stateful ones should be safe for concurrent use.
+type Condition interface {
+	// ShouldRelax determines whether or not to relax after the specified
+	// receipt.
+	ShouldRelax(receipt *Receipt) bool
+}
+
+// Receipt represents the outcome of a call to a poller's receiver.
+type Receipt struct {
+	// Found denotes whether or not a payload was found.
+	Found bool
+
+	// Err is the error that the call failed with, if any.
+	Err error
+
+	// ConsecutiveEmpty is the number of consecutive empty-handed calls,
+	// up to and including this one; it's reset when a payload is found.
+	// Failed calls neither count nor break the streak.
+	ConsecutiveEmpty int
+
+	// ConsecutiveErrors is the number of consecutive failed calls, up to and
+	// including this one; it's reset when a call doesn't fail.
+	ConsecutiveErrors int
+
+	// Time is when the call returned.
+	Time time.Time
+}
+
+// succeeded determines whether or not the call found a payload without error.
+func (receipt *Receipt) succeeded() bool {
+	return receipt.Found && receipt.Err == nil
+}
+
+// validatable is implemented by conditions (and backoffs) that can be
+// misconfigured; pollers validate their conditions and backoffs when they're
+// created or reconfigured.
+type validatable interface {
+	validate() error
+}
+
+func validateCondition(condition Condition) error {
+	if v, ok := condition.(validatable); ok {
+		return v.validate()
+	}
+	return nil
+}
+
+func validateBackoff(b backoff.Backoff) error {
+	if v, ok := b.(validatable); ok {
+		return v.validate()
+	}
+	return nil
+}
+
+func validateConditions(conditions []Condition) error {
+	for _, c := range conditions {
+		if err := validateCondition(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// And creates a condition that's true if all of the specified conditions are
+// true (or if there are none); conditions are evaluated in order until one
+// of them is false.
+func And(conditions ...Condition) Condition {
+	return &and{conditions}
 }
 
-// andRelaxationCondition is true if all conditions are true; order matters.
 type and struct {
-	conditions []relaxationCondition
+	conditions []Condition
 }
 
-func (and *and) shouldRelax(lastReceiptSucceeded bool) bool {
+func (and *and) ShouldRelax(receipt *Receipt) bool {
 	for _, c := range and.conditions {
-		if !c.shouldRelax(lastReceiptSucceeded) {
+		if !c.ShouldRelax(receipt) {
 			return false
 		}
 	}
 	return true
 }
 
-// A relaxationCondition that evaluates to false if the last call was
-// empty-handed (no payload). The rationale is: if the last call was
+func (and *and) validate() error {
+	return validateConditions(and.conditions)
+}
+
+// Or creates a condition that's true if any of the specified conditions is
+// true; conditions are evaluated in order until one of them is true.
+func Or(conditions ...Condition) Condition {
+	return &or{conditions}
+}
+
+type or struct {
+	conditions []Condition
+}
+
+func (or *or) ShouldRelax(receipt *Receipt) bool {
+	for _, c := range or.conditions {
+		if c.ShouldRelax(receipt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (or *or) validate() error {
+	return validateConditions(or.conditions)
+}
+
+// Not creates a condition that negates the specified one.
+func Not(condition Condition) Condition {
+	return &not{condition}
+}
+
+type not struct {
+	condition Condition
+}
+
+func (not *not) ShouldRelax(receipt *Receipt) bool {
+	return !not.condition.ShouldRelax(receipt)
+}
+
+func (not *not) validate() error {
+	return validateCondition(not.condition)
+}
+
+// EmptyHanded creates a condition that's true if the call was empty-handed
+// (no payload, and no error). The rationale is: if the last call was
 // empty-handed, the next one is most probably alike.
+func EmptyHanded() Condition {
+	return &emptyHanded{}
+}
+
 type emptyHanded struct{}
 
-func (*emptyHanded) shouldRelax(lastReceiptSucceeded bool) bool {
-	return !lastReceiptSucceeded
+func (*emptyHanded) ShouldRelax(receipt *Receipt) bool {
+	return !receipt.Found && receipt.Err == nil
+}
+
+// OnError creates a condition that's true if the call failed.
+func OnError() Condition {
+	return &onError{}
 }
 
-// A relaxationCondition that runs a Bernoulli trial:
-//     rand.Float64() < value
+type onError struct{}
+
+func (*onError) ShouldRelax(receipt *Receipt) bool {
+	return receipt.Err != nil
+}
+
+// ConsecutiveEmpty creates a condition that's true after the specified number
+// of consecutive empty-handed calls (see Receipt.ConsecutiveEmpty).
+func ConsecutiveEmpty(count int) Condition {
+	return &consecutiveEmpty{count}
+}
+
+type consecutiveEmpty struct {
+	count int
+}
+
+func (condition *consecutiveEmpty) ShouldRelax(receipt *Receipt) bool {
+	return receipt.ConsecutiveEmpty >= condition.count
+}
+
+// BernoulliSampler is a condition that runs a Bernoulli trial:
+//
+//	random() < probability
+//
 // to determine whether or not to relax.
-type bernoulliSampler struct {
-	float64
+type BernoulliSampler struct {
+	probability float64
+	random      func() float64
+}
+
+// Bernoulli creates a condition that runs Bernoulli trials with the specified
+// probability, which must be in [0, 1], using the math/rand package's global
+// source, which is safe for concurrent use; WithRandom configures another one.
+func Bernoulli(probability float64) *BernoulliSampler {
+	return &BernoulliSampler{probability: probability, random: rand.Float64}
 }
 
-// newBernoulliSampler creates a probabilistic relaxer that relaxes
-// if the following Bernoulli trial is true:
-//     rand.Float64() < probability
-func newBernoulliSampler(probability float64) (relaxationCondition, error) {
-	if err := validate.InRange(probability, 0.0, 1.0, "probability"); err != nil {
-		return nil, err
+// WithRandom configures the source of random numbers in [0, 1) that
+// the sampler uses (e.g., a seeded *rand.Rand's Float64 method).
+func (sampler *BernoulliSampler) WithRandom(random func() float64) *BernoulliSampler {
+	sampler.random = random
+	return sampler
+}
+
+// ShouldRelax runs a Bernoulli trial.
+func (sampler *BernoulliSampler) ShouldRelax(*Receipt) bool {
+	return sampler.random() < sampler.probability
+}
+
+func (sampler *BernoulliSampler) validate() error {
+	return validate.InRange(sampler.probability, 0.0, 1.0, "probability")
+}
+
+// DailyWindow creates a condition that's true if the call returned in
+// the specified window of the day, in the specified location (UTC if nil).
+// The window starts and ends at the specified offsets from midnight; windows
+// that end before they start span midnight (e.g., from 22h to 6h).
+func DailyWindow(start, end time.Duration, location *time.Location) Condition {
+	return &dailyWindow{start: start, end: end, location: orUTC(location)}
+}
+
+type dailyWindow struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+func (window *dailyWindow) ShouldRelax(receipt *Receipt) bool {
+	t := receipt.Time.In(window.location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	if window.start <= window.end {
+		return offset >= window.start && offset < window.end
 	}
-	return &bernoulliSampler{probability}, nil
+	return offset >= window.start || offset < window.end
 }
 
-func (sampler *bernoulliSampler) shouldRelax(lastReceiptSucceeded bool) bool {
-	return rand.Float64() < sampler.float64
+// OnWeekdays creates a condition that's true if the call returned on any of
+// the specified days of the week, in the specified location (UTC if nil).
+// For example, business hours can be expressed as:
+//
+//	polling.And(
+//		polling.OnWeekdays(location, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday),
+//		polling.DailyWindow(9*time.Hour, 17*time.Hour, location))
+func OnWeekdays(location *time.Location, days ...time.Weekday) Condition {
+	weekdays := map[time.Weekday]bool{}
+	for _, day := range days {
+		weekdays[day] = true
+	}
+	return &onWeekdays{weekdays: weekdays, location: orUTC(location)}
+}
+
+type onWeekdays struct {
+	weekdays map[time.Weekday]bool
+	location *time.Location
+}
+
+func (condition *onWeekdays) ShouldRelax(receipt *Receipt) bool {
+	return condition.weekdays[receipt.Time.In(condition.location).Weekday()]
+}
+
+func orUTC(location *time.Location) *time.Location {
+	if location == nil {
+		return time.UTC
+	}
+	return location
 }
@@ -1,60 +1,165 @@
 package polling
 
 import (
+	"errors"
 	"math/rand"
 	"testing"
+	"time"
 
+	"github.com/voicera/gooseberry/validate"
 	"github.com/voicera/tester/assert"
 )
 
-type passthrough struct{}
+var (
+	successfulReceipt = &Receipt{Found: true}
+	emptyReceipt      = &Receipt{ConsecutiveEmpty: 1}
+	failedReceipt     = &Receipt{Err: errors.New("failed successfully")}
+)
+
+type succeeded struct{}
 
-func (*passthrough) shouldRelax(lastReceiptSucceeded bool) bool {
-	return lastReceiptSucceeded
+func (*succeeded) ShouldRelax(receipt *Receipt) bool {
+	return receipt.succeeded()
 }
 
-type not struct{}
+type constant bool
 
-func (*not) shouldRelax(lastReceiptSucceeded bool) bool {
-	return !lastReceiptSucceeded
+func (c constant) ShouldRelax(*Receipt) bool {
+	return bool(c)
 }
 
 func TestAnd(t *testing.T) {
 	cases := []struct {
-		*and
-		lastReceiptSucceeded bool
-		expected             bool
+		Condition
+		receipt  *Receipt
+		expected bool
 	}{
-		{&and{}, true, true},
-		{&and{}, false, true},
-		{&and{[]relaxationCondition{}}, true, true},
-		{&and{[]relaxationCondition{}}, false, true},
-		{&and{[]relaxationCondition{&and{}}}, true, true},
-		{&and{[]relaxationCondition{&and{}}}, false, true},
-		{&and{[]relaxationCondition{&passthrough{}}}, true, true},
-		{&and{[]relaxationCondition{&passthrough{}}}, false, false},
-		{&and{[]relaxationCondition{&passthrough{}, &not{}}}, true, false},
-		{&and{[]relaxationCondition{&passthrough{}, &not{}}}, false, false},
+		{And(), successfulReceipt, true},
+		{And(), emptyReceipt, true},
+		{And(And()), successfulReceipt, true},
+		{And(And()), emptyReceipt, true},
+		{And(&succeeded{}), successfulReceipt, true},
+		{And(&succeeded{}), emptyReceipt, false},
+		{And(&succeeded{}, Not(&succeeded{})), successfulReceipt, false},
+		{And(&succeeded{}, Not(&succeeded{})), emptyReceipt, false},
 	}
 
-	for _, c := range cases {
-		assert.For(t).ThatActual(c.shouldRelax(c.lastReceiptSucceeded)).Equals(c.expected)
+	for i, c := range cases {
+		assert.For(t, i).ThatActual(c.ShouldRelax(c.receipt)).Equals(c.expected)
+	}
+}
+
+func TestOr(t *testing.T) {
+	cases := []struct {
+		Condition
+		expected bool
+	}{
+		{Or(), false},
+		{Or(constant(false)), false},
+		{Or(constant(false), constant(true)), true},
+		{Or(constant(true), constant(false)), true},
+		{Or(Or(), And()), true},
+	}
+
+	for i, c := range cases {
+		assert.For(t, i).ThatActual(c.ShouldRelax(emptyReceipt)).Equals(c.expected)
 	}
 }
 
-func TestEmptyHanded(t *testing.T) {
-	condition := &emptyHanded{}
-	assert.For(t).ThatActual(condition.shouldRelax(true)).IsFalse()
-	assert.For(t).ThatActual(condition.shouldRelax(false)).IsTrue()
+func TestNot(t *testing.T) {
+	assert.For(t).ThatActual(Not(constant(false)).ShouldRelax(emptyReceipt)).IsTrue()
+	assert.For(t).ThatActual(Not(constant(true)).ShouldRelax(emptyReceipt)).IsFalse()
 }
 
-func TestBernoulliSampler(t *testing.T) {
-	doNotCare := false
-	condition, err := newBernoulliSampler(0.5)
-	assert.For(t).ThatActual(err).IsNil()
+func TestReceiptConditions(t *testing.T) {
+	cases := []struct {
+		id        string
+		condition Condition
+		expected  []bool // for successful, empty, and failed receipts
+	}{
+		{"empty-handed", EmptyHanded(), []bool{false, true, false}},
+		{"on error", OnError(), []bool{false, false, true}},
+		{"empty-handed or on error", Or(EmptyHanded(), OnError()), []bool{false, true, true}},
+		{"consecutive empty", ConsecutiveEmpty(1), []bool{false, true, false}},
+		{"more consecutive empty", ConsecutiveEmpty(2), []bool{false, false, false}},
+	}
+
+	for _, c := range cases {
+		for i, receipt := range []*Receipt{successfulReceipt, emptyReceipt, failedReceipt} {
+			assert.For(t, c.id, i).ThatActual(c.condition.ShouldRelax(receipt)).Equals(c.expected[i])
+		}
+	}
+}
+
+func TestBernoulli(t *testing.T) {
+	condition := Bernoulli(0.5)
+	assert.For(t).ThatActual(validateCondition(condition)).IsNil()
 	rand.Seed(0) // to produce the same sequence of pseudo-random numbers every time
 	sequence := []bool{false, true, false, true, true, true, true, false, false, true}
-	for _, expected := range sequence {
-		assert.For(t).ThatActual(condition.shouldRelax(doNotCare)).Equals(expected)
+	for i, expected := range sequence {
+		assert.For(t, i).ThatActual(condition.ShouldRelax(emptyReceipt)).Equals(expected)
+	}
+
+	random := 0.3
+	condition.WithRandom(func() float64 { return random })
+	assert.For(t, "below").ThatActual(condition.ShouldRelax(emptyReceipt)).IsTrue()
+	random = 0.5
+	assert.For(t, "equal").ThatActual(condition.ShouldRelax(emptyReceipt)).IsFalse()
+}
+
+func TestDailyWindow(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database is unavailable")
+	}
+	cases := []struct {
+		id        string
+		condition Condition
+		time      time.Time
+		expected  bool
+	}{
+		{"inside", DailyWindow(9*time.Hour, 17*time.Hour, nil), time.Date(2018, 1, 1, 9, 0, 0, 0, time.UTC), true},
+		{"end", DailyWindow(9*time.Hour, 17*time.Hour, nil), time.Date(2018, 1, 1, 17, 0, 0, 0, time.UTC), false},
+		{"before", DailyWindow(9*time.Hour, 17*time.Hour, nil), time.Date(2018, 1, 1, 8, 59, 59, 0, time.UTC), false},
+		{"location", DailyWindow(9*time.Hour, 17*time.Hour, newYork), time.Date(2018, 1, 1, 15, 0, 0, 0, time.UTC), true},
+		{"midnight, late", DailyWindow(22*time.Hour, 6*time.Hour, nil), time.Date(2018, 1, 1, 23, 0, 0, 0, time.UTC), true},
+		{"midnight, early", DailyWindow(22*time.Hour, 6*time.Hour, nil), time.Date(2018, 1, 1, 5, 0, 0, 0, time.UTC), true},
+		{"midnight, outside", DailyWindow(22*time.Hour, 6*time.Hour, nil), time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, c := range cases {
+		assert.For(t, c.id).ThatActual(c.condition.ShouldRelax(&Receipt{Time: c.time})).Equals(c.expected)
+	}
+}
+
+func TestOnWeekdays(t *testing.T) {
+	condition := OnWeekdays(time.FixedZone("UTC-5", -5*60*60), time.Saturday, time.Sunday)
+	monday := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.For(t, "monday").ThatActual(condition.ShouldRelax(&Receipt{Time: monday})).IsFalse()
+	assert.For(t, "saturday").ThatActual(condition.ShouldRelax(&Receipt{Time: monday.AddDate(0, 0, 5)})).IsTrue()
+	assert.For(t, "monday in UTC").ThatActual(condition.ShouldRelax(&Receipt{Time: monday.Add(-10 * time.Hour)})).IsTrue()
+}
+
+func TestValidateCondition(t *testing.T) {
+	cases := []struct {
+		id        string
+		condition Condition
+		isValid   bool
+	}{
+		{"not validatable", EmptyHanded(), true},
+		{"valid", Bernoulli(1), true},
+		{"invalid", Bernoulli(1.1), false},
+		{"nested invalid", And(EmptyHanded(), And(Bernoulli(-1))), false},
+		{"invalid in or", Or(EmptyHanded(), Bernoulli(-1)), false},
+		{"invalid in not", Not(Bernoulli(-1)), false},
+	}
+
+	for _, c := range cases {
+		err := validateCondition(c.condition)
+		if c.isValid {
+			assert.For(t, c.id).ThatActual(err).IsNil()
+		} else if assert.For(t, c.id).ThatActual(err).IsNotNil().Passed() {
+			assert.For(t, c.id).ThatActual(err.(*validate.ValidationError).ArgumentName).Equals("probability")
+		}
 	}
 }
@@ -6,7 +6,9 @@ using a PollingChannel to wrap said receiver is provides a better poller that
 may use contingent on Bernoulli trials cyclic exponential backoff between calls.
 
 For example,
-    polling.NewPoller(receiver, 0.95, time.Millisecond, time.Minute)
+
+	polling.NewBernoulliExponentialBackoffPoller(receiver, "calls", 0.95, time.Millisecond, time.Minute)
+
 creates a poller that keeps sending along (via the poller's send-only channel)
 payloads received from the specified receiver as long as they keep arriving.
 When the receiver is empty-handed (denoted by a flag on the Receive method's
@@ -20,5 +22,96 @@ is capped at 1 minute (cap), after which it starts from the seed again.
 The contingent nature of the cyclic exponential backoff between calls (thanks to
 Bernoulli sampling) allows the poller to break out of the cycle to check for new
 payloads – intermittently, which is useful when the delay intervals are long.
+
+Pollers can be configured with other strategies using NewPoller, which accepts
+any backoff (see the backoff package) and relaxation condition. For example,
+
+	polling.NewPoller(receiver,
+		polling.WithName("calls"),
+		polling.WithBackoff(backoff.NewEqualJitter(time.Second, time.Minute)))
+
+creates a poller that backs off after every empty-handed (or failed) call,
+with randomized exponential delays that saturate at 1 minute.
+
+Instead of tuning a backoff by hand, pollers can adapt their polling interval
+to the arrival rate of payloads; for example,
+
+	polling.NewAdaptivePoller(receiver, "calls", 100*time.Millisecond, time.Minute)
+
+creates a poller whose interval increases additively while calls are
+empty-handed and decreases multiplicatively when payloads are found (AIMD),
+without growing much longer than the estimated time between arrivals; see
+AdaptiveBackoff for targeting a latency or a call budget.
+
+To cap the cost of polling, WithCallBudget configures pollers to share
+a budget of calls per time window; for example,
+
+	budget := polling.NewCallBudget(10000, time.Hour)
+
+caps the pollers that share it at 10,000 calls per hour in total, spreads
+the last quarter of each window's calls evenly over the rest of the window,
+and makes the pollers wait for the next window once the calls are used up.
+
+To poll REST resources, NewRESTReceiver creates a receiver that fetches
+a resource incrementally using a Cursor; for example,
+
+	polling.NewRESTReceiver(client, "Calls.json", func() interface{} { return &[]*Call{} }).
+		WithCursor(polling.NewSinceCursor("StartTime>", time.RFC3339, start, startTimeOf)).
+		WithDeduplication(callSID, sets.NewExpirableSet(0, time.Hour))
+
+creates a receiver that asks for calls made since the latest one received so
+far, and that drops the calls it has already received within the last hour
+(windows that overlap are common when timestamps aren't unique). Responses with
+no new items, or with a 304 (Not Modified) status, are empty-handed.
+
+Relaxation conditions are composable; for example,
+
+	polling.WithCondition(polling.And(
+		polling.Or(polling.ConsecutiveEmpty(3), polling.OnError()),
+		polling.Not(polling.DailyWindow(9*time.Hour, 17*time.Hour, location))))
+
+configures a poller to back off after 3 consecutive empty-handed calls or
+after a failed one, but never during business hours.
+
+Failed calls can be handled separately: WithErrorBackoff configures a backoff
+for them, WithMaxConsecutiveErrors stops pollers after too many of them in a row,
+and WithErrorHandler reports them. Receivers can also stop their pollers by
+failing with fatal errors (see RetryableError and NewFatalError); the error that
+stops a poller is returned by its Run and Err methods.
+
+To drain busy resources faster, WithWorkers configures a poller to run several
+receive loops that feed its channel, and to scale their number between a minimum
+and a maximum depending on how often they find payloads.
+
+For at-least-once processing, an InFlightTracker wraps a receiver so that its
+payloads arrive as Messages, which consumers acknowledge (Ack) once processed
+or reject (Nack) to have them redelivered later; messages that aren't
+acknowledged within the visibility timeout are redelivered, too, until they've
+been delivered the maximum number of times and go to a dead-letter sink.
+Receivers that acknowledge messages natively (AckReceivers) are polled through
+NewMessageReceiver, whose payloads are their Messages.
+InMemoryQueue is a receiver (and a dead-letter sink) that's handy for tests.
+
+Pollers keep statistics (calls, found/empty/failed ones, time spent relaxing,
+calls saved versus busy-waiting, etc.) that their Stats method returns;
+a Registry of pollers serves all their stats as JSON over HTTP. For example,
+
+	registry := polling.NewRegistry()
+	registry.Register(poller)
+	http.Handle("/pollers", registry.Handler())
+
+Running pollers can be controlled, too (e.g., by operators during incidents):
+Pause and Resume suspend and resume calls to the receiver, PollNow cuts short
+any relaxation in progress to drain a backlog, and Reconfigure changes how
+a poller relaxes; for example,
+
+	poller.Reconfigure(polling.WithBernoulliExponentialBackoff(0.5, time.Second, 10*time.Second))
+
+changes the probability, seed, and cap of a Bernoulli exponential backoff.
+
+Pollers tell time and relax using the real clock by default; WithClock
+configures another one (e.g., a fake clock from the testutil package, which
+makes tests deterministic), and WithRandom configures the source of random
+numbers for Bernoulli trials.
 */
 package polling
@@ -0,0 +1,76 @@
+package polling
+
+import (
+	"fmt"
+)
+
+// RetryableError is implemented by errors that tell whether or not it's worth
+// calling a receiver again after a call fails with them. Pollers stop when
+// calls fail with errors that aren't retryable (i.e., fatal errors) and keep
+// polling after other errors.
+type RetryableError interface {
+	error
+
+	// Retryable determines whether or not it's worth calling again.
+	Retryable() bool
+}
+
+// NewFatalError wraps the specified error as a RetryableError that isn't
+// retryable; receivers return such errors to stop their pollers.
+func NewFatalError(err error) error {
+	return &fatalError{err}
+}
+
+type fatalError struct {
+	error
+}
+
+func (*fatalError) Retryable() bool {
+	return false
+}
+
+func (err *fatalError) Unwrap() error {
+	return err.error
+}
+
+// TerminalError represents the error that stopped a poller: either a fatal
+// error (see RetryableError) or the last of too many consecutive errors (see
+// WithMaxConsecutiveErrors).
+type TerminalError struct {
+	// Poller is the name of the poller.
+	Poller string
+
+	// ConsecutiveErrors is the number of consecutive calls that failed.
+	ConsecutiveErrors int
+
+	// Err is the error that the last call failed with.
+	Err error
+}
+
+func (err *TerminalError) Error() string {
+	if !isRetryable(err.Err) {
+		return fmt.Sprintf("poller %s stopped after a fatal error: %v", err.Poller, err.Err)
+	}
+	return fmt.Sprintf("poller %s stopped after %d consecutive errors: %v", err.Poller, err.ConsecutiveErrors, err.Err)
+}
+
+// Unwrap returns the error that the last call failed with.
+func (err *TerminalError) Unwrap() error {
+	return err.Err
+}
+
+// isRetryable determines whether the specified error, or the first error in
+// its chain of wrapped errors that's a RetryableError, is retryable.
+func isRetryable(err error) bool {
+	for err != nil {
+		if retryableError, ok := err.(RetryableError); ok {
+			return retryableError.Retryable()
+		}
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = wrapper.Unwrap()
+	}
+	return true
+}
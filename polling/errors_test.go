@@ -0,0 +1,91 @@
+package polling
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+type temporaryError struct {
+	retryable bool
+}
+
+func (*temporaryError) Error() string {
+	return "temporary"
+}
+
+func (err *temporaryError) Retryable() bool {
+	return err.retryable
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		id       string
+		err      error
+		expected bool
+	}{
+		{"unclassified", errors.New("failed"), true},
+		{"retryable", &temporaryError{true}, true},
+		{"not retryable", &temporaryError{false}, false},
+		{"fatal", NewFatalError(errors.New("failed")), false},
+		{"wrapped fatal", &wrappingError{NewFatalError(errors.New("failed"))}, false},
+		{"wrapped unclassified", &wrappingError{errors.New("failed")}, true},
+	}
+
+	for _, c := range cases {
+		assert.For(t, c.id).ThatActual(isRetryable(c.err)).Equals(c.expected)
+	}
+}
+
+// wrappingError wraps another error, like the ones that fmt.Errorf creates
+// using the %w verb.
+type wrappingError struct {
+	err error
+}
+
+func (err *wrappingError) Error() string {
+	return "wrapped: " + err.err.Error()
+}
+
+func (err *wrappingError) Unwrap() error {
+	return err.err
+}
+
+func TestNewFatalError(t *testing.T) {
+	errFailed := errors.New("failed")
+	err := NewFatalError(errFailed)
+	assert.For(t).ThatActualString(err.Error()).Equals("failed")
+	if fatalError, ok := err.(*fatalError); assert.For(t).ThatActual(ok).IsTrue().Passed() {
+		assert.For(t).ThatActual(fatalError.Unwrap()).Equals(errFailed)
+	}
+}
+
+func TestTerminalError_Error(t *testing.T) {
+	cases := []struct {
+		id       string
+		err      error
+		expected string
+	}{
+		{"consecutive errors", errors.New("failed"), "poller calls stopped after 3 consecutive errors: failed"},
+		{"fatal", NewFatalError(errors.New("failed")), "poller calls stopped after a fatal error: failed"},
+		{"not retryable", &temporaryError{false}, "poller calls stopped after a fatal error: temporary"},
+	}
+
+	for _, c := range cases {
+		err := &TerminalError{Poller: "calls", ConsecutiveErrors: 3, Err: c.err}
+		assert.For(t, c.id).ThatActualString(err.Error()).Equals(c.expected)
+	}
+}
+
+func TestTerminalError_Unwrap(t *testing.T) {
+	errFatal := NewFatalError(errors.New("failed"))
+	err := &TerminalError{Poller: "calls", ConsecutiveErrors: 1, Err: errFatal}
+	assert.For(t).ThatActual(err.Unwrap()).Equals(errFatal)
+	switch unwrapped := err.Unwrap().(type) {
+	case RetryableError:
+		assert.For(t).ThatActual(unwrapped.Retryable()).IsFalse()
+	default:
+		t.Errorf("unwrapped error %v is not a RetryableError", unwrapped)
+	}
+}
@@ -0,0 +1,52 @@
+package polling
+
+import (
+	"sync"
+)
+
+// InMemoryQueue represents a FIFO queue of payloads that's safe for
+// concurrent use. It's a Receiver (e.g., to test pollers, and with
+// an InFlightTracker, consumers that acknowledge messages) and
+// a DeadLetterSink of the payloads of dead letters.
+type InMemoryQueue struct {
+	mutex    sync.Mutex
+	payloads []interface{}
+}
+
+// NewInMemoryQueue creates a queue with the specified payloads.
+func NewInMemoryQueue(payloads ...interface{}) *InMemoryQueue {
+	return &InMemoryQueue{payloads: payloads}
+}
+
+// Send appends the specified payloads to the queue.
+func (queue *InMemoryQueue) Send(payloads ...interface{}) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	queue.payloads = append(queue.payloads, payloads...)
+}
+
+// Receive removes the first payload from the queue and returns it, if any.
+func (queue *InMemoryQueue) Receive() (interface{}, bool, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if len(queue.payloads) == 0 {
+		return nil, false, nil
+	}
+	payload := queue.payloads[0]
+	queue.payloads[0] = nil
+	queue.payloads = queue.payloads[1:]
+	return payload, true, nil
+}
+
+// Len returns the number of payloads in the queue.
+func (queue *InMemoryQueue) Len() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return len(queue.payloads)
+}
+
+// PutDeadLetter appends the payload of the specified message to the queue.
+func (queue *InMemoryQueue) PutDeadLetter(message Message) error {
+	queue.Send(message.Payload())
+	return nil
+}
@@ -0,0 +1,25 @@
+package polling
+
+import (
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestInMemoryQueue(t *testing.T) {
+	queue := NewInMemoryQueue("a")
+	queue.Send("b", "c")
+	assert.For(t).ThatActual(queue.Len()).Equals(3)
+	for _, expected := range []string{"a", "b", "c"} {
+		payload, found, err := queue.Receive()
+		assert.For(t, expected).ThatActual(payload).Equals(expected)
+		assert.For(t, expected).ThatActual(found).IsTrue()
+		assert.For(t, expected).ThatActual(err).IsNil()
+	}
+
+	payload, found, err := queue.Receive()
+	assert.For(t).ThatActual(payload).IsNil()
+	assert.For(t).ThatActual(found).IsFalse()
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(queue.Len()).Equals(0)
+}
@@ -0,0 +1,199 @@
+package polling
+
+import (
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/gooseberry/clock"
+)
+
+const (
+	defaultName           = "poller"
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = time.Minute
+
+	defaultScalingInterval = 10 * time.Second
+)
+
+// Option configures pollers created by NewPoller.
+type Option func(*config)
+
+type config struct {
+	name                 string
+	condition            Condition
+	backoff              backoff.Backoff
+	errorBackoff         backoff.Backoff
+	resetOnFound         bool
+	maxConsecutiveErrors int
+	errorHandler         func(error)
+	minWorkers           int
+	maxWorkers           int
+	scalingInterval      time.Duration
+	bufferSize           int
+	clock                clock.Clock
+	random               func() float64
+	bernoulliSampler     *BernoulliSampler
+	bernoulliCondition   Condition // the condition that uses bernoulliSampler
+	budget               *CallBudget
+}
+
+func newConfig(options []Option) *config {
+	config := &config{
+		name:            defaultName,
+		condition:       Or(EmptyHanded(), OnError()),
+		backoff:         backoff.NewExponential(defaultInitialBackoff, defaultMaxBackoff),
+		minWorkers:      1,
+		maxWorkers:      1,
+		scalingInterval: defaultScalingInterval,
+		clock:           clock.New(),
+	}
+	config.apply(options)
+	return config
+}
+
+func (config *config) apply(options []Option) {
+	for _, option := range options {
+		option(config)
+	}
+	if config.random != nil && config.bernoulliSampler != nil && config.condition == config.bernoulliCondition {
+		// replaced rather than modified, as workers may be using the sampler
+		config.setBernoulliSampler(Bernoulli(config.bernoulliSampler.probability).WithRandom(config.random))
+	}
+}
+
+// setBernoulliSampler configures the poller to relax after calls that are
+// empty-handed or fail, subject to the specified sampler's trials.
+func (config *config) setBernoulliSampler(sampler *BernoulliSampler) {
+	config.bernoulliSampler = sampler
+	config.condition = And(Or(EmptyHanded(), OnError()), sampler)
+	config.bernoulliCondition = config.condition
+}
+
+// WithName configures the name of the poller ("poller" by default).
+func WithName(name string) Option {
+	return func(config *config) {
+		config.name = name
+	}
+}
+
+// WithCondition configures the condition that determines whether or not
+// the poller relaxes after a call to its receiver. By default, the poller
+// relaxes after every call that's empty-handed or fails.
+func WithCondition(condition Condition) Option {
+	return func(config *config) {
+		config.condition = condition
+	}
+}
+
+// WithBackoff configures the backoff that determines how long the poller
+// relaxes (see WithBackoffResetOnFound). By default, the poller uses
+// an exponential backoff that starts with 100ms and saturates at 1 minute.
+func WithBackoff(b backoff.Backoff) Option {
+	return func(config *config) {
+		config.backoff = b
+	}
+}
+
+// WithBackoffResetOnFound configures the poller to reset its backoff whenever
+// a payload is found, so that it relaxes for the shortest delays again once
+// payloads stop arriving. By default, the backoff isn't reset, and it carries
+// on from where it was (e.g., the cyclic exponential backoff configured by
+// WithBernoulliExponentialBackoff keeps cycling).
+func WithBackoffResetOnFound() Option {
+	return func(config *config) {
+		config.resetOnFound = true
+	}
+}
+
+// WithErrorBackoff configures the backoff that determines how long the poller
+// relaxes after failed calls, regardless of its relaxation condition; it's
+// reset after every call that doesn't fail. By default, failed calls are
+// treated like empty-handed ones.
+func WithErrorBackoff(b backoff.Backoff) Option {
+	return func(config *config) {
+		config.errorBackoff = b
+	}
+}
+
+// WithMaxConsecutiveErrors configures the number of consecutive failed calls
+// after which the poller stops with a TerminalError. By default, the poller
+// stops only on fatal errors (see RetryableError).
+func WithMaxConsecutiveErrors(count int) Option {
+	return func(config *config) {
+		config.maxConsecutiveErrors = count
+	}
+}
+
+// WithErrorHandler configures a function to call with the error of every
+// failed call; it's called by the goroutine that polls, before it relaxes.
+func WithErrorHandler(handler func(error)) Option {
+	return func(config *config) {
+		config.errorHandler = handler
+	}
+}
+
+// WithWorkers configures the poller to run between min and max concurrent
+// receive loops (workers) that feed its channel, each with its own backoff
+// state; the receiver must be safe for concurrent use if max is more than 1.
+// The poller starts with min workers and adds (or retires) one at a time,
+// at the scaling interval (see WithScalingInterval), when most calls since
+// the last scaling decision found (or did not find) payloads. By default,
+// the poller runs a single worker.
+func WithWorkers(min, max int) Option {
+	return func(config *config) {
+		config.minWorkers, config.maxWorkers = min, max
+	}
+}
+
+// WithScalingInterval configures the interval at which the poller decides
+// whether or not to scale its workers (10 seconds by default).
+func WithScalingInterval(interval time.Duration) Option {
+	return func(config *config) {
+		config.scalingInterval = interval
+	}
+}
+
+// WithBufferSize configures the capacity of the poller's channel, which is
+// unbuffered by default.
+func WithBufferSize(size int) Option {
+	return func(config *config) {
+		config.bufferSize = size
+	}
+}
+
+// WithBernoulliExponentialBackoff configures the poller to use Bernoulli
+// trials with cyclic exponential backoff between empty-handed calls; see
+// NewBernoulliExponentialBackoffPoller.
+func WithBernoulliExponentialBackoff(probability float64, seed, cap time.Duration) Option {
+	return func(config *config) {
+		config.setBernoulliSampler(Bernoulli(probability))
+		config.backoff = backoff.NewCyclicExponential(seed, cap)
+	}
+}
+
+// WithCallBudget configures the budget of calls that the poller makes to its
+// receiver; pollers that share a budget share its cap. By default, calls
+// aren't capped.
+func WithCallBudget(budget *CallBudget) Option {
+	return func(config *config) {
+		config.budget = budget
+	}
+}
+
+// WithClock configures the clock that the poller uses to tell time and to
+// relax (e.g., a fake one in tests); by default, it uses the real clock.
+func WithClock(clock clock.Clock) Option {
+	return func(config *config) {
+		config.clock = clock
+	}
+}
+
+// WithRandom configures the source of random numbers in [0, 1) for
+// the Bernoulli trials configured by WithBernoulliExponentialBackoff
+// (e.g., a seeded *rand.Rand's Float64 method, guarded by a mutex if
+// the poller has several workers); see BernoulliSampler.WithRandom.
+func WithRandom(random func() float64) Option {
+	return func(config *config) {
+		config.random = random
+	}
+}
@@ -1,9 +1,26 @@
 package polling
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/clock"
+	"github.com/voicera/gooseberry/validate"
+	"go.uber.org/atomic"
+)
+
+const (
+	drainCheckInterval = 10 * time.Millisecond
+)
+
+var (
+	errAlreadyStarted     = errors.New("poller has already started")
+	errNilReceiver        = errors.New("receiver is nil")
+	errNegativeBufferSize = errors.New("buffer size is negative")
+	errInvalidWorkerRange = errors.New("workers must be at least 1, and max workers must be at least min workers")
 )
 
 // Poller represents the resource being polled as a send-only channel.
@@ -16,13 +33,67 @@ type Poller interface {
 	// GetName returns name of the poller.
 	GetName() string
 
-	// Start starts polling for new payloads to arrive on the receiving end.
-	// This method is non-idempotent.
-	Start()
+	// GetLastSuccessfulReceiveTime returns the time of the last call to
+	// the receiver that did not fail (whether or not it was empty-handed),
+	// or the zero time if there's none; it's useful to check liveness.
+	GetLastSuccessfulReceiveTime() time.Time
+
+	// Start starts polling for new payloads to arrive on the receiving end
+	// in a new goroutine, until the specified context is done or Stop is
+	// called; see Run. This method is non-idempotent.
+	Start(ctx context.Context)
+
+	// Run polls for new payloads to arrive on the receiving end until
+	// the specified context is done, Stop is called, or a call to the receiver
+	// fails with a terminal error, and then closes the channel. It returns
+	// the context's error if the context is done, nil if the poller was
+	// stopped, or the terminal error (see Err). This method is
+	// non-idempotent; it fails if the poller has already started.
+	Run(ctx context.Context) error
 
-	// Stop signals the poller to stop polling; it also closes the channel.
-	// This method is non-idempotent.
+	// Stop signals the poller to stop polling, which cancels the context
+	// passed to context-aware receivers (see ContextReceiver) and cuts short
+	// any relaxation; the channel is closed once the poller stops (see Done).
+	// This method is idempotent and does not block; it may be called before
+	// the poller starts, in which case the poller stops as soon as it starts.
 	Stop()
+
+	// Done returns a channel that's closed after the poller stops
+	// and closes its channel.
+	Done() <-chan struct{}
+
+	// Err returns the *TerminalError that stopped the poller, if any.
+	Err() error
+
+	// Stats returns a snapshot of the poller's statistics.
+	Stats() Stats
+
+	// Pause signals the poller to stop calling its receiver until Resume is
+	// called; calls that are in progress are not interrupted. This method is
+	// idempotent and does not block.
+	Pause()
+
+	// Resume signals a paused poller to resume calling its receiver.
+	// This method is idempotent and does not block.
+	Resume()
+
+	// PollNow cuts short any relaxation in progress, so that the receiver is
+	// called again as soon as possible (unless the poller is paused); e.g., to
+	// drain a backlog without waiting for the backoff.
+	PollNow()
+
+	// Reconfigure changes how a (possibly running) poller relaxes; only
+	// the options that configure relaxation (WithCondition, WithBackoff,
+	// WithErrorBackoff, WithBackoffResetOnFound, WithBernoulliExponentialBackoff,
+	// and WithRandom) take effect, and the rest are ignored; the options that
+	// aren't specified keep their current values. Workers switch to the new
+	// configuration, with a fresh backoff state, before they relax next.
+	Reconfigure(options ...Option) error
+
+	// WaitUntilDrained waits until the poller stops and the payloads that are
+	// buffered in its channel (if any) are received, or until the specified
+	// context is done, in which case it returns the context's error.
+	WaitUntilDrained(ctx context.Context) error
 }
 
 // Receiver represents the simple act of receiving payloads (e.g., messages)
@@ -34,37 +105,131 @@ type Receiver interface {
 	Receive() (interface{}, bool, error)
 }
 
+// ContextReceiver represents receivers whose calls can be canceled. Pollers
+// call ReceiveContext instead of Receive on receivers that implement it,
+// passing a context that's canceled when the poller stops.
+type ContextReceiver interface {
+	// ReceiveContext receives like Receiver.Receive does; it should return
+	// as soon as possible once the specified context is done.
+	ReceiveContext(ctx context.Context) (interface{}, bool, error)
+}
+
+// ContextReceiverFunc is an adapter to use a function as a Receiver that
+// implements ContextReceiver.
+type ContextReceiverFunc func(ctx context.Context) (interface{}, bool, error)
+
+// Receive calls the function with a background context.
+func (f ContextReceiverFunc) Receive() (interface{}, bool, error) {
+	return f(context.Background())
+}
+
+// ReceiveContext calls the function with the specified context.
+func (f ContextReceiverFunc) ReceiveContext(ctx context.Context) (interface{}, bool, error) {
+	return f(ctx)
+}
+
+type contextIgnoringReceiver struct {
+	Receiver
+}
+
+func (receiver *contextIgnoringReceiver) ReceiveContext(context.Context) (interface{}, bool, error) {
+	return receiver.Receive()
+}
+
+func toContextReceiver(receiver Receiver) ContextReceiver {
+	if contextReceiver, ok := receiver.(ContextReceiver); ok {
+		return contextReceiver
+	}
+	return &contextIgnoringReceiver{receiver}
+}
+
 type pollingChannel struct {
-	name     string
-	receiver Receiver
-	data     chan interface{}
-	signal   chan interface{} // can be user later to send commands (like relax)
-	relaxer  relaxer
+	name                      string
+	receiver                  ContextReceiver
+	data                      chan interface{}
+	stop                      chan struct{}
+	stopOnce                  sync.Once
+	done                      chan struct{}
+	started                   *atomic.Bool
+	mutex                     sync.Mutex
+	config                    *config // guarded by mutex; only its relaxation options change
+	configurations            *atomic.Uint64
+	resumed                   chan struct{}                  // guarded by mutex; nil unless paused
+	wakes                     map[*worker]context.CancelFunc // guarded by mutex; of relaxing workers
+	wakeups                   *atomic.Uint64
+	minWorkers                int
+	maxWorkers                int
+	scalingInterval           time.Duration
+	workers                   *atomic.Int64
+	calls                     *atomic.Uint64 // since the last scaling decision
+	foundCalls                *atomic.Uint64 // since the last scaling decision
+	maxConsecutiveErrors      int
+	errorHandler              func(error)
+	terminalError             atomic.Value // of *TerminalError
+	terminalErrorOnce         sync.Once
+	lastSuccessfulReceiveTime *atomic.Int64 // in Unix nanoseconds
+	stats                     *statistics
+	budget                    *CallBudget
+	clock                     clock.Clock
 }
 
-// NewBernoulliExponentialBackoffPoller creates a polling channel that uses
-// Bernoulli trials with cyclic exponential backoff between empty-handed calls.
-func NewBernoulliExponentialBackoffPoller(
-	receiver Receiver, entityName string, probability float64, seed, cap time.Duration) (Poller, error) {
-	sampler, err := newBernoulliSampler(probability)
-	if err != nil {
+// NewPoller creates a poller that polls the specified receiver as configured
+// by the specified options.
+func NewPoller(receiver Receiver, options ...Option) (Poller, error) {
+	if receiver == nil {
+		return nil, validate.NewValidationError(errNilReceiver, "receiver")
+	}
+	config := newConfig(options)
+	if config.bufferSize < 0 {
+		return nil, validate.NewValidationError(errNegativeBufferSize, "bufferSize")
+	}
+	if config.minWorkers < 1 || config.maxWorkers < config.minWorkers {
+		return nil, validate.NewValidationError(errInvalidWorkerRange, "workers")
+	}
+	if err := validateCondition(config.condition); err != nil {
+		return nil, err
+	}
+	if err := validateBackoff(config.backoff); err != nil {
 		return nil, err
 	}
+	if config.budget != nil {
+		if err := config.budget.validate(); err != nil {
+			return nil, err
+		}
+	}
 	return &pollingChannel{
-		name:     entityName,
-		data:     make(chan interface{}),
-		signal:   make(chan interface{}),
-		receiver: receiver,
-		relaxer: &cyclicExponentialBackoffRelaxer{
-			relaxationCondition:    &and{[]relaxationCondition{&emptyHanded{}, sampler}},
-			initialBackoffDuration: seed,
-			currentBackoffDuration: seed,
-			exponentialBackoffCap:  cap,
-			sleep: time.Sleep,
-		},
+		name:                      config.name,
+		data:                      make(chan interface{}, config.bufferSize),
+		stop:                      make(chan struct{}),
+		done:                      make(chan struct{}),
+		started:                   atomic.NewBool(false),
+		receiver:                  toContextReceiver(receiver),
+		config:                    config,
+		configurations:            atomic.NewUint64(0),
+		wakes:                     map[*worker]context.CancelFunc{},
+		wakeups:                   atomic.NewUint64(0),
+		minWorkers:                config.minWorkers,
+		maxWorkers:                config.maxWorkers,
+		scalingInterval:           config.scalingInterval,
+		workers:                   atomic.NewInt64(0),
+		calls:                     atomic.NewUint64(0),
+		foundCalls:                atomic.NewUint64(0),
+		maxConsecutiveErrors:      config.maxConsecutiveErrors,
+		errorHandler:              config.errorHandler,
+		lastSuccessfulReceiveTime: atomic.NewInt64(0),
+		stats:                     newStatistics(),
+		budget:                    config.budget,
+		clock:                     config.clock,
 	}, nil
 }
 
+// NewBernoulliExponentialBackoffPoller creates a polling channel that uses
+// Bernoulli trials with cyclic exponential backoff between empty-handed calls.
+func NewBernoulliExponentialBackoffPoller(
+	receiver Receiver, entityName string, probability float64, seed, cap time.Duration) (Poller, error) {
+	return NewPoller(receiver, WithName(entityName), WithBernoulliExponentialBackoff(probability, seed, cap))
+}
+
 func (pc *pollingChannel) Channel() <-chan interface{} {
 	return pc.data
 }
@@ -73,40 +238,202 @@ func (pc *pollingChannel) GetName() string {
 	return pc.name
 }
 
-func (pc *pollingChannel) Start() {
+func (pc *pollingChannel) GetLastSuccessfulReceiveTime() time.Time {
+	if nanoseconds := pc.lastSuccessfulReceiveTime.Load(); nanoseconds != 0 {
+		return time.Unix(0, nanoseconds)
+	}
+	return time.Time{}
+}
+
+func (pc *pollingChannel) Start(ctx context.Context) {
+	go func() {
+		if err := pc.Run(ctx); err == errAlreadyStarted {
+			gooseberry.Logger.Error(err.Error(), "poller", pc.name)
+		}
+	}()
+}
+
+func (pc *pollingChannel) Run(parent context.Context) error {
+	if !pc.started.CAS(false, true) {
+		return errAlreadyStarted
+	}
 	gooseberry.Logger.Debug("Started", "poller", pc.name)
 	defer gooseberry.Logger.Debug("Stopped", "poller", pc.name)
+	defer close(pc.done)
 	defer close(pc.data)
 
-	for {
-		if payload, found, err := pc.receiver.Receive(); found && err == nil {
-			select {
-			case pc.data <- payload:
-				pc.relax(found)
-			case <-pc.signal:
-				return
-			}
-		} else {
-			if err != nil {
-				gooseberry.Logger.Error(err.Error(), "poller", pc.name, "err", err)
-			}
-
-			select {
-			case <-pc.signal:
-				return
-			default:
-				pc.relax(false)
-			}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	go func() {
+		select {
+		case <-pc.stop:
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
+
+	pool := &workerPool{pollingChannel: pc, ctx: ctx, cancel: cancel}
+	for i := 0; i < pc.minWorkers; i++ {
+		pool.add()
+	}
+	if pc.maxWorkers > pc.minWorkers {
+		pool.scale()
+	}
+	<-ctx.Done()
+	pool.wait()
+
+	if err := pc.Err(); err != nil {
+		return err
+	}
+	return parent.Err()
+}
+
+// handleError logs and reports the error of a failed call; it returns
+// a terminal error if the poller should stop.
+func (pc *pollingChannel) handleError(receipt *Receipt) error {
+	gooseberry.Logger.Error(receipt.Err.Error(), "poller", pc.name, "err", receipt.Err)
+	if pc.errorHandler != nil {
+		pc.errorHandler(receipt.Err)
+	}
+	if isRetryable(receipt.Err) &&
+		(pc.maxConsecutiveErrors <= 0 || receipt.ConsecutiveErrors < pc.maxConsecutiveErrors) {
+		return nil
 	}
+
+	terminalError := &TerminalError{Poller: pc.name, ConsecutiveErrors: receipt.ConsecutiveErrors, Err: receipt.Err}
+	pc.terminalErrorOnce.Do(func() {
+		pc.terminalError.Store(terminalError)
+		gooseberry.Logger.Error("Stopping due to a terminal error", "poller", pc.name, "err", terminalError)
+	})
+	return terminalError
 }
 
 func (pc *pollingChannel) Stop() {
-	gooseberry.Logger.Debug("Stopping", "poller", pc.name)
-	pc.signal <- 0
+	pc.stopOnce.Do(func() {
+		gooseberry.Logger.Debug("Stopping", "poller", pc.name)
+		close(pc.stop)
+	})
+}
+
+func (pc *pollingChannel) Done() <-chan struct{} {
+	return pc.done
+}
+
+func (pc *pollingChannel) Err() error {
+	if terminalError, ok := pc.terminalError.Load().(*TerminalError); ok {
+		return terminalError
+	}
+	return nil
 }
 
-func (pc *pollingChannel) relax(lastReceiptSucceeded bool) {
-	gooseberry.Logger.Debug("Relaxing", "poller", pc.name)
-	pc.relaxer.relax(lastReceiptSucceeded)
+func (pc *pollingChannel) Stats() Stats {
+	stats := Stats{
+		Name:                      pc.name,
+		Workers:                   int(pc.workers.Load()),
+		LastSuccessfulReceiveTime: pc.GetLastSuccessfulReceiveTime(),
+	}
+	pc.mutex.Lock()
+	stats.Paused = pc.resumed != nil
+	pc.mutex.Unlock()
+	if pc.budget != nil {
+		budgetStats := pc.budget.Stats()
+		stats.Budget = &budgetStats
+	}
+	pc.stats.snapshot(&stats)
+	return stats
+}
+
+func (pc *pollingChannel) WaitUntilDrained(ctx context.Context) error {
+	select {
+	case <-pc.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for len(pc.data) > 0 {
+		select {
+		case <-pc.clock.After(drainCheckInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (pc *pollingChannel) Pause() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.resumed == nil {
+		gooseberry.Logger.Debug("Pausing", "poller", pc.name)
+		pc.resumed = make(chan struct{})
+	}
+}
+
+func (pc *pollingChannel) Resume() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	if pc.resumed != nil {
+		gooseberry.Logger.Debug("Resuming", "poller", pc.name)
+		close(pc.resumed)
+		pc.resumed = nil
+	}
+}
+
+func (pc *pollingChannel) PollNow() {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	pc.wakeups.Inc()
+	for _, wake := range pc.wakes {
+		wake()
+	}
+}
+
+func (pc *pollingChannel) Reconfigure(options ...Option) error {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	config := *pc.config
+	config.apply(options)
+	if err := validateCondition(config.condition); err != nil {
+		return err
+	}
+	if err := validateBackoff(config.backoff); err != nil {
+		return err
+	}
+	pc.config = &config
+	pc.configurations.Inc()
+	gooseberry.Logger.Debug("Reconfigured", "poller", pc.name)
+	return nil
+}
+
+// waitUntilResumed waits until the poller isn't paused; it returns false if
+// the specified context is done first.
+func (pc *pollingChannel) waitUntilResumed(ctx context.Context) bool {
+	pc.mutex.Lock()
+	resumed := pc.resumed
+	pc.mutex.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newRelaxer creates a relaxer with its own backoff state, and returns it
+// along with the number of the configuration it's created from.
+func (pc *pollingChannel) newRelaxer() (relaxer, uint64) {
+	pc.mutex.Lock()
+	defer pc.mutex.Unlock()
+	relaxer := &backoffRelaxer{
+		condition:    pc.config.condition,
+		backoff:      pc.config.backoff.Clone(),
+		resetOnFound: pc.config.resetOnFound,
+	}
+	if pc.config.errorBackoff != nil {
+		relaxer.errorBackoff = pc.config.errorBackoff.Clone()
+	}
+	return relaxer, pc.configurations.Load()
 }
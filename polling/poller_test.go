@@ -1,12 +1,21 @@
 package polling
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
+	"testing"
 	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/tester/assert"
 )
 
+var errFound = errors.New("found") // denotes that a payload was found in scripts of receipts
+
 type counter struct{ count int }
 type alwaysEmptyHandedReceiver counter
 type neverEmptyHandedReceiver counter
@@ -34,10 +43,10 @@ func (receiver *threeSidedDieReceiver) Receive() (interface{}, bool, error) {
 }
 
 func ExamplePoller() {
-	rand.Seed(0) // to produce the same sequence of pseudo-random numbers every time
 	receivers := []Receiver{&alwaysEmptyHandedReceiver{}, &neverEmptyHandedReceiver{}, &threeSidedDieReceiver{}}
 
 	for r, receiver := range receivers {
+		rand.Seed(0) // to produce the same sequence of pseudo-random numbers every time
 		poller, err := NewBernoulliExponentialBackoffPoller(
 			receiver, "test", 0.5, time.Nanosecond, time.Millisecond)
 		if err != nil {
@@ -45,13 +54,14 @@ func ExamplePoller() {
 		}
 		payloads := []interface{}{}
 
-		go poller.Start()
+		poller.Start(context.Background())
 
 		for i := 0; r > 0 && i < 13; i++ {
 			payloads = append(payloads, <-poller.Channel())
 		}
 
 		poller.Stop()
+		<-poller.Done()
 		_, receiving := <-poller.Channel()
 		fmt.Printf("Stopped; is channel receiving from %T? %t\n", receiver, receiving)
 		fmt.Println("Payloads received:", payloads)
@@ -67,3 +77,324 @@ func ExamplePoller() {
 	// Stopped; is channel receiving from *polling.threeSidedDieReceiver? false
 	// Payloads received: [4 6 7 9 15 17 19 20 21 25 31 38 44]
 }
+
+func TestPoller_stop(t *testing.T) {
+	poller, _ := NewBernoulliExponentialBackoffPoller(
+		&neverEmptyHandedReceiver{}, "test", 0.5, time.Nanosecond, time.Millisecond)
+	poller.Stop() // before starting
+	poller.Stop() // again
+	err := poller.Run(context.Background())
+	assert.For(t).ThatActual(err).IsNil()
+	_, receiving := <-poller.Channel()
+	assert.For(t).ThatActual(receiving).IsFalse()
+	assert.For(t).ThatActual(poller.Run(context.Background())).Equals(errAlreadyStarted)
+}
+
+func TestPoller_cancel(t *testing.T) {
+	receiving := make(chan struct{})
+	receiver := ContextReceiverFunc(func(ctx context.Context) (interface{}, bool, error) {
+		close(receiving)
+		<-ctx.Done() // blocks until canceled
+		return nil, false, ctx.Err()
+	})
+	poller, _ := NewBernoulliExponentialBackoffPoller(receiver, "test", 0.5, time.Hour, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- poller.Run(ctx) }()
+	<-receiving
+	cancel()
+	assert.For(t).ThatActual(<-errs).Equals(context.Canceled)
+	<-poller.Done()
+}
+
+func TestPoller_stopWhileRelaxing(t *testing.T) {
+	poller, _ := NewBernoulliExponentialBackoffPoller(&alwaysEmptyHandedReceiver{}, "test", 1, time.Hour, time.Hour)
+	poller.Start(context.Background())
+	time.Sleep(10 * time.Millisecond) // to start relaxing
+	poller.Stop()
+	select {
+	case <-poller.Done():
+	case <-time.After(time.Second):
+		t.Error("poller did not stop while relaxing")
+	}
+	assert.For(t).ThatActual(poller.GetLastSuccessfulReceiveTime().IsZero()).IsFalse()
+}
+
+func TestPoller_waitUntilDrained(t *testing.T) {
+	pc := &pollingChannel{data: make(chan interface{}, 2), done: make(chan struct{})}
+	pc.data <- 1
+	pc.data <- 2
+	close(pc.data)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.For(t, "not done").ThatActual(pc.WaitUntilDrained(ctx)).Equals(context.DeadlineExceeded)
+
+	close(pc.done)
+	drained := make(chan error)
+	go func() { drained <- pc.WaitUntilDrained(context.Background()) }()
+	for range pc.data {
+	}
+	assert.For(t, "drained").ThatActual(<-drained).IsNil()
+}
+
+func TestNewPoller(t *testing.T) {
+	cases := []struct {
+		id                   string
+		receiver             Receiver
+		options              []Option
+		expectedArgumentName string
+	}{
+		{"nil receiver", nil, nil, "receiver"},
+		{"negative buffer size", &neverEmptyHandedReceiver{}, []Option{WithBufferSize(-1)}, "bufferSize"},
+		{"invalid probability", &neverEmptyHandedReceiver{},
+			[]Option{WithBernoulliExponentialBackoff(2, time.Second, time.Minute)}, "probability"},
+	}
+
+	for _, c := range cases {
+		_, err := NewPoller(c.receiver, c.options...)
+		if assert.For(t, c.id).ThatActual(err).IsNotNil().Passed() {
+			assert.For(t, c.id).ThatActual(err.(*validate.ValidationError).ArgumentName).Equals(c.expectedArgumentName)
+		}
+	}
+}
+
+func TestNewPoller_options(t *testing.T) {
+	recorder := newSleepRecorder()
+	receiver := &threeSidedDieReceiver{}
+	poller, err := NewPoller(receiver,
+		WithName("die"),
+		WithClock(recorder),
+		WithBufferSize(3),
+		WithCondition(Or(EmptyHanded(), OnError())),
+		WithBackoff(backoff.NewLinear(1, 1, 3)))
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+	pc := poller.(*pollingChannel)
+
+	assert.For(t).ThatActualString(poller.GetName()).Equals("die")
+	assert.For(t).ThatActual(cap(pc.data)).Equals(3)
+	poller.Start(context.Background())
+	for i := 0; i < 3; i++ {
+		<-poller.Channel()
+	}
+	poller.Stop()
+	<-poller.Done()
+	for range poller.Channel() { // drains the buffer
+	}
+	assert.For(t).ThatActual(poller.WaitUntilDrained(context.Background())).IsNil()
+	sleeps := recorder.recorded()
+	assert.For(t).ThatActual(len(sleeps) > 0).IsTrue()
+	for _, d := range sleeps {
+		assert.For(t).ThatActual(d >= 1 && d <= 3).IsTrue()
+	}
+}
+
+func TestPoller_consecutiveEmpty(t *testing.T) {
+	script := []error{nil, nil, errors.New("failed"), nil, errFound, nil, nil, nil}
+	var poller Poller
+	receiver := newScriptedReceiver(&poller, script)
+	var relaxedAfterCalls []int
+	recorder := newSleepRecorder()
+	recorder.onSleep = func() { relaxedAfterCalls = append(relaxedAfterCalls, receiver.calls) }
+	poller, _ = NewPoller(receiver, WithCondition(ConsecutiveEmpty(3)), WithBufferSize(len(script)), WithClock(recorder))
+
+	assert.For(t).ThatActual(poller.Run(context.Background())).IsNil()
+	assert.For(t).ThatActual(relaxedAfterCalls).Equals([]int{4, 8}).ThenDiffOnFail()
+}
+
+func TestPoller_errors(t *testing.T) {
+	errFailed := errors.New("failed")
+	errFatal := NewFatalError(errFailed)
+	cases := []struct {
+		id                        string
+		maxConsecutiveErrors      int
+		script                    []error
+		expectedConsecutiveErrors int
+		expectedErr               error
+		expectedHandledErrors     int
+	}{
+		{"budget exhausted", 2, []error{errFailed, nil, errFailed, errFailed, nil}, 2, errFailed, 3},
+		{"budget reset", 2, []error{errFailed, errFound, errFailed, nil, errFailed}, 0, nil, 3},
+		{"fatal", 0, []error{errFailed, errFatal, nil}, 2, errFatal, 2},
+		{"no budget", 0, []error{errFailed, errFailed, errFailed, errFailed}, 0, nil, 4},
+	}
+
+	for _, c := range cases {
+		var poller Poller
+		receiver := newScriptedReceiver(&poller, c.script)
+		var handledErrors []error
+		poller, _ = NewPoller(receiver,
+			WithBufferSize(len(c.script)),
+			WithMaxConsecutiveErrors(c.maxConsecutiveErrors),
+			WithErrorBackoff(backoff.NewConstant(time.Nanosecond)),
+			WithBackoff(backoff.NewConstant(time.Nanosecond)),
+			WithErrorHandler(func(err error) { handledErrors = append(handledErrors, err) }))
+
+		err := poller.Run(context.Background())
+		assert.For(t, c.id).ThatActual(len(handledErrors)).Equals(c.expectedHandledErrors)
+		if c.expectedErr == nil {
+			assert.For(t, c.id).ThatActual(err).IsNil()
+			assert.For(t, c.id).ThatActual(poller.Err()).IsNil()
+			continue
+		}
+		assert.For(t, c.id).ThatActual(poller.Err()).Equals(err)
+		if terminalError, ok := err.(*TerminalError); assert.For(t, c.id).ThatActual(ok).IsTrue().Passed() {
+			assert.For(t, c.id).ThatActual(terminalError.Err).Equals(c.expectedErr)
+			assert.For(t, c.id).ThatActual(terminalError.ConsecutiveErrors).Equals(c.expectedConsecutiveErrors)
+		}
+	}
+}
+
+func TestPoller_pauseAndResume(t *testing.T) {
+	receiver := &neverEmptyHandedReceiver{}
+	poller, _ := NewPoller(receiver)
+	poller.Pause()
+	poller.Pause()
+	poller.Start(context.Background())
+	defer poller.Stop()
+
+	select {
+	case payload := <-poller.Channel():
+		t.Errorf("paused poller received %v", payload)
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.For(t).ThatActual(poller.Stats().Paused).IsTrue()
+
+	poller.Resume()
+	poller.Resume()
+	assert.For(t).ThatActual(<-poller.Channel()).Equals(1)
+	assert.For(t).ThatActual(poller.Stats().Paused).IsFalse()
+}
+
+func TestPoller_stopWhilePaused(t *testing.T) {
+	poller, _ := NewPoller(&neverEmptyHandedReceiver{})
+	poller.Pause()
+	poller.Start(context.Background())
+	poller.Stop()
+	select {
+	case <-poller.Done():
+	case <-time.After(time.Second):
+		t.Error("paused poller did not stop")
+	}
+}
+
+func TestPoller_pollNow(t *testing.T) {
+	calls := make(chan struct{}, 2)
+	receiver := ContextReceiverFunc(func(context.Context) (interface{}, bool, error) {
+		calls <- struct{}{}
+		return nil, false, nil
+	})
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	poller, _ := NewPoller(receiver, WithBackoff(backoff.NewConstant(time.Hour)), WithClock(fake))
+	poller.Start(context.Background())
+	defer poller.Stop()
+
+	<-calls
+	fake.WaitForTimers(1) // waits until relaxing
+	assert.For(t).ThatActual(poller.Stats().CurrentBackoff).Equals(time.Hour)
+	fake.Advance(time.Minute)
+	poller.PollNow()
+	select {
+	case <-calls:
+		assert.For(t).ThatActual(poller.Stats().TimeSlept).Equals(time.Minute)
+	case <-time.After(time.Second):
+		t.Error("poller did not cut its relaxation short")
+	}
+}
+
+func TestPoller_reconfigure(t *testing.T) {
+	var poller Poller
+	calls := 0
+	receiver := ContextReceiverFunc(func(ctx context.Context) (interface{}, bool, error) {
+		switch calls++; calls {
+		case 3:
+			assert.For(t).ThatActual(poller.Reconfigure(WithBackoff(backoff.NewConstant(7)), WithName("ignored"))).IsNil()
+		case 6:
+			poller.Stop()
+			<-ctx.Done()
+		}
+		return nil, false, nil
+	})
+	recorder := newSleepRecorder()
+	poller, _ = NewPoller(receiver, WithBackoff(backoff.NewConstant(1)), WithClock(recorder))
+
+	err := poller.Reconfigure(WithBernoulliExponentialBackoff(2, time.Second, time.Minute))
+	if assert.For(t).ThatActual(err).IsNotNil().Passed() {
+		assert.For(t).ThatActual(err.(*validate.ValidationError).ArgumentName).Equals("probability")
+	}
+	assert.For(t).ThatActual(poller.Run(context.Background())).IsNil()
+	assert.For(t).ThatActual(recorder.recorded()).Equals([]time.Duration{1, 1, 7, 7, 7}).ThenDiffOnFail()
+	assert.For(t).ThatActualString(poller.GetName()).Equals("poller")
+}
+
+func TestPoller_reconfigureKeepsOtherOptions(t *testing.T) {
+	var poller Poller
+	calls := 0
+	receiver := ContextReceiverFunc(func(ctx context.Context) (interface{}, bool, error) {
+		if calls++; calls > 10 {
+			poller.Stop()
+			<-ctx.Done()
+		}
+		return nil, false, nil
+	})
+	recorder := newSleepRecorder()
+	poller, _ = NewPoller(receiver,
+		WithRandom(func() float64 { return 0.75 }), // trials of probabilities up to 0.75 fail
+		WithBernoulliExponentialBackoff(0.25, time.Second, time.Minute),
+		WithClock(recorder))
+
+	assert.For(t).ThatActual(poller.Reconfigure(WithBernoulliExponentialBackoff(0.5, time.Second, time.Minute))).IsNil()
+	assert.For(t).ThatActual(poller.Run(context.Background())).IsNil()
+	assert.For(t).ThatActual(len(recorder.recorded())).Equals(0)
+}
+
+func TestConfig_applyRandomReplacesSampler(t *testing.T) {
+	original := newConfig([]Option{
+		WithRandom(func() float64 { return 0.75 }),
+		WithBernoulliExponentialBackoff(0.5, time.Second, time.Minute),
+	})
+	sampler := original.bernoulliSampler
+	assert.For(t).ThatActual(sampler.ShouldRelax(emptyReceipt)).IsFalse()
+
+	reconfigured := *original
+	reconfigured.apply([]Option{WithRandom(func() float64 { return 0.25 })})
+	assert.For(t).ThatActual(sampler.ShouldRelax(emptyReceipt)).IsFalse() // not modified while in use
+	assert.For(t).ThatActual(reconfigured.bernoulliSampler != sampler).IsTrue()
+	assert.For(t).ThatActual(reconfigured.condition.ShouldRelax(emptyReceipt)).IsTrue()
+
+	condition := EmptyHanded()
+	reconfigured.apply([]Option{WithCondition(condition), WithRandom(func() float64 { return 0.75 })})
+	assert.For(t).ThatActual(reconfigured.condition).Equals(condition)
+}
+
+func TestNewPoller_random(t *testing.T) {
+	cases := []struct {
+		id             string
+		random         float64
+		expectedSleeps int
+	}{
+		{"trials fail", 0.75, 0},
+		{"trials succeed", 0.25, 3},
+	}
+
+	for _, c := range cases {
+		var poller Poller
+		calls := 0
+		receiver := ContextReceiverFunc(func(ctx context.Context) (interface{}, bool, error) {
+			if calls++; calls > 3 {
+				poller.Stop()
+				<-ctx.Done()
+			}
+			return nil, false, nil
+		})
+		recorder := newSleepRecorder()
+		poller, _ = NewPoller(receiver,
+			WithRandom(func() float64 { return c.random }),
+			WithBernoulliExponentialBackoff(0.5, time.Second, time.Minute),
+			WithClock(recorder))
+		assert.For(t, c.id).ThatActual(poller.Run(context.Background())).IsNil()
+		assert.For(t, c.id).ThatActual(len(recorder.recorded())).Equals(c.expectedSleeps)
+	}
+}
@@ -1,30 +1,61 @@
 package polling
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/gooseberry/clock"
+)
 
 // Relaxer optionally causes pollers to relax (instead of busy-waiting)
 // between polls.
 type relaxer interface {
-	// relax optionally causes pollers to relax.
-	relax(lastReceiptSucceeded bool)
+	// delay determines how long to relax after the call with the specified
+	// receipt; a non-positive delay means not to relax.
+	delay(receipt *Receipt) time.Duration
 }
 
-type cyclicExponentialBackoffRelaxer struct {
-	relaxationCondition
-	initialBackoffDuration time.Duration
-	currentBackoffDuration time.Duration
-	exponentialBackoffCap  time.Duration
-	sleep                  func(time.Duration) `test-hook:"verify-unexported"`
+// backoffRelaxer relaxes for the durations of a backoff when its condition is
+// met; the backoff is reset whenever a payload is found, if resetOnFound is
+// set. If it has an error backoff, it relaxes for its durations after failed
+// calls instead. Backoffs that are ReceiptObservers observe every receipt.
+type backoffRelaxer struct {
+	condition    Condition
+	backoff      backoff.Backoff
+	errorBackoff backoff.Backoff
+	resetOnFound bool
 }
 
-func (relaxer *cyclicExponentialBackoffRelaxer) relax(lastReceiptSucceeded bool) {
-	if !relaxer.shouldRelax(lastReceiptSucceeded) {
-		return
+func (relaxer *backoffRelaxer) delay(receipt *Receipt) time.Duration {
+	if observer, ok := relaxer.backoff.(ReceiptObserver); ok {
+		observer.ObserveReceipt(receipt)
+	}
+	if relaxer.errorBackoff != nil {
+		if receipt.Err != nil {
+			return relaxer.errorBackoff.Next()
+		}
+		relaxer.errorBackoff.Reset()
+	}
+	if relaxer.resetOnFound && receipt.succeeded() {
+		relaxer.backoff.Reset()
 	}
+	if !relaxer.condition.ShouldRelax(receipt) {
+		return 0
+	}
+	return relaxer.backoff.Next()
+}
 
-	relaxer.sleep(relaxer.currentBackoffDuration)
-	relaxer.currentBackoffDuration += relaxer.currentBackoffDuration
-	if relaxer.currentBackoffDuration > relaxer.exponentialBackoffCap {
-		relaxer.currentBackoffDuration = relaxer.initialBackoffDuration
+// sleep pauses the current goroutine for the specified duration, as told by
+// the specified clock, or until the specified context is done, whichever
+// comes first; it returns how long it actually slept.
+func sleep(ctx context.Context, clock clock.Clock, duration time.Duration) time.Duration {
+	start := clock.Now()
+	timer := clock.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-ctx.Done():
 	}
+	return clock.Now().Sub(start)
 }
@@ -1,44 +1,172 @@
 package polling
 
 import (
-	"reflect"
+	"context"
 	"testing"
 	"time"
 
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/gooseberry/clock"
+	"github.com/voicera/gooseberry/testutil"
 	"github.com/voicera/tester/assert"
 )
 
+// sleepRecorder is a fake clock whose timers fire immediately, advancing
+// the clock by their durations; it records said durations, which are how long
+// pollers would have slept, and calls onSleep (if any) for each one.
+type sleepRecorder struct {
+	*testutil.FakeClock
+	sleeps  chan time.Duration
+	onSleep func()
+}
+
+func newSleepRecorder() *sleepRecorder {
+	return &sleepRecorder{FakeClock: testutil.NewFakeClock(time.Unix(0, 0)), sleeps: make(chan time.Duration, 1000)}
+}
+
+func (recorder *sleepRecorder) NewTimer(duration time.Duration) clock.Timer {
+	recorder.sleeps <- duration
+	if recorder.onSleep != nil {
+		recorder.onSleep()
+	}
+	if duration > 0 {
+		recorder.Advance(duration)
+	}
+	return recorder.FakeClock.NewTimer(0)
+}
+
+// recorded returns the durations recorded so far.
+func (recorder *sleepRecorder) recorded() []time.Duration {
+	var sleeps []time.Duration
+	for {
+		select {
+		case d := <-recorder.sleeps:
+			sleeps = append(sleeps, d)
+		default:
+			return sleeps
+		}
+	}
+}
+
+// scriptedReceiver follows a script of receipts: errFound yields a payload,
+// nil yields none and any other error fails the call. Once the script runs
+// out, it stops the poller and blocks until its context is done. onCall (if
+// any) is called before each scripted receipt.
+type scriptedReceiver struct {
+	poller *Poller
+	script []error
+	calls  int
+	onCall func()
+}
+
+func newScriptedReceiver(poller *Poller, script []error) *scriptedReceiver {
+	return &scriptedReceiver{poller: poller, script: script}
+}
+
+func (receiver *scriptedReceiver) Receive() (interface{}, bool, error) {
+	return receiver.ReceiveContext(context.Background())
+}
+
+func (receiver *scriptedReceiver) ReceiveContext(ctx context.Context) (interface{}, bool, error) {
+	if receiver.calls++; receiver.calls > len(receiver.script) {
+		(*receiver.poller).Stop()
+		<-ctx.Done()
+		return nil, false, nil
+	}
+	if receiver.onCall != nil {
+		receiver.onCall()
+	}
+	if err := receiver.script[receiver.calls-1]; err == errFound {
+		return receiver.calls, true, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return nil, false, nil
+}
+
 func TestRelax_shouldNotRelax(t *testing.T) {
-	relaxer := cyclicExponentialBackoffRelaxer{
-		relaxationCondition: &passthrough{},
-		sleep:               func(time.Duration) { t.Error("there's no rest for the wicked!") },
+	relaxer := backoffRelaxer{
+		condition: &succeeded{},
+		backoff:   backoff.NewConstant(1),
 	}
-	relaxer.relax(false)
+	assert.For(t).ThatActual(relaxer.delay(emptyReceipt)).Equals(time.Duration(0))
 }
 
 func TestRelax_shouldRelax(t *testing.T) {
-	shifter := uint(0)
-	actualCallCount := 0
-	expectedCallCount := 10
-	relaxer := cyclicExponentialBackoffRelaxer{
-		relaxationCondition:    &passthrough{},
-		initialBackoffDuration: 1,
-		currentBackoffDuration: 1,
-		exponentialBackoffCap:  1000,
-		sleep: func(d time.Duration) {
-			assert.For(t).ThatActual(d).Equals(time.Duration(1 << shifter))
-			shifter = (shifter + 1) % 10
-			actualCallCount++
-		},
+	relaxer := backoffRelaxer{
+		condition: Not(&succeeded{}),
+		backoff:   backoff.NewCyclicExponential(1, 1000),
 	}
 
-	for i := 0; i < expectedCallCount; i++ {
-		relaxer.relax(true)
+	for i := 0; i < 10; i++ {
+		assert.For(t, i).ThatActual(relaxer.delay(emptyReceipt)).Equals(time.Duration(1 << uint(i%10)))
+	}
+}
+
+func TestRelax_resetsBackoffOnSuccess(t *testing.T) {
+	cases := []struct {
+		id                string
+		resetOnFound      bool
+		expectedDurations []time.Duration
+	}{
+		{"reset", true, []time.Duration{1, 2, 4, 1, 2}},
+		{"not reset (default)", false, []time.Duration{1, 2, 4, 8, 16}},
 	}
 
-	assert.For(t).ThatActual(actualCallCount).Equals(expectedCallCount)
+	for _, c := range cases {
+		durations := []time.Duration{}
+		relaxer := backoffRelaxer{
+			condition:    And(),
+			backoff:      backoff.NewExponential(1, 1000),
+			resetOnFound: c.resetOnFound,
+		}
+		for _, receipt := range []*Receipt{emptyReceipt, failedReceipt, emptyReceipt, successfulReceipt, emptyReceipt} {
+			durations = append(durations, relaxer.delay(receipt))
+		}
+		assert.For(t, c.id).ThatActual(durations).Equals(c.expectedDurations).ThenDiffOnFail()
+	}
 }
 
-func TestHooksAreHidden(t *testing.T) {
-	assert.For(t).ThatType(reflect.TypeOf(cyclicExponentialBackoffRelaxer{})).HidesTestHooks()
+func TestSleep(t *testing.T) {
+	fake := testutil.NewFakeClock(time.Unix(0, 0))
+	slept := make(chan struct{})
+	var sleptFor time.Duration
+	go func() {
+		sleptFor = sleep(context.Background(), fake, time.Minute)
+		close(slept)
+	}()
+	fake.WaitForTimers(1)
+	fake.Advance(time.Minute - 1)
+	select {
+	case <-slept:
+		t.Error("woke up too early")
+	default:
+	}
+	fake.Advance(1)
+	<-slept
+	assert.For(t).ThatActual(sleptFor).Equals(time.Minute)
+	assert.For(t).ThatActual(fake.Timers()).Equals(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.For(t).ThatActual(sleep(ctx, fake, time.Minute)).Equals(time.Duration(0))
+	assert.For(t).ThatActual(fake.Timers()).Equals(0)
+}
+
+func TestRelax_errorBackoff(t *testing.T) {
+	durations := []time.Duration{}
+	relaxer := backoffRelaxer{
+		condition:    EmptyHanded(),
+		backoff:      backoff.NewConstant(1),
+		errorBackoff: backoff.NewExponential(10, 1000),
+	}
+
+	receipts := []*Receipt{failedReceipt, failedReceipt, emptyReceipt, failedReceipt, successfulReceipt, failedReceipt}
+	for _, receipt := range receipts {
+		if delay := relaxer.delay(receipt); delay > 0 {
+			durations = append(durations, delay)
+		}
+	}
+
+	assert.For(t).ThatActual(durations).Equals([]time.Duration{10, 20, 1, 10, 10}).ThenDiffOnFail()
 }
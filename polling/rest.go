@@ -0,0 +1,261 @@
+package polling
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry/containers/sets"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+)
+
+const (
+	eTagHeaderKey        = "ETag"
+	ifNoneMatchHeaderKey = "If-None-Match"
+)
+
+// Cursor tracks how far a RESTReceiver has read a resource, so that it
+// fetches new items incrementally.
+type Cursor interface {
+	// Apply returns the URL (e.g., with query parameters added) and
+	// the headers of the request for the items after the cursor, given
+	// the resource's URL.
+	Apply(url string) (string, map[string]string, error)
+
+	// Advance moves the cursor past the items (a slice) of the specified
+	// successful response.
+	Advance(response *http.Response, items interface{})
+}
+
+// RESTReceiver is a Receiver that fetches (GET) a list of items from a REST
+// resource; its payloads are slices of the items that it finds. Requests are
+// made using a cursor, if any, to fetch new items incrementally; responses
+// that are 304 (Not Modified) or that have no (new) items are empty-handed.
+// Items that were already received can be deduplicated (e.g., when the cursor
+// fetches overlapping windows of items).
+//
+// RESTReceiver is configured using its With* methods, which return
+// the modified receiver to allow chaining, before it's used; it's safe for
+// concurrent use, but its calls are serialized to keep the cursor consistent.
+type RESTReceiver struct {
+	mutex     sync.Mutex
+	client    rest.Client
+	url       string
+	newResult func() interface{}
+	items     func(result interface{}) interface{}
+	cursor    Cursor
+	keyOf     func(item interface{}) interface{}
+	received  sets.Set
+}
+
+// NewRESTReceiver creates a receiver that fetches items from the specified URL
+// (relative to the client's base URL, if any) using the specified client.
+// Responses are decoded into the results that newResult creates, which are
+// pointers to slices of items by default (e.g., &[]*call{}); WithItems
+// configures how to get the items out of other results. Cursors that set
+// headers (e.g., NewETagCursor) require clients that support request options
+// (see rest.RequestOptionsDoer), like the ones that package rest creates.
+func NewRESTReceiver(client rest.Client, url string, newResult func() interface{}) *RESTReceiver {
+	return &RESTReceiver{
+		client:    client,
+		url:       url,
+		newResult: newResult,
+		items:     func(result interface{}) interface{} { return reflect.ValueOf(result).Elem().Interface() },
+	}
+}
+
+// WithItems configures the function that gets the items (a slice) out of
+// a decoded result (e.g., out of an envelope like {"calls": [...]}).
+func (receiver *RESTReceiver) WithItems(items func(result interface{}) interface{}) *RESTReceiver {
+	receiver.items = items
+	return receiver
+}
+
+// WithCursor configures the cursor that the receiver uses to fetch new items
+// incrementally; by default, it fetches the whole resource every time.
+func (receiver *RESTReceiver) WithCursor(cursor Cursor) *RESTReceiver {
+	receiver.cursor = cursor
+	return receiver
+}
+
+// WithDeduplication configures the receiver to drop items whose keys are
+// in the specified set, to which the keys of received items are added.
+// The set is typically expirable with a TTL that covers the overlap of
+// fetched windows (e.g., sets.NewExpirableSet(0, time.Hour)).
+func (receiver *RESTReceiver) WithDeduplication(keyOf func(item interface{}) interface{}, received sets.Set) *RESTReceiver {
+	receiver.keyOf, receiver.received = keyOf, received
+	return receiver
+}
+
+// Receive fetches the items after the cursor, if any, and returns the new ones.
+func (receiver *RESTReceiver) Receive() (interface{}, bool, error) {
+	return receiver.ReceiveContext(context.Background())
+}
+
+// ReceiveContext receives like Receive does; the request is aborted when
+// the specified context is canceled (e.g., when the poller stops), unless
+// the client doesn't support request options (see rest.RequestOptionsDoer).
+func (receiver *RESTReceiver) ReceiveContext(ctx context.Context) (interface{}, bool, error) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	url, headers := receiver.url, map[string]string(nil)
+	if receiver.cursor != nil {
+		var err error
+		if url, headers, err = receiver.cursor.Apply(url); err != nil {
+			return nil, false, err
+		}
+	}
+
+	result := receiver.newResult()
+	options := make([]rest.RequestOption, 0, len(headers)+1)
+	for key, value := range headers {
+		options = append(options, rest.WithRequestHeader(key, value))
+	}
+	if _, ok := receiver.client.(rest.RequestOptionsDoer); ok {
+		options = append(options, rest.WithRequestContext(ctx))
+	}
+	response, err := rest.DoWithOptions(receiver.client, http.MethodGet, url, nil, result, options...)
+	if httpError, ok := err.(*web.HTTPError); ok && httpError.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	items := receiver.items(result)
+	if items == nil {
+		return nil, false, nil
+	}
+	if receiver.cursor != nil {
+		receiver.cursor.Advance(response, items)
+	}
+	if receiver.received != nil {
+		items = receiver.deduplicate(items)
+	}
+	if reflect.ValueOf(items).Len() == 0 {
+		return nil, false, nil
+	}
+	return items, true, nil
+}
+
+// deduplicate returns a slice of the items that were not received before,
+// and marks them as received.
+func (receiver *RESTReceiver) deduplicate(items interface{}) interface{} {
+	slice := reflect.ValueOf(items)
+	deduplicated := reflect.MakeSlice(slice.Type(), 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		if key := receiver.keyOf(slice.Index(i).Interface()); !receiver.received.Contains(key) {
+			receiver.received.Add(key)
+			deduplicated = reflect.Append(deduplicated, slice.Index(i))
+		}
+	}
+	return deduplicated.Interface()
+}
+
+// NewSinceCursor creates a cursor that fetches the items whose times are since
+// the latest time of the items fetched so far (or since the specified initial
+// time), by setting the specified query parameter to said time, which is
+// formatted using the specified layout (e.g., time.RFC3339). As items with
+// the latest time are fetched again, the cursor is typically used with
+// deduplication.
+func NewSinceCursor(parameter, layout string, initial time.Time, timeOf func(item interface{}) time.Time) Cursor {
+	return &sinceCursor{parameter: parameter, layout: layout, since: initial, timeOf: timeOf}
+}
+
+type sinceCursor struct {
+	parameter string
+	layout    string
+	since     time.Time
+	timeOf    func(item interface{}) time.Time
+}
+
+func (cursor *sinceCursor) Apply(url string) (string, map[string]string, error) {
+	if cursor.since.IsZero() {
+		return url, nil, nil
+	}
+	url, err := withQueryParameter(url, cursor.parameter, cursor.since.Format(cursor.layout))
+	return url, nil, err
+}
+
+func (cursor *sinceCursor) Advance(_ *http.Response, items interface{}) {
+	forEachItem(items, func(item interface{}) {
+		if t := cursor.timeOf(item); t.After(cursor.since) {
+			cursor.since = t
+		}
+	})
+}
+
+// NewLastIDCursor creates a cursor that fetches the items after the last one
+// fetched so far, by setting the specified query parameter to said item's ID;
+// items are expected in ascending order.
+func NewLastIDCursor(parameter string, idOf func(item interface{}) string) Cursor {
+	return &lastIDCursor{parameter: parameter, idOf: idOf}
+}
+
+type lastIDCursor struct {
+	parameter string
+	lastID    string
+	idOf      func(item interface{}) string
+}
+
+func (cursor *lastIDCursor) Apply(url string) (string, map[string]string, error) {
+	if cursor.lastID == "" {
+		return url, nil, nil
+	}
+	url, err := withQueryParameter(url, cursor.parameter, cursor.lastID)
+	return url, nil, err
+}
+
+func (cursor *lastIDCursor) Advance(_ *http.Response, items interface{}) {
+	forEachItem(items, func(item interface{}) {
+		cursor.lastID = cursor.idOf(item)
+	})
+}
+
+// NewETagCursor creates a cursor that makes conditional requests: it sends
+// the ETag of the last response as the If-None-Match header, so that
+// the resource responds with 304 (Not Modified) if it hasn't changed.
+func NewETagCursor() Cursor {
+	return &eTagCursor{}
+}
+
+type eTagCursor struct {
+	eTag string
+}
+
+func (cursor *eTagCursor) Apply(url string) (string, map[string]string, error) {
+	if cursor.eTag == "" {
+		return url, nil, nil
+	}
+	return url, map[string]string{ifNoneMatchHeaderKey: cursor.eTag}, nil
+}
+
+func (cursor *eTagCursor) Advance(response *http.Response, _ interface{}) {
+	if eTag := response.Header.Get(eTagHeaderKey); eTag != "" {
+		cursor.eTag = eTag
+	}
+}
+
+func withQueryParameter(url, key, value string) (string, error) {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %v", url, err)
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// forEachItem calls the specified function with each item of the specified
+// slice.
+func forEachItem(items interface{}, f func(item interface{})) {
+	slice := reflect.ValueOf(items)
+	for i := 0; i < slice.Len(); i++ {
+		f(slice.Index(i).Interface())
+	}
+}
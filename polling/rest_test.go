@@ -0,0 +1,172 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/containers/sets"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+	"github.com/voicera/tester/assert"
+)
+
+type restItem struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+func newRestItem(id string, seconds int64) *restItem {
+	return &restItem{ID: id, Time: time.Unix(seconds, 0).UTC()}
+}
+
+// newRESTServer creates a server that responds to each request with the next
+// response of the script, which are encoded as JSON unless they're status
+// codes, and records the requests.
+func newRESTServer(script ...interface{}) (*httptest.Server, *[]*http.Request) {
+	requests := &[]*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		*requests = append(*requests, request)
+		response := script[len(*requests)-1]
+		if statusCode, ok := response.(int); ok {
+			writer.WriteHeader(statusCode)
+			return
+		}
+		json.NewEncoder(writer).Encode(response)
+	}))
+	return server, requests
+}
+
+func newRestItems() interface{} {
+	return &[]*restItem{}
+}
+
+func receiveAll(t *testing.T, receiver Receiver, count int) []interface{} {
+	payloads := make([]interface{}, count)
+	for i := range payloads {
+		payload, found, err := receiver.Receive()
+		assert.For(t, i).ThatActual(err).IsNil()
+		assert.For(t, i).ThatActual(found).Equals(payload != nil)
+		payloads[i] = payload
+	}
+	return payloads
+}
+
+func TestRESTReceiver_sinceCursor(t *testing.T) {
+	server, requests := newRESTServer(
+		[]*restItem{newRestItem("a", 10), newRestItem("b", 20)},
+		[]*restItem{newRestItem("b", 20), newRestItem("c", 30)},
+		[]*restItem{newRestItem("c", 30)},
+		[]*restItem{})
+	defer server.Close()
+
+	receiver := NewRESTReceiver(rest.NewJSONClient(http.DefaultClient).WithBaseURL(server.URL), "calls?page=1", newRestItems).
+		WithCursor(NewSinceCursor("since", time.RFC3339, time.Time{},
+			func(item interface{}) time.Time { return item.(*restItem).Time })).
+		WithDeduplication(func(item interface{}) interface{} { return item.(*restItem).ID }, sets.NewExpirableSet(0, time.Hour))
+
+	payloads := receiveAll(t, receiver, 4)
+	assert.For(t).ThatActual(payloads).Equals([]interface{}{
+		[]*restItem{newRestItem("a", 10), newRestItem("b", 20)},
+		[]*restItem{newRestItem("c", 30)},
+		nil,
+		nil,
+	}).ThenDiffOnFail()
+	var queries []string
+	for _, request := range *requests {
+		queries = append(queries, request.URL.RawQuery)
+	}
+	assert.For(t).ThatActual(queries).Equals([]string{
+		"page=1",
+		"page=1&since=1970-01-01T00%3A00%3A20Z",
+		"page=1&since=1970-01-01T00%3A00%3A30Z",
+		"page=1&since=1970-01-01T00%3A00%3A30Z",
+	}).ThenDiffOnFail()
+}
+
+func TestRESTReceiver_lastIDCursor(t *testing.T) {
+	server, requests := newRESTServer(
+		map[string]interface{}{"calls": []*restItem{newRestItem("a", 0), newRestItem("b", 0)}},
+		map[string]interface{}{"calls": []*restItem{}},
+		map[string]interface{}{"calls": []*restItem{newRestItem("c", 0)}})
+	defer server.Close()
+
+	type envelope struct {
+		Calls []*restItem `json:"calls"`
+	}
+	receiver := NewRESTReceiver(rest.NewJSONClient(http.DefaultClient).WithBaseURL(server.URL), "calls",
+		func() interface{} { return &envelope{} }).
+		WithItems(func(result interface{}) interface{} { return result.(*envelope).Calls }).
+		WithCursor(NewLastIDCursor("after", func(item interface{}) string { return item.(*restItem).ID }))
+
+	payloads := receiveAll(t, receiver, 3)
+	assert.For(t).ThatActual(payloads).Equals([]interface{}{
+		[]*restItem{newRestItem("a", 0), newRestItem("b", 0)},
+		nil,
+		[]*restItem{newRestItem("c", 0)},
+	}).ThenDiffOnFail()
+	assert.For(t).ThatActualString((*requests)[0].URL.RawQuery).Equals("")
+	assert.For(t).ThatActualString((*requests)[1].URL.RawQuery).Equals("after=b")
+	assert.For(t).ThatActualString((*requests)[2].URL.RawQuery).Equals("after=b")
+}
+
+func TestRESTReceiver_eTagCursor(t *testing.T) {
+	eTags := []string{`"v1"`, "", `"v2"`}
+	var ifNoneMatches []string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ifNoneMatches = append(ifNoneMatches, request.Header.Get("If-None-Match"))
+		switch len(ifNoneMatches) {
+		case 2:
+			writer.WriteHeader(http.StatusNotModified)
+		case 4:
+			writer.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			writer.Header().Set("ETag", eTags[len(ifNoneMatches)-1])
+			json.NewEncoder(writer).Encode([]*restItem{newRestItem("a", 0)})
+		}
+	}))
+	defer server.Close()
+
+	receiver := NewRESTReceiver(rest.NewJSONClient(http.DefaultClient).WithBaseURL(server.URL), "calls", newRestItems).
+		WithCursor(NewETagCursor())
+	payloads := receiveAll(t, receiver, 3)
+	assert.For(t).ThatActual(payloads).Equals([]interface{}{
+		[]*restItem{newRestItem("a", 0)},
+		nil,
+		[]*restItem{newRestItem("a", 0)},
+	}).ThenDiffOnFail()
+
+	_, found, err := receiver.Receive()
+	assert.For(t).ThatActual(found).IsFalse()
+	if assert.For(t).ThatActual(err).IsNotNil().Passed() {
+		assert.For(t).ThatActual(err.(*web.HTTPError).StatusCode).Equals(http.StatusServiceUnavailable)
+	}
+	assert.For(t).ThatActual(ifNoneMatches).Equals([]string{"", `"v1"`, `"v1"`, `"v2"`}).ThenDiffOnFail()
+}
+
+func TestRESTReceiver_stopCancelsRequest(t *testing.T) {
+	receiving, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(receiving)
+		select { // blocks until canceled
+		case <-request.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	receiver := NewRESTReceiver(rest.NewJSONClient(http.DefaultClient).WithBaseURL(server.URL), "calls", newRestItems)
+	poller, _ := NewBernoulliExponentialBackoffPoller(receiver, "test", 1, time.Hour, time.Hour)
+	poller.Start(context.Background())
+	<-receiving
+	poller.Stop()
+	select {
+	case <-poller.Done():
+	case <-time.After(time.Second):
+		t.Error("poller did not cancel the request when it stopped")
+	}
+}
@@ -0,0 +1,200 @@
+package polling
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/validate"
+	"go.uber.org/atomic"
+)
+
+// Stats represents a snapshot of a poller's statistics since it was created.
+type Stats struct {
+	// Name is the name of the poller.
+	Name string `json:"name"`
+
+	// Paused denotes whether or not the poller is paused.
+	Paused bool `json:"paused"`
+
+	// Workers is the number of the poller's receive loops that are running.
+	Workers int `json:"workers"`
+
+	// Calls is the number of calls to the receiver.
+	Calls uint64 `json:"calls"`
+
+	// FoundCalls is the number of calls to the receiver that found payloads.
+	FoundCalls uint64 `json:"foundCalls"`
+
+	// EmptyCalls is the number of calls to the receiver that were
+	// empty-handed (without failing).
+	EmptyCalls uint64 `json:"emptyCalls"`
+
+	// FailedCalls is the number of calls to the receiver that failed.
+	FailedCalls uint64 `json:"failedCalls"`
+
+	// ConsecutiveEmpty is the number of consecutive empty-handed calls
+	// (of the worker that called the receiver last).
+	ConsecutiveEmpty int `json:"consecutiveEmpty"`
+
+	// CurrentBackoff is the delay of the relaxation after the last call,
+	// which is zero if the poller didn't relax.
+	CurrentBackoff time.Duration `json:"currentBackoff"`
+
+	// LastSuccessfulReceiveTime is the time of the last call to the receiver
+	// that did not fail, or the zero time if there's none.
+	LastSuccessfulReceiveTime time.Time `json:"lastSuccessfulReceiveTime"`
+
+	// TimeSlept is the total of the poller's relaxation delays.
+	TimeSlept time.Duration `json:"timeSlept"`
+
+	// CallsSaved is the estimated number of calls that busy-waiting would
+	// have made while the poller relaxed, given the average call duration.
+	CallsSaved uint64 `json:"callsSaved"`
+
+	// Budget is the state of the poller's call budget, if any; it's shared
+	// by the pollers that share the budget.
+	Budget *BudgetStats `json:"budget,omitempty"`
+}
+
+// MarshalJSON marshals the stats into JSON, with durations formatted as
+// strings (e.g., "1.5s").
+func (stats Stats) MarshalJSON() ([]byte, error) {
+	type plainStats Stats
+	return json.Marshal(&struct {
+		plainStats
+		CurrentBackoff string `json:"currentBackoff"`
+		TimeSlept      string `json:"timeSlept"`
+	}{plainStats(stats), stats.CurrentBackoff.String(), stats.TimeSlept.String()})
+}
+
+// statistics tracks the statistics of a poller; it's safe for concurrent use.
+type statistics struct {
+	calls            *atomic.Uint64
+	foundCalls       *atomic.Uint64
+	failedCalls      *atomic.Uint64
+	consecutiveEmpty *atomic.Int64
+	currentBackoff   *atomic.Int64 // in nanoseconds
+	timeSlept        *atomic.Int64 // in nanoseconds
+	timeReceiving    *atomic.Int64 // in nanoseconds
+}
+
+func newStatistics() *statistics {
+	return &statistics{
+		calls:            atomic.NewUint64(0),
+		foundCalls:       atomic.NewUint64(0),
+		failedCalls:      atomic.NewUint64(0),
+		consecutiveEmpty: atomic.NewInt64(0),
+		currentBackoff:   atomic.NewInt64(0),
+		timeSlept:        atomic.NewInt64(0),
+		timeReceiving:    atomic.NewInt64(0),
+	}
+}
+
+// recordCall records a call to the receiver that took the specified duration.
+func (stats *statistics) recordCall(receipt *Receipt, duration time.Duration) {
+	stats.calls.Inc()
+	stats.timeReceiving.Add(int64(duration))
+	if receipt.Err != nil {
+		stats.failedCalls.Inc()
+	} else if receipt.Found {
+		stats.foundCalls.Inc()
+	}
+	stats.consecutiveEmpty.Store(int64(receipt.ConsecutiveEmpty))
+}
+
+// recordRelaxation records the delay of the relaxation after a call and how
+// long it actually lasted (e.g., less if it was cut short by PollNow).
+func (stats *statistics) recordRelaxation(delay time.Duration, slept time.Duration) {
+	stats.currentBackoff.Store(int64(delay))
+	stats.timeSlept.Add(int64(slept))
+}
+
+// snapshot fills in the specified stats.
+func (stats *statistics) snapshot(snapshot *Stats) {
+	snapshot.Calls = stats.calls.Load()
+	snapshot.FoundCalls = stats.foundCalls.Load()
+	snapshot.FailedCalls = stats.failedCalls.Load()
+	if snapshot.Calls > snapshot.FoundCalls+snapshot.FailedCalls { // loaded separately, so they may be off by a call
+		snapshot.EmptyCalls = snapshot.Calls - snapshot.FoundCalls - snapshot.FailedCalls
+	}
+	snapshot.ConsecutiveEmpty = int(stats.consecutiveEmpty.Load())
+	snapshot.CurrentBackoff = time.Duration(stats.currentBackoff.Load())
+	snapshot.TimeSlept = time.Duration(stats.timeSlept.Load())
+	if timeReceiving := stats.timeReceiving.Load(); timeReceiving > 0 && snapshot.Calls > 0 {
+		averageCallDuration := float64(timeReceiving) / float64(snapshot.Calls)
+		snapshot.CallsSaved = uint64(float64(snapshot.TimeSlept) / averageCallDuration)
+	}
+}
+
+// Registry is a collection of pollers, keyed by name, whose stats can be
+// rendered as JSON over HTTP; it's safe for concurrent use.
+type Registry struct {
+	mutex   sync.RWMutex
+	pollers map[string]Poller
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{pollers: map[string]Poller{}}
+}
+
+// Register adds the specified poller to the registry; it fails if a poller
+// with the same name is already registered.
+func (registry *Registry) Register(poller Poller) error {
+	if poller == nil {
+		return validate.NewValidationError(errors.New("poller is nil"), "poller")
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, found := registry.pollers[poller.GetName()]; found {
+		return validate.NewValidationError(errors.New("poller is already registered: "+poller.GetName()), "poller")
+	}
+	registry.pollers[poller.GetName()] = poller
+	return nil
+}
+
+// Unregister removes the poller with the specified name from the registry,
+// if any.
+func (registry *Registry) Unregister(name string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.pollers, name)
+}
+
+// Stats returns the stats of the registered pollers, sorted by name.
+func (registry *Registry) Stats() []Stats {
+	registry.mutex.RLock()
+	pollers := make([]Poller, 0, len(registry.pollers))
+	for _, poller := range registry.pollers {
+		pollers = append(pollers, poller)
+	}
+	registry.mutex.RUnlock()
+	sort.Slice(pollers, func(i, j int) bool { return pollers[i].GetName() < pollers[j].GetName() })
+
+	stats := make([]Stats, len(pollers))
+	for i, poller := range pollers {
+		stats[i] = poller.Stats()
+	}
+	return stats
+}
+
+// Handler creates a handler that serves the stats of the registered pollers
+// as JSON, keyed by name.
+func (registry *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		stats := map[string]Stats{}
+		for _, pollerStats := range registry.Stats() {
+			stats[pollerStats.Name] = pollerStats
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(writer).Encode(stats); err != nil {
+			gooseberry.Logger.Error("Error writing poller stats", "err", err)
+		}
+	})
+}
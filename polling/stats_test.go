@@ -0,0 +1,95 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/tester/assert"
+)
+
+// newScriptedPoller creates a poller whose receiver follows the script and
+// whose calls take 1ms on the poller's (fake) clock, which starts at the Unix
+// epoch.
+func newScriptedPoller(name string, script []error, options ...Option) Poller {
+	var poller Poller
+	recorder, receiver := newSleepRecorder(), newScriptedReceiver(&poller, script)
+	receiver.onCall = func() { recorder.Advance(time.Millisecond) }
+	poller, _ = NewPoller(receiver,
+		append([]Option{WithName(name), WithBufferSize(len(script)), WithClock(recorder)}, options...)...)
+	return poller
+}
+
+func TestPoller_stats(t *testing.T) {
+	poller := newScriptedPoller("stats", []error{errFound, nil, errors.New("failed"), nil, nil},
+		WithCondition(EmptyHanded()), WithBackoff(backoff.NewLinear(time.Second, time.Second, time.Minute)))
+	assert.For(t).ThatActual(poller.Stats()).Equals(Stats{Name: "stats"}).ThenDiffOnFail()
+
+	assert.For(t).ThatActual(poller.Run(context.Background())).IsNil()
+	stats := poller.Stats()
+	assert.For(t).ThatActualString(stats.Name).Equals("stats")
+	assert.For(t).ThatActual(stats.Workers).Equals(1)
+	assert.For(t).ThatActual(stats.Calls).Equals(uint64(5))
+	assert.For(t).ThatActual(stats.FoundCalls).Equals(uint64(1))
+	assert.For(t).ThatActual(stats.EmptyCalls).Equals(uint64(3))
+	assert.For(t).ThatActual(stats.FailedCalls).Equals(uint64(1))
+	assert.For(t).ThatActual(stats.ConsecutiveEmpty).Equals(3)
+	assert.For(t).ThatActual(stats.CurrentBackoff).Equals(3 * time.Second)
+	assert.For(t).ThatActual(stats.TimeSlept).Equals(6 * time.Second)
+	assert.For(t).ThatActual(stats.LastSuccessfulReceiveTime).Equals(time.Unix(0, 0).Add(3*time.Second + 5*time.Millisecond))
+	assert.For(t).ThatActual(stats.CallsSaved).Equals(uint64(6000))
+}
+
+func TestStats_MarshalJSON(t *testing.T) {
+	stats := Stats{Name: "calls", Calls: 3, CurrentBackoff: 1500 * time.Millisecond, TimeSlept: time.Minute}
+	marshalled, err := json.Marshal(stats)
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+	var actual map[string]interface{}
+	json.Unmarshal(marshalled, &actual)
+	assert.For(t).ThatActual(actual["name"]).Equals("calls")
+	assert.For(t).ThatActual(actual["calls"]).Equals(3.0)
+	assert.For(t).ThatActual(actual["currentBackoff"]).Equals("1.5s")
+	assert.For(t).ThatActual(actual["timeSlept"]).Equals("1m0s")
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	calls := newScriptedPoller("calls", []error{errFound})
+	texts := newScriptedPoller("texts", []error{nil, nil})
+	for _, poller := range []Poller{texts, calls} {
+		assert.For(t, poller.GetName()).ThatActual(registry.Register(poller)).IsNil()
+		poller.Run(context.Background())
+	}
+
+	err := registry.Register(newScriptedPoller("calls", nil))
+	if assert.For(t).ThatActual(err).IsNotNil().Passed() {
+		assert.For(t).ThatActual(err.(*validate.ValidationError).ArgumentName).Equals("poller")
+	}
+	assert.For(t).ThatActual(registry.Register(nil)).IsNotNil()
+
+	stats := registry.Stats()
+	if assert.For(t).ThatActual(len(stats)).Equals(2).Passed() {
+		assert.For(t).ThatActualString(stats[0].Name).Equals("calls")
+		assert.For(t).ThatActualString(stats[1].Name).Equals("texts")
+	}
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/pollers", nil))
+	assert.For(t).ThatActual(recorder.Code).Equals(200)
+	assert.For(t).ThatActualString(recorder.Header().Get("Content-Type")).Equals("application/json")
+	var served map[string]map[string]interface{}
+	if assert.For(t).ThatActual(json.Unmarshal(recorder.Body.Bytes(), &served)).IsNil().Passed() {
+		assert.For(t).ThatActual(served["calls"]["foundCalls"]).Equals(1.0)
+		assert.For(t).ThatActual(served["texts"]["emptyCalls"]).Equals(2.0)
+	}
+
+	registry.Unregister("calls")
+	assert.For(t).ThatActual(len(registry.Stats())).Equals(1)
+}
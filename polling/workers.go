@@ -0,0 +1,193 @@
+package polling
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry"
+)
+
+const (
+	// scaleUpFoundRatio is the ratio of calls that found payloads, at or above
+	// which a poller adds a worker.
+	scaleUpFoundRatio = 0.75
+
+	// scaleDownFoundRatio is the ratio of calls that found payloads, at or
+	// below which a poller retires a worker.
+	scaleDownFoundRatio = 0.25
+)
+
+// workerPool runs the receive loops (workers) of a poller.
+type workerPool struct {
+	*pollingChannel
+	ctx         context.Context
+	cancel      context.CancelFunc
+	retirements []context.CancelFunc
+	waitGroup   sync.WaitGroup
+}
+
+// add starts a new worker.
+func (pool *workerPool) add() {
+	ctx, retire := context.WithCancel(pool.ctx)
+	pool.retirements = append(pool.retirements, retire)
+	pool.workers.Store(int64(len(pool.retirements)))
+	pool.waitGroup.Add(1)
+	w := &worker{pollingChannel: pool.pollingChannel}
+	w.relaxer, w.configuration = pool.newRelaxer()
+	go func() {
+		defer pool.waitGroup.Done()
+		defer retire()
+		if err := w.run(pool.ctx, ctx); err != nil {
+			pool.cancel()
+		}
+	}()
+}
+
+// retire signals the last worker to stop after its current call, if any.
+func (pool *workerPool) retire() {
+	last := len(pool.retirements) - 1
+	pool.retirements[last]()
+	pool.retirements = pool.retirements[:last]
+	pool.workers.Store(int64(last))
+}
+
+// scale adds and retires workers at the scaling interval until the pool's
+// context is done.
+func (pool *workerPool) scale() {
+	for {
+		select {
+		case <-pool.clock.After(pool.scalingInterval):
+		case <-pool.ctx.Done():
+			return
+		}
+
+		workers := len(pool.retirements)
+		switch scalingDelta(pool.foundCalls.Swap(0), pool.calls.Swap(0), workers, pool.minWorkers, pool.maxWorkers) {
+		case 1:
+			pool.add()
+		case -1:
+			pool.retire()
+		default:
+			continue
+		}
+		gooseberry.Logger.Debug("Scaled workers", "poller", pool.name, "workers", len(pool.retirements))
+	}
+}
+
+// wait waits for all workers to stop.
+func (pool *workerPool) wait() {
+	pool.waitGroup.Wait()
+}
+
+// scalingDelta determines whether to add a worker (1), retire one (-1), or
+// neither (0), given the number of calls (and ones that found payloads) since
+// the last scaling decision. Workers are retired if no calls were made (e.g.,
+// because they're all relaxing).
+func scalingDelta(foundCalls, calls uint64, workers, min, max int) int {
+	foundRatio := 0.0
+	if calls > 0 {
+		foundRatio = float64(foundCalls) / float64(calls)
+	}
+	switch {
+	case foundRatio >= scaleUpFoundRatio && workers < max:
+		return 1
+	case foundRatio <= scaleDownFoundRatio && workers > min:
+		return -1
+	}
+	return 0
+}
+
+// worker runs one of a poller's receive loops, with its own relaxation state.
+type worker struct {
+	*pollingChannel
+	relaxer           relaxer
+	configuration     uint64 // the number of the configuration of the relaxer
+	wakeupsSeen       uint64 // the number of PollNow calls before the current call started
+	consecutiveEmpty  int
+	consecutiveErrors int
+}
+
+// run calls the receiver and sends found payloads along until the pool's
+// context is done, or until the worker's context is done and the current call
+// (if any) is over; it returns the terminal error that stopped it, if any.
+func (w *worker) run(poolCtx, ctx context.Context) error {
+	for ctx.Err() == nil {
+		if !w.waitUntilResumed(ctx) || (w.budget != nil && !w.budget.acquire(ctx, w.name)) {
+			return nil
+		}
+		w.wakeupsSeen = w.wakeups.Load()
+		start := w.clock.Now()
+		payload, found, err := w.receiver.ReceiveContext(poolCtx)
+		if poolCtx.Err() != nil {
+			return nil
+		}
+		receipt := w.record(found, err, start)
+		if receipt.succeeded() {
+			select {
+			case w.data <- payload:
+			case <-poolCtx.Done():
+				return nil
+			}
+		} else if err != nil {
+			if terminalError := w.handleError(receipt); terminalError != nil {
+				return terminalError
+			}
+		}
+		gooseberry.Logger.Debug("Relaxing", "poller", w.name)
+		w.stats.recordRelaxation(w.relax(ctx, receipt))
+	}
+	return nil
+}
+
+// relax relaxes (using the poller's latest configuration) until the relaxation
+// is over, the specified context is done, or PollNow is called; relaxation is
+// skipped if PollNow was called since the current call started. It returns
+// the scheduled delay and how long the worker actually slept.
+func (w *worker) relax(ctx context.Context, receipt *Receipt) (delay time.Duration, slept time.Duration) {
+	if w.configurations.Load() != w.configuration {
+		w.relaxer, w.configuration = w.newRelaxer()
+	}
+
+	if delay = w.relaxer.delay(receipt); delay <= 0 {
+		return 0, 0
+	}
+	w.stats.currentBackoff.Store(int64(delay)) // so that it's current while relaxing
+
+	ctx, wake := context.WithCancel(ctx)
+	defer wake()
+	w.mutex.Lock()
+	if w.wakeups.Load() != w.wakeupsSeen {
+		wake()
+	}
+	w.wakes[w] = wake
+	w.mutex.Unlock()
+	defer func() {
+		w.mutex.Lock()
+		delete(w.wakes, w)
+		w.mutex.Unlock()
+	}()
+	return delay, sleep(ctx, w.clock, delay)
+}
+
+// record creates a receipt for a call that started at the specified time,
+// and updates the statistics.
+func (w *worker) record(found bool, err error, start time.Time) *Receipt {
+	receipt := &Receipt{Found: found, Err: err, Time: w.clock.Now()}
+	w.calls.Inc()
+	if err == nil {
+		w.lastSuccessfulReceiveTime.Store(receipt.Time.UnixNano())
+		if found {
+			w.foundCalls.Inc()
+			w.consecutiveEmpty = 0
+		} else {
+			w.consecutiveEmpty++
+		}
+		w.consecutiveErrors = 0
+	} else {
+		w.consecutiveErrors++
+	}
+	receipt.ConsecutiveEmpty, receipt.ConsecutiveErrors = w.consecutiveEmpty, w.consecutiveErrors
+	w.stats.recordCall(receipt, receipt.Time.Sub(start))
+	return receipt
+}
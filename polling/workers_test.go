@@ -0,0 +1,150 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/tester/assert"
+	"go.uber.org/atomic"
+)
+
+// concurrentReceiver finds payloads (sequence numbers) until it runs out of
+// them, and tracks the maximum number of concurrent calls.
+type concurrentReceiver struct {
+	mutex         sync.Mutex
+	payloads      int
+	sent          int
+	inFlight      int
+	maxInFlight   int
+	callDuration  time.Duration
+	alwaysFinding *atomic.Bool
+}
+
+func (receiver *concurrentReceiver) Receive() (interface{}, bool, error) {
+	receiver.mutex.Lock()
+	receiver.inFlight++
+	if receiver.inFlight > receiver.maxInFlight {
+		receiver.maxInFlight = receiver.inFlight
+	}
+	receiver.mutex.Unlock()
+
+	time.Sleep(receiver.callDuration)
+
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.inFlight--
+	if receiver.sent < receiver.payloads || (receiver.alwaysFinding != nil && receiver.alwaysFinding.Load()) {
+		receiver.sent++
+		return receiver.sent, true, nil
+	}
+	return nil, false, nil
+}
+
+func (receiver *concurrentReceiver) getMaxInFlight() int {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return receiver.maxInFlight
+}
+
+func TestScalingDelta(t *testing.T) {
+	cases := []struct {
+		id         string
+		foundCalls uint64
+		calls      uint64
+		workers    int
+		expected   int
+	}{
+		{"busy", 8, 10, 2, 1},
+		{"busy at max", 10, 10, 3, 0},
+		{"idle", 2, 10, 2, -1},
+		{"idle at min", 0, 10, 1, 0},
+		{"no calls", 0, 0, 2, -1},
+		{"moderate", 5, 10, 2, 0},
+	}
+
+	for _, c := range cases {
+		assert.For(t, c.id).ThatActual(scalingDelta(c.foundCalls, c.calls, c.workers, 1, 3)).Equals(c.expected)
+	}
+}
+
+func TestWorkers_fanIn(t *testing.T) {
+	receiver := &concurrentReceiver{payloads: 100, callDuration: time.Millisecond}
+	poller, err := NewPoller(receiver, WithWorkers(4, 4), WithBackoff(backoff.NewConstant(time.Millisecond)))
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+
+	poller.Start(context.Background())
+	received := map[interface{}]bool{}
+	for len(received) < receiver.payloads {
+		payload := <-poller.Channel()
+		assert.For(t, payload).ThatActual(received[payload]).IsFalse()
+		received[payload] = true
+	}
+	poller.Stop()
+	for range poller.Channel() { // drains payloads sent before stopping, until the channel is closed
+	}
+	<-poller.Done()
+	assert.For(t).ThatActual(receiver.getMaxInFlight()).Equals(4)
+	assert.For(t).ThatActual(poller.Err()).IsNil()
+}
+
+func TestWorkers_scaling(t *testing.T) {
+	alwaysFinding := atomic.NewBool(true)
+	receiver := &concurrentReceiver{callDuration: time.Millisecond, alwaysFinding: alwaysFinding}
+	poller, _ := NewPoller(receiver,
+		WithWorkers(1, 3),
+		WithScalingInterval(5*time.Millisecond),
+		WithBackoff(backoff.NewConstant(time.Millisecond)))
+	pc := poller.(*pollingChannel)
+	poller.Start(context.Background())
+	go func() {
+		for range poller.Channel() {
+		}
+	}()
+
+	waitForWorkers := func(expected int64) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if pc.workers.Load() == expected {
+				return true
+			}
+		}
+		return false
+	}
+	assert.For(t, "scaled up").ThatActual(waitForWorkers(3)).IsTrue()
+	alwaysFinding.Store(false)
+	assert.For(t, "scaled down").ThatActual(waitForWorkers(1)).IsTrue()
+	poller.Stop()
+	<-poller.Done()
+	assert.For(t).ThatActual(receiver.getMaxInFlight()).Equals(3)
+}
+
+func TestWorkers_terminalErrorStopsAll(t *testing.T) {
+	errFailed := errors.New("failed")
+	calls := atomic.NewInt64(0)
+	receiver := ContextReceiverFunc(func(ctx context.Context) (interface{}, bool, error) {
+		if calls.Inc() == 10 {
+			return nil, false, NewFatalError(errFailed)
+		}
+		return nil, false, nil
+	})
+	poller, _ := NewPoller(receiver, WithWorkers(3, 3), WithBackoff(backoff.NewConstant(time.Millisecond)))
+
+	err := poller.Run(context.Background())
+	if terminalError, ok := err.(*TerminalError); assert.For(t).ThatActual(ok).IsTrue().Passed() {
+		assert.For(t).ThatActual(terminalError.Err.Error()).Equals("failed")
+	}
+	_, receiving := <-poller.Channel()
+	assert.For(t).ThatActual(receiving).IsFalse()
+}
+
+func TestNewPoller_invalidWorkers(t *testing.T) {
+	for _, workers := range [][]int{{0, 1}, {2, 1}, {-1, -1}} {
+		_, err := NewPoller(&neverEmptyHandedReceiver{}, WithWorkers(workers[0], workers[1]))
+		assert.For(t, workers).ThatActual(err).IsNotNil()
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+	"github.com/voicera/gooseberry/polling"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+)
+
+// newRESTClient creates a REST client whose transport stack is configured
+// by the specified options: basic auth, custom headers, retries, and logging
+// (using the specified logger) with redaction, from the outermost inward.
+func newRESTClient(options *options, transport http.RoundTripper, logger log.LeveledLogger) rest.Client {
+	redactor := web.NewDefaultRedactor().
+		WithJSONFields(web.MaskAll, options.RedactedFields...).
+		WithFormFields(web.MaskAll, options.RedactedFields...)
+	transport = web.NewRedactingLeveledLoggerRoundTripper(transport, logger, redactor)
+	if options.MaxAttempts > 1 {
+		transport = web.NewRetryingRoundTripper(transport, options.MaxAttempts, time.Duration(options.RetryDelay))
+	}
+	transport = web.NewCustomHeadersRoundTripper(transport, options.Headers)
+	if options.Username != "" || options.Password != "" {
+		transport = web.NewBasicAuthRoundTripper(transport, options.Username, options.Password)
+	}
+
+	httpClient := &http.Client{Transport: transport}
+	var client rest.Client
+	if options.URLEncoded {
+		client = rest.NewURLEncodedRequestJSONResponseClient(httpClient)
+	} else {
+		client = rest.NewJSONClient(httpClient)
+	}
+	return client.WithBaseURL(options.BaseURL).(rest.ConfigurableClient).WithTimeout(time.Duration(options.Timeout))
+}
+
+// newRequestBody creates the body of the request from the data specified in
+// the options, as expected by the client that newRESTClient creates.
+func newRequestBody(options *options) (interface{}, error) {
+	if options.data == "" {
+		return nil, nil
+	}
+	if !options.URLEncoded {
+		return json.RawMessage(options.data), nil
+	}
+	values, err := url.ParseQuery(options.data)
+	if err != nil {
+		return nil, err
+	}
+	parameters := map[string]string{}
+	for key := range values {
+		parameters[key] = values.Get(key)
+	}
+	return parameters, nil
+}
+
+// fetch makes the request and returns its decoded JSON response, which is nil
+// if the response has no body.
+func fetch(client rest.Client, options *options, body interface{}) (interface{}, error) {
+	var result interface{}
+	if _, err := client.Do(options.method, options.url, body, &result); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return result, nil
+}
+
+// changeReceiver receives responses to a request, but only when they differ
+// from the last one received; the first one is always received.
+type changeReceiver struct {
+	fetch    func() (interface{}, error)
+	received bool
+	last     []byte
+}
+
+func (receiver *changeReceiver) Receive() (interface{}, bool, error) {
+	result, err := receiver.fetch()
+	if err != nil {
+		return nil, false, err
+	}
+	marshalled, err := json.Marshal(result) // objects' keys are sorted, so equal results are marshalled alike
+	if err != nil {
+		return nil, false, err
+	}
+	if receiver.received && bytes.Equal(marshalled, receiver.last) {
+		return nil, false, nil
+	}
+	receiver.received, receiver.last = true, marshalled
+	return result, true, nil
+}
+
+// printChanges prints the payloads of the specified poller until it prints
+// the specified number of them (0 for no limit) or until the poller stops,
+// in which case it returns the error that stopped the poller, if any.
+func printChanges(writer io.Writer, poller polling.Poller, maxChanges int) error {
+	for changes := 1; ; changes++ {
+		payload, ok := <-poller.Channel()
+		if !ok {
+			return poller.Err()
+		}
+		if err := printJSON(writer, payload); err != nil {
+			return err
+		}
+		if changes == maxChanges {
+			return nil
+		}
+	}
+}
+
+func printJSON(writer io.Writer, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	indented, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(indented, '\n'))
+	return err
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+	"github.com/voicera/gooseberry/polling"
+	"github.com/voicera/tester/assert"
+)
+
+func TestParseArguments(t *testing.T) {
+	directory, err := ioutil.TempDir("", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	profilePath := filepath.Join(directory, "profile.json")
+	profile := `{"baseURL": "https://api.example.com/", "username": "user", "password": "secret",
+		"headers": {"X-Tenant": "acme", "Accept": "text/plain"}, "redactedFields": ["password"],
+		"maxAttempts": 3, "retryDelay": "250ms", "timeout": "5s"}`
+	if err := ioutil.WriteFile(profilePath, []byte(profile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	options, err := parseArguments("client", []string{
+		"-profile", profilePath, "-u", "admin:p:w", "-H", "Accept: application/json", "-redact", "token",
+		"-attempts", "5", "-X", "POST", "-form", "-d", "a=1", "-poll", "-poll-changes", "2", "users"})
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActualString(options.BaseURL).Equals("https://api.example.com/")
+		assert.For(t).ThatActualString(options.Username).Equals("admin")
+		assert.For(t).ThatActualString(options.Password).Equals("p:w")
+		assert.For(t).ThatActual(options.Headers).Equals(
+			map[string]string{"X-Tenant": "acme", "Accept": "application/json"}).ThenDiffOnFail()
+		assert.For(t).ThatActual(options.RedactedFields).Equals([]string{"password", "token"}).ThenDiffOnFail()
+		assert.For(t).ThatActual(options.MaxAttempts).Equals(5)
+		assert.For(t).ThatActual(time.Duration(options.RetryDelay)).Equals(250 * time.Millisecond)
+		assert.For(t).ThatActual(time.Duration(options.Timeout)).Equals(5 * time.Second)
+		assert.For(t).ThatActual(options.URLEncoded).IsTrue()
+		assert.For(t).ThatActualString(options.method).Equals("POST")
+		assert.For(t).ThatActualString(options.url).Equals("users")
+		assert.For(t).ThatActualString(options.data).Equals("a=1")
+		assert.For(t).ThatActual(options.poll).Equals(
+			&pollOptions{probability: 0.95, seed: time.Second, cap: time.Minute, changes: 2}).ThenDiffOnFail()
+	}
+
+	cases := []struct {
+		id        string
+		arguments []string
+	}{
+		{"no URL", []string{"-X", "GET"}},
+		{"invalid credentials", []string{"-u", "admin", "users"}},
+		{"invalid header", []string{"-H", "Accept", "users"}},
+		{"missing profile", []string{"-profile", filepath.Join(directory, "missing.json"), "users"}},
+	}
+	for _, c := range cases {
+		_, err := parseArguments("client", append([]string{}, c.arguments...))
+		assert.For(t, c.id).ThatActual(err).IsNotNil()
+	}
+}
+
+func TestFetch(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests, bodies = append(requests, r), append(bodies, string(body))
+		if len(requests) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id": 42, "tags": ["a", "b"]}`))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		id           string
+		urlEncoded   bool
+		data         string
+		expectedBody string
+	}{
+		{"json", false, `{"name":"gooseberry"}`, `{"name":"gooseberry"}`},
+		{"url-encoded", true, "name=gooseberry&color=green", "color=green&name=gooseberry"},
+	}
+	for _, c := range cases {
+		requests, bodies = nil, nil
+		options := &options{
+			BaseURL:     server.URL + "/",
+			Username:    "user",
+			Password:    "secret",
+			Headers:     map[string]string{"X-Tenant": "acme"},
+			URLEncoded:  c.urlEncoded,
+			MaxAttempts: 2,
+			RetryDelay:  duration(time.Millisecond),
+			method:      http.MethodPost,
+			url:         "users",
+			data:        c.data,
+		}
+		client := newRESTClient(options, http.DefaultTransport, &log.NoOpLogger{})
+		body, err := newRequestBody(options)
+		if !assert.For(t, c.id).ThatActual(err).IsNil().Passed() {
+			continue
+		}
+		result, err := fetch(client, options, body)
+		if assert.For(t, c.id).ThatActual(err).IsNil().Passed() &&
+			assert.For(t, c.id).ThatActual(len(requests)).Equals(2).Passed() {
+			assert.For(t, c.id).ThatActual(result).Equals(
+				map[string]interface{}{"id": 42.0, "tags": []interface{}{"a", "b"}}).ThenDiffOnFail()
+			username, password, _ := requests[1].BasicAuth()
+			assert.For(t, c.id).ThatActualString(username).Equals("user")
+			assert.For(t, c.id).ThatActualString(password).Equals("secret")
+			assert.For(t, c.id).ThatActualString(requests[1].Header.Get("X-Tenant")).Equals("acme")
+			assert.For(t, c.id).ThatActualString(requests[1].URL.Path).Equals("/users")
+			assert.For(t, c.id).ThatActualString(bodies[1]).Equals(c.expectedBody)
+		}
+	}
+}
+
+func TestChangeReceiver(t *testing.T) {
+	results := []interface{}{
+		map[string]interface{}{"status": "queued", "id": 1.0},
+		map[string]interface{}{"id": 1.0, "status": "queued"},
+		nil,
+		map[string]interface{}{"id": 1.0, "status": "ringing"},
+		map[string]interface{}{"id": 1.0, "status": "ringing"},
+	}
+	errFetch := errors.New("failed successfully")
+	calls := 0
+	receiver := &changeReceiver{fetch: func() (interface{}, error) {
+		calls++
+		if calls == 3 {
+			return nil, errFetch
+		}
+		return results[calls-1], nil
+	}}
+
+	expected := []bool{true, false, false, true, false}
+	for i, expectedFound := range expected {
+		payload, found, err := receiver.Receive()
+		assert.For(t, i).ThatActual(found).Equals(expectedFound)
+		if i == 2 {
+			assert.For(t, i).ThatActual(err).Equals(errFetch)
+		} else if found {
+			assert.For(t, i).ThatActual(payload).Equals(results[i]).ThenDiffOnFail()
+		}
+	}
+}
+
+func TestPrintChanges(t *testing.T) {
+	errFatal := errors.New("gone")
+	cases := []struct {
+		id             string
+		maxChanges     int
+		expectedOutput string
+		expectedErr    bool
+	}{
+		{"limited", 1, "1\n", false},
+		{"poller stopped", 0, "1\n2\n", true},
+	}
+	for _, c := range cases {
+		calls := 0
+		poller, err := polling.NewPoller(polling.ContextReceiverFunc(func(context.Context) (interface{}, bool, error) {
+			if calls++; calls > 2 {
+				return nil, false, polling.NewFatalError(errFatal)
+			}
+			return calls, true, nil
+		}), polling.WithName("changes"))
+		if !assert.For(t, c.id).ThatActual(err).IsNil().Passed() {
+			continue
+		}
+		poller.Start(context.Background())
+		buffer := &bytes.Buffer{}
+		err = printChanges(buffer, poller, c.maxChanges)
+		poller.Stop()
+		assert.For(t, c.id).ThatActualString(buffer.String()).Equals(c.expectedOutput)
+		assert.For(t, c.id).ThatActual(err != nil).Equals(c.expectedErr)
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	err := printJSON(buffer, map[string]interface{}{"b": []interface{}{1.0}, "a": "x"})
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActualString(buffer.String()).Equals("{\n  \"a\": \"x\",\n  \"b\": [\n    1\n  ]\n}\n")
+	}
+
+	buffer.Reset()
+	printJSON(buffer, nil)
+	assert.For(t).ThatActualString(buffer.String()).Equals("")
+}
@@ -0,0 +1,61 @@
+// This program makes HTTP requests using gooseberry's transport stack
+// and prints their decoded JSON responses; it can also watch a resource
+// change by polling it. For example:
+//
+//	go run scripts/client/*.go -profile twilio.json -poll Calls.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/log"
+	"github.com/voicera/gooseberry/log/zap"
+	"github.com/voicera/gooseberry/polling"
+	uberzap "go.uber.org/zap"
+)
+
+func main() {
+	options, err := parseArguments(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if !options.verbose {
+		zap.Level = uberzap.InfoLevel
+	}
+	gooseberry.Logger = zap.DefaultLogger
+	defer gooseberry.Logger.Sync()
+
+	client := newRESTClient(options, http.DefaultTransport, log.NewPrefixedLeveledLogger(gooseberry.Logger, "CLI:"))
+	body, err := newRequestBody(options)
+	exitIfNotNil(err)
+	if options.poll == nil {
+		result, err := fetch(client, options, body)
+		exitIfNotNil(err)
+		exitIfNotNil(printJSON(os.Stdout, result))
+		return
+	}
+
+	receiver := &changeReceiver{fetch: func() (interface{}, error) { return fetch(client, options, body) }}
+	poller, err := polling.NewBernoulliExponentialBackoffPoller(
+		receiver, options.url, options.poll.probability, options.poll.seed, options.poll.cap)
+	exitIfNotNil(err)
+	poller.Start(context.Background())
+	exitIfNotNil(printChanges(os.Stdout, poller, options.poll.changes))
+}
+
+func exitIfNotNil(err error) {
+	if err != nil {
+		gooseberry.Logger.Sync()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
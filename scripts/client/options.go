@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// options configure the client and the request it makes; the ones that
+// configure the client can be loaded from a profile (a JSON file), and flags
+// override them.
+type options struct {
+	BaseURL        string            `json:"baseURL"`
+	Username       string            `json:"username"`
+	Password       string            `json:"password"`
+	Headers        map[string]string `json:"headers"`
+	RedactedFields []string          `json:"redactedFields"`
+	URLEncoded     bool              `json:"urlEncoded"`
+	MaxAttempts    int               `json:"maxAttempts"`
+	RetryDelay     duration          `json:"retryDelay"`
+	Timeout        duration          `json:"timeout"`
+
+	method  string
+	url     string
+	data    string
+	verbose bool
+	poll    *pollOptions
+}
+
+// pollOptions configure the Bernoulli exponential backoff poller that's used
+// to watch a resource change.
+type pollOptions struct {
+	probability float64
+	seed        time.Duration
+	cap         time.Duration
+	changes     int
+}
+
+// duration is a time.Duration that's represented in JSON as a string that
+// time.ParseDuration can parse (e.g., "1.5s").
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	*d = duration(parsed)
+	return err
+}
+
+// stringsFlag is a flag that can be specified multiple times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func parseArguments(name string, arguments []string) (*options, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	profilePath := flags.String("profile", "", "path of a profile (JSON) with client options; flags override them.")
+	method := flags.String("X", http.MethodGet, "HTTP method of the request.")
+	data := flags.String("d", "", "body of the request: JSON, or key=value pairs separated by & if URL-encoded.")
+	urlEncoded := flags.Bool("form", false, "URL-encode the body of the request instead of using JSON.")
+	credentials := flags.String("u", "", "basic auth credentials as username:password.")
+	headers, redactedFields := stringsFlag{}, stringsFlag{}
+	flags.Var(&headers, "H", "custom header as \"Key: Value\"; can be repeated.")
+	flags.Var(&redactedFields, "redact", "JSON or form field to censor in logs; can be repeated.")
+	maxAttempts := flags.Int("attempts", 1, "maximum number of attempts per request (see web.NewRetryingRoundTripper).")
+	retryDelay := flags.Duration("retry-delay", time.Second, "delay before the first retry; it doubles after every retry.")
+	timeout := flags.Duration("timeout", 0, "timeout of each request (none by default).")
+	verbose := flags.Bool("v", false, "log (redacted) requests and responses.")
+	poll := flags.Bool("poll", false, "poll the resource and print it whenever it changes.")
+	probability := flags.Float64("poll-probability", 0.95, "probability of backing off after an unchanged poll.")
+	seed := flags.Duration("poll-seed", time.Second, "initial backoff delay between unchanged polls.")
+	cap := flags.Duration("poll-cap", time.Minute, "maximum backoff delay, after which backoff starts over.")
+	changes := flags.Int("poll-changes", 0, "number of changes to print before exiting (0 to poll forever).")
+	if err := flags.Parse(arguments); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != 1 {
+		return nil, errors.New("expected exactly one URL (or path relative to the profile's base URL)")
+	}
+
+	options := &options{Headers: map[string]string{}, MaxAttempts: 1, RetryDelay: duration(time.Second)}
+	if *profilePath != "" {
+		if err := loadProfile(*profilePath, options); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "form":
+			options.URLEncoded = *urlEncoded
+		case "u":
+			credentialParts := strings.SplitN(*credentials, ":", 2)
+			if len(credentialParts) != 2 {
+				err = errors.New("expected credentials as username:password")
+				return
+			}
+			options.Username, options.Password = credentialParts[0], credentialParts[1]
+		case "attempts":
+			options.MaxAttempts = *maxAttempts
+		case "retry-delay":
+			options.RetryDelay = duration(*retryDelay)
+		case "timeout":
+			options.Timeout = duration(*timeout)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range headers {
+		headerParts := strings.SplitN(header, ":", 2)
+		if len(headerParts) != 2 {
+			return nil, fmt.Errorf("expected header as \"Key: Value\" but got %q", header)
+		}
+		options.Headers[strings.TrimSpace(headerParts[0])] = strings.TrimSpace(headerParts[1])
+	}
+	options.RedactedFields = append(options.RedactedFields, redactedFields...)
+
+	options.method, options.url, options.data, options.verbose = *method, flags.Arg(0), *data, *verbose
+	if *poll {
+		options.poll = &pollOptions{probability: *probability, seed: *seed, cap: *cap, changes: *changes}
+	}
+	return options, nil
+}
+
+func loadProfile(path string, options *options) error {
+	profile, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(profile, options); err != nil {
+		return fmt.Errorf("invalid profile %s: %v", path, err)
+	}
+	if options.Headers == nil {
+		options.Headers = map[string]string{}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -64,7 +65,7 @@ func poll(receiver *receiver) {
 	if err != nil {
 		gooseberry.Logger.Error("error creating a poller", "err", err)
 	}
-	go poller.Start()
+	poller.Start(context.Background())
 	for batch := range poller.Channel() {
 		calls := batch.([]*call)
 		gooseberry.Logger.Debug("found calls", "callsCount", len(calls))
@@ -0,0 +1,731 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const sourceFileTemplateText = `// Package {{.Package}} provides a client of the {{.Title}} API{{if .Version}} (version {{.Version}}){{end}}.
+package {{.Package}} // auto-generated using scripts/openapi - DO NOT EDIT!
+
+import (
+{{- if .UsesFmt}}
+	"fmt"
+{{- end}}
+{{- if .Methods}}
+	"net/http"
+{{- end}}
+{{- if .UsesURL}}
+	"net/url"
+{{- end}}
+
+	"github.com/voicera/gooseberry/web/rest"
+)
+{{range .Types}}
+{{range .Comment}}//{{if .}} {{.}}{{end}}
+{{end -}}
+{{if .Fields -}}
+type {{.Name}} struct {
+{{- range .Fields}}
+{{range .Comment}}	//{{if .}} {{.}}{{end}}
+{{end -}}
+	{{.Name}} {{.Type}} ` + "`json:\"{{.JSONName}}{{if not .Required}},omitempty{{end}}\"`" + `
+{{- end}}
+}
+{{- else -}}
+type {{.Name}} {{.Underlying}}
+{{- end}}
+{{end}}
+// Client is a client of the {{.Title}} API.
+type Client struct {
+	restClient rest.Client
+}
+
+// NewClient creates a client that uses the specified REST client, which must
+// use JSON to encode requests and decode responses and whose base URL must be
+// the URL of the API's server (see rest.NewJSONClient).
+func NewClient(restClient rest.Client) *Client {
+	return &Client{restClient: restClient}
+}
+{{range .Methods}}
+{{range .Comment}}//{{if .}} {{.}}{{end}}
+{{end -}}
+func (client *Client) {{.Name}}(
+{{- range .PathArgs}}{{.Name}} {{.Type}}, {{end}}
+{{- if .ParamsType}}params *{{.ParamsType}}, {{end}}
+{{- if .BodyType}}body {{.BodyType}}{{end -}}
+) {{if .ResultType}}({{.ResultType}}, error){{else}}error{{end}} {
+	path := {{.PathExpression}}
+{{- if .QueryParams}}
+	query := url.Values{}
+	if params != nil {
+{{- range .QueryParams}}
+{{- if .IsSlice}}
+		for _, value := range params.{{.Field}} {
+			query.Add("{{.Name}}", fmt.Sprint(value))
+		}
+{{- else if .Required}}
+		query.Set("{{.Name}}", fmt.Sprint(params.{{.Field}}))
+{{- else}}
+		if params.{{.Field}} != nil {
+			query.Set("{{.Name}}", fmt.Sprint(*params.{{.Field}}))
+		}
+{{- end}}
+{{- end}}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+{{- end}}
+{{- if .ResultType}}
+	var result {{.ResultType}}
+	_, err := client.restClient.Do({{.HTTPMethod}}, path, {{if .BodyType}}body{{else}}nil{{end}}, &result)
+	return result, err
+{{- else}}
+	_, err := client.restClient.Do({{.HTTPMethod}}, path, {{if .BodyType}}body{{else}}nil{{end}}, nil)
+	return err
+{{- end}}
+}
+{{- if .ParamsType}}
+
+// {{.ParamsType}} represents the query parameters of {{.Name}}.
+type {{.ParamsType}} struct {
+{{- range .QueryParams}}
+{{range .Comment}}	//{{if .}} {{.}}{{end}}
+{{end -}}
+	{{.Field}} {{.Type}}
+{{- end}}
+}
+{{- end}}
+{{end}}`
+
+var (
+	sourceFileTemplate = template.Must(template.New("").Parse(sourceFileTemplateText))
+	httpMethods        = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+	commonInitialisms  = map[string]bool{
+		"API": true, "CPU": true, "CSS": true, "DNS": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+		"IP": true, "JSON": true, "SQL": true, "SSH": true, "TLS": true, "TTL": true, "UI": true, "URI": true,
+		"URL": true, "UUID": true, "XML": true,
+	}
+	reservedArgumentNames = map[string]bool{
+		"body": true, "client": true, "err": true, "params": true, "path": true, "query": true, "result": true,
+	}
+)
+
+// document represents the subset of an OpenAPI 3 document that's used to
+// generate clients.
+type document struct {
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas       map[string]*schema       `json:"schemas"`
+		Parameters    map[string]*parameter    `json:"parameters"`
+		RequestBodies map[string]*requestBody  `json:"requestBodies"`
+		Responses     map[string]*responseBody `json:"responses"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	AllOf                []*schema          `json:"allOf"`
+}
+
+type parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *schema `json:"schema"`
+}
+
+type operation struct {
+	OperationID string                   `json:"operationId"`
+	Summary     string                   `json:"summary"`
+	Description string                   `json:"description"`
+	Deprecated  bool                     `json:"deprecated"`
+	Parameters  []*parameter             `json:"parameters"`
+	RequestBody *requestBody             `json:"requestBody"`
+	Responses   map[string]*responseBody `json:"responses"`
+}
+
+type requestBody struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*mediaType `json:"content"`
+}
+
+type responseBody struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// fileModel, typeModel, and the other models are what the template renders.
+type fileModel struct {
+	Package string
+	Title   string
+	Version string
+	Types   []*typeModel
+	Methods []*methodModel
+	UsesFmt bool
+	UsesURL bool
+}
+
+type typeModel struct {
+	Name       string
+	Comment    []string
+	Fields     []*fieldModel
+	Underlying string
+}
+
+type fieldModel struct {
+	Name     string
+	JSONName string
+	Type     string
+	Required bool
+	Comment  []string
+}
+
+type methodModel struct {
+	Name           string
+	Comment        []string
+	HTTPMethod     string
+	PathExpression string
+	PathArgs       []*argumentModel
+	ParamsType     string
+	QueryParams    []*queryParamModel
+	BodyType       string
+	ResultType     string
+}
+
+type argumentModel struct {
+	Name string
+	Type string
+}
+
+type queryParamModel struct {
+	Name     string
+	Field    string
+	Type     string
+	IsSlice  bool
+	Required bool
+	Comment  []string
+}
+
+// generator generates a client from a document; it iterates over maps in
+// sorted order so that its output is deterministic.
+type generator struct {
+	document    *document
+	file        *fileModel
+	typeNames   map[string]bool
+	methodNames map[string]bool
+}
+
+// generate generates the gofmt-ed source of a client package with
+// the specified name from the specified OpenAPI 3 document (in JSON).
+func generate(documentJSON []byte, packageName string) ([]byte, error) {
+	document := &document{}
+	if err := json.Unmarshal(documentJSON, document); err != nil {
+		return nil, err
+	}
+	g := &generator{
+		document:    document,
+		file:        &fileModel{Package: packageName, Title: document.Info.Title, Version: document.Info.Version},
+		typeNames:   map[string]bool{"Client": true},
+		methodNames: map[string]bool{},
+	}
+	if g.file.Title == "" {
+		g.file.Title = "REST"
+	}
+	for _, name := range sortedKeys(document.Components.Schemas) {
+		g.typeNames[exportedName(name)] = true
+	}
+	for _, name := range sortedKeys(document.Components.Schemas) {
+		g.generateSchemaType(exportedName(name), "the "+name, document.Components.Schemas[name])
+	}
+	for _, path := range sortedKeys(document.Paths) {
+		if err := g.generatePath(path, document.Paths[path]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(g.file.Types, func(i, j int) bool { return g.file.Types[i].Name < g.file.Types[j].Name })
+
+	buffer := &bytes.Buffer{}
+	if err := sourceFileTemplate.Execute(buffer, g.file); err != nil {
+		return nil, err
+	}
+	source, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid source: %s\n%s", err, buffer.String())
+	}
+	return source, nil
+}
+
+// generateSchemaType generates a named type for the specified schema, which is
+// described by source (e.g., "the Pet" or "an inline").
+func (g *generator) generateSchemaType(name, source string, s *schema) {
+	model := &typeModel{Name: name, Comment: newComment(name+" is generated from "+source+" schema.", s.Description)}
+	g.file.Types = append(g.file.Types, model)
+	if !isStruct(s) {
+		model.Underlying = g.goType(s, name+"Value")
+		return
+	}
+	required := map[string]bool{}
+	for _, propertyName := range s.Required {
+		required[propertyName] = true
+	}
+	fieldNames := map[string]bool{}
+	for _, propertyName := range sortedKeys(s.Properties) {
+		property := s.Properties[propertyName]
+		fieldName := uniqueName(exportedName(propertyName), fieldNames)
+		model.Fields = append(model.Fields, &fieldModel{
+			Name:     fieldName,
+			JSONName: propertyName,
+			Type:     g.goType(property, name+fieldName),
+			Required: required[propertyName],
+			Comment:  newComment(property.Description),
+		})
+	}
+}
+
+// goType returns the Go type of the specified schema; inline object schemas
+// are generated as types named after the specified hint.
+func (g *generator) goType(s *schema, hint string) string {
+	if s == nil {
+		return "interface{}"
+	}
+	if s.Ref != "" {
+		name := refName(s.Ref)
+		if referenced := g.document.Components.Schemas[name]; referenced != nil && isStruct(referenced) {
+			return "*" + exportedName(name)
+		}
+		return exportedName(name)
+	}
+	if len(s.AllOf) == 1 {
+		return g.goType(s.AllOf[0], hint)
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "byte" {
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if s.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, hint+"Item")
+	}
+	if isStruct(s) {
+		name := uniqueName(hint, g.typeNames)
+		g.generateSchemaType(name, "an inline", s)
+		return "*" + name
+	}
+	if len(s.AdditionalProperties) > 0 && s.AdditionalProperties[0] == '{' {
+		valueSchema := &schema{}
+		if err := json.Unmarshal(s.AdditionalProperties, valueSchema); err == nil {
+			return "map[string]" + g.goType(valueSchema, hint+"Value")
+		}
+	}
+	if s.Type == "object" {
+		return "map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+func (g *generator) generatePath(path string, item map[string]json.RawMessage) error {
+	var sharedParameters []*parameter
+	if raw, found := item["parameters"]; found {
+		if err := json.Unmarshal(raw, &sharedParameters); err != nil {
+			return err
+		}
+	}
+	for _, method := range httpMethods {
+		raw, found := item[method]
+		if !found {
+			continue
+		}
+		operation := &operation{}
+		if err := json.Unmarshal(raw, operation); err != nil {
+			return fmt.Errorf("invalid %s %s operation: %s", strings.ToUpper(method), path, err)
+		}
+		if err := g.generateOperation(path, method, operation, sharedParameters); err != nil {
+			return fmt.Errorf("invalid %s %s operation: %s", strings.ToUpper(method), path, err)
+		}
+	}
+	return nil
+}
+
+func (g *generator) generateOperation(path, method string, operation *operation, sharedParameters []*parameter) error {
+	name := exportedName(operation.OperationID)
+	if operation.OperationID == "" {
+		name = exportedName(method + " " + strings.NewReplacer("{", "", "}", "").Replace(path))
+	}
+	name = uniqueName(name, g.methodNames)
+	model := &methodModel{
+		Name:       name,
+		Comment:    newComment(name+" calls "+strings.ToUpper(method)+" "+path+".", operation.Summary, operation.Description),
+		HTTPMethod: "http.Method" + exportedName(method),
+	}
+	if operation.Deprecated {
+		model.Comment = append(model.Comment, "", "Deprecated: the operation is deprecated by the API.")
+	}
+
+	parameters, err := g.mergeParameters(sharedParameters, operation.Parameters)
+	if err != nil {
+		return err
+	}
+	if err := g.generateParameters(model, path, parameters); err != nil {
+		return err
+	}
+
+	if operation.RequestBody != nil {
+		body, err := g.resolveRequestBody(operation.RequestBody)
+		if err != nil {
+			return err
+		}
+		if bodySchema := jsonSchema(body.Content); bodySchema != nil {
+			model.BodyType = g.goType(bodySchema, name+"Request")
+		}
+	}
+	for _, statusCode := range sortedKeys(operation.Responses) {
+		if !strings.HasPrefix(statusCode, "2") {
+			continue
+		}
+		response, err := g.resolveResponse(operation.Responses[statusCode])
+		if err != nil {
+			return err
+		}
+		if resultSchema := jsonSchema(response.Content); resultSchema != nil {
+			model.ResultType = g.goType(resultSchema, name+"Response")
+		}
+		break
+	}
+	g.file.Methods = append(g.file.Methods, model)
+	return nil
+}
+
+// mergeParameters resolves the specified parameters; operation parameters
+// override the path's shared parameters with the same name and location.
+func (g *generator) mergeParameters(shared, own []*parameter) ([]*parameter, error) {
+	var merged []*parameter
+	indexes := map[string]int{}
+	for _, p := range append(append([]*parameter(nil), shared...), own...) {
+		resolved, err := g.resolveParameter(p)
+		if err != nil {
+			return nil, err
+		}
+		key := resolved.In + ":" + resolved.Name
+		if index, found := indexes[key]; found {
+			merged[index] = resolved
+			continue
+		}
+		indexes[key] = len(merged)
+		merged = append(merged, resolved)
+	}
+	return merged, nil
+}
+
+func (g *generator) generateParameters(model *methodModel, path string, parameters []*parameter) error {
+	pathParameters := map[string]*parameter{}
+	queryParameters := []*parameter{}
+	for _, p := range parameters {
+		switch p.In {
+		case "path":
+			pathParameters[p.Name] = p
+		case "query":
+			queryParameters = append(queryParameters, p)
+		} // header and cookie parameters are left to round trippers
+	}
+
+	expression := []string{}
+	literal := strings.TrimPrefix(path, "/") // the base URL of REST clients ends with a slash
+	argumentNames := map[string]bool{}
+	for literal != "" {
+		start := strings.IndexRune(literal, '{')
+		if start < 0 {
+			expression = append(expression, fmt.Sprintf("%q", literal))
+			break
+		}
+		end := strings.IndexRune(literal[start:], '}')
+		if end < 0 {
+			return errors.New("unterminated path parameter")
+		}
+		if start > 0 {
+			expression = append(expression, fmt.Sprintf("%q", literal[:start]))
+		}
+		parameterName := literal[start+1 : start+end]
+		p, found := pathParameters[parameterName]
+		if !found {
+			return errors.New("undeclared path parameter: " + parameterName)
+		}
+		argument := &argumentModel{Name: uniqueName(argumentName(parameterName), argumentNames), Type: g.goType(p.Schema, "")}
+		model.PathArgs = append(model.PathArgs, argument)
+		expression = append(expression, "url.PathEscape(fmt.Sprint("+argument.Name+"))")
+		g.file.UsesFmt, g.file.UsesURL = true, true
+		literal = literal[start+end+1:]
+	}
+	if len(expression) == 0 {
+		expression = append(expression, `""`)
+	}
+	model.PathExpression = strings.Join(expression, " + ")
+
+	if len(queryParameters) == 0 {
+		return nil
+	}
+	sort.Slice(queryParameters, func(i, j int) bool { return queryParameters[i].Name < queryParameters[j].Name })
+	model.ParamsType = uniqueName(model.Name+"Params", g.typeNames)
+	fieldNames := map[string]bool{}
+	for _, p := range queryParameters {
+		if !g.isQueryable(p.Schema) {
+			return fmt.Errorf("unsupported query parameter %s: only primitives and arrays of them are supported", p.Name)
+		}
+		queryParam := &queryParamModel{
+			Name:     p.Name,
+			Field:    uniqueName(exportedName(p.Name), fieldNames),
+			Type:     g.goType(p.Schema, model.ParamsType+exportedName(p.Name)),
+			Required: p.Required,
+			Comment:  newComment(p.Description),
+		}
+		queryParam.IsSlice = strings.HasPrefix(queryParam.Type, "[]")
+		if !queryParam.IsSlice && !queryParam.Required && !strings.HasPrefix(queryParam.Type, "*") {
+			queryParam.Type = "*" + queryParam.Type
+		}
+		model.QueryParams = append(model.QueryParams, queryParam)
+	}
+	g.file.UsesFmt, g.file.UsesURL = true, true
+	return nil
+}
+
+// isQueryable returns whether the specified schema is of a primitive type or
+// an array of a primitive type, whose values can be formatted as query values;
+// objects cannot (styles like deepObject are not supported).
+func (g *generator) isQueryable(s *schema) bool {
+	s = g.resolveSchema(s)
+	if s != nil && s.Type == "array" {
+		s = g.resolveSchema(s.Items)
+	}
+	if s == nil {
+		return false
+	}
+	switch s.Type {
+	case "string":
+		return s.Format != "byte"
+	case "integer", "number", "boolean":
+		return true
+	}
+	return false
+}
+
+// resolveSchema follows the references of the specified schema (and its
+// single allOf subschemas) to the schema that defines its type.
+func (g *generator) resolveSchema(s *schema) *schema {
+	visited := map[string]bool{}
+	for s != nil {
+		if s.Ref != "" {
+			if visited[s.Ref] {
+				return nil // circular
+			}
+			visited[s.Ref] = true
+			s = g.document.Components.Schemas[refName(s.Ref)]
+		} else if len(s.AllOf) == 1 {
+			s = s.AllOf[0]
+		} else {
+			return s
+		}
+	}
+	return nil // unresolved
+}
+
+func (g *generator) resolveParameter(p *parameter) (*parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	if resolved := g.document.Components.Parameters[refName(p.Ref)]; resolved != nil {
+		return resolved, nil
+	}
+	return nil, errors.New("unresolved reference: " + p.Ref)
+}
+
+func (g *generator) resolveRequestBody(body *requestBody) (*requestBody, error) {
+	if body.Ref == "" {
+		return body, nil
+	}
+	if resolved := g.document.Components.RequestBodies[refName(body.Ref)]; resolved != nil {
+		return resolved, nil
+	}
+	return nil, errors.New("unresolved reference: " + body.Ref)
+}
+
+func (g *generator) resolveResponse(response *responseBody) (*responseBody, error) {
+	if response.Ref == "" {
+		return response, nil
+	}
+	if resolved := g.document.Components.Responses[refName(response.Ref)]; resolved != nil {
+		return resolved, nil
+	}
+	return nil, errors.New("unresolved reference: " + response.Ref)
+}
+
+// jsonSchema returns the schema of the JSON media type in the specified
+// content, or nil if there's none.
+func jsonSchema(content map[string]*mediaType) *schema {
+	for _, contentType := range sortedKeys(content) {
+		if contentType == "application/json" || strings.HasSuffix(contentType, "+json") {
+			if content[contentType].Schema != nil {
+				return content[contentType].Schema
+			}
+		}
+	}
+	return nil
+}
+
+func isStruct(s *schema) bool {
+	return s.Ref == "" && len(s.Properties) > 0 && (s.Type == "" || s.Type == "object")
+}
+
+// refName returns the name of the component that the specified local
+// reference (e.g., "#/components/schemas/Pet") refers to.
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// exportedName converts the specified name (e.g., "pet_id" or "petId") into
+// an exported Go identifier (e.g., "PetID").
+func exportedName(name string) string {
+	buffer := &bytes.Buffer{}
+	for _, word := range splitWords(name) {
+		if upper := strings.ToUpper(word); commonInitialisms[upper] {
+			buffer.WriteString(upper)
+		} else {
+			runes := []rune(word)
+			buffer.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+		}
+	}
+	if buffer.Len() == 0 {
+		return "Value"
+	}
+	if identifier := buffer.String(); !unicode.IsDigit([]rune(identifier)[0]) {
+		return identifier
+	}
+	return "N" + buffer.String()
+}
+
+// argumentName converts the specified name into an unexported Go identifier
+// that's not a keyword and does not shadow a local variable in generated code.
+func argumentName(name string) string {
+	exported := exportedName(name)
+	words := splitWords(exported)
+	first := words[0]
+	if commonInitialisms[first] {
+		first = strings.ToLower(first)
+	} else {
+		runes := []rune(first)
+		first = strings.ToLower(string(runes[0])) + string(runes[1:])
+	}
+	identifier := first + strings.TrimPrefix(exported, words[0])
+	if token.Lookup(identifier).IsKeyword() || reservedArgumentNames[identifier] {
+		return identifier + "Param"
+	}
+	return identifier
+}
+
+// splitWords splits the specified name into words at non-alphanumeric runes
+// and at lower-to-upper case transitions (keeping runs of upper case together).
+func splitWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words, word = append(words, string(word)), nil
+			}
+			continue
+		}
+		if len(word) > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				words, word = append(words, string(word)), nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// uniqueName returns the specified name, or the name with the smallest numeric
+// suffix that makes it unique, and marks the returned name as taken.
+func uniqueName(name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// newComment splits the specified paragraphs into comment lines; empty
+// paragraphs are skipped.
+func newComment(paragraphs ...string) []string {
+	var lines []string
+	for _, paragraph := range paragraphs {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		for _, line := range strings.Split(paragraph, "\n") {
+			lines = append(lines, strings.TrimRightFunc(line, unicode.IsSpace))
+		}
+	}
+	return lines
+}
+
+// sortedKeys returns the sorted keys of the specified map, whose keys must be
+// strings.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+const testDocument = `{
+  "openapi": "3.0.0",
+  "info": {"title": "Pet Store", "version": "1.0.0"},
+  "paths": {
+    "/pets/{petId}": {
+      "parameters": [{"$ref": "#/components/parameters/PetID"}],
+      "get": {
+        "operationId": "showPetById",
+        "summary": "Shows a pet.",
+        "parameters": [
+          {"name": "fields", "in": "query", "schema": {"type": "array", "items": {"type": "string"}}},
+          {"name": "status", "in": "query", "schema": {"$ref": "#/components/schemas/Status"}},
+          {"name": "limit", "in": "query", "required": true, "schema": {"type": "integer", "format": "int32"}}
+        ],
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}}
+      },
+      "put": {
+        "operationId": "update_pet",
+        "requestBody": {"content": {"application/json": {"schema": {"type": "object", "properties": {"name": {"type": "string"}}}}}},
+        "responses": {"204": {"description": "updated"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Pet": {
+        "type": "object",
+        "required": ["id"],
+        "properties": {"id": {"type": "integer"}, "owner": {"type": "object", "properties": {"name": {"type": "string"}}}}
+      },
+      "Status": {"type": "string"}
+    },
+    "parameters": {"PetID": {"name": "petId", "in": "path", "required": true, "schema": {"type": "string"}}}
+  }
+}`
+
+func TestGenerate(t *testing.T) {
+	source, err := generate([]byte(testDocument), "petstore")
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+	generated := string(source)
+	for _, expected := range []string{
+		"package petstore // auto-generated using scripts/openapi - DO NOT EDIT!\n",
+		"type Pet struct {\n\tID    int64     `json:\"id\"`\n\tOwner *PetOwner `json:\"owner,omitempty\"`\n}\n",
+		"type PetOwner struct {\n\tName string `json:\"name,omitempty\"`\n}\n",
+		"type UpdatePetRequest struct {\n",
+		"// ShowPetByID calls GET /pets/{petId}.\n//\n// Shows a pet.\n" +
+			"func (client *Client) ShowPetByID(petID string, params *ShowPetByIDParams) (*Pet, error) {\n",
+		`path := "pets/" + url.PathEscape(fmt.Sprint(petID))`,
+		`query.Add("fields", fmt.Sprint(value))`,
+		`query.Set("limit", fmt.Sprint(params.Limit))`,
+		`query.Set("status", fmt.Sprint(*params.Status))`,
+		"_, err := client.restClient.Do(http.MethodGet, path, nil, &result)\n",
+		"func (client *Client) UpdatePet(petID string, body *UpdatePetRequest) error {\n",
+		"_, err := client.restClient.Do(http.MethodPut, path, body, nil)\n",
+	} {
+		assert.For(t, expected).ThatActual(strings.Contains(generated, expected)).IsTrue()
+	}
+
+	typeCheck(t, source)
+
+	for i := 0; i < 10; i++ {
+		again, _ := generate([]byte(testDocument), "petstore")
+		assert.For(t, i).ThatActualString(string(again)).Equals(generated)
+	}
+}
+
+func TestGenerate_invalidDocuments(t *testing.T) {
+	cases := []struct {
+		id       string
+		document string
+	}{
+		{"invalid JSON", `{`},
+		{"undeclared path parameter", `{"paths": {"/pets/{id}": {"get": {}}}}`},
+		{"unresolved reference", `{"paths": {"/pets": {"get": {"parameters": [{"$ref": "#/components/parameters/X"}]}}}}`},
+		{"object query parameter", `{"paths": {"/pets": {"get": {"parameters": [{"name": "filter", "in": "query",
+			"schema": {"type": "object", "properties": {"name": {"type": "string"}}}}]}}}}`},
+		{"array of objects query parameter", `{"paths": {"/pets": {"get": {"parameters": [{"name": "filter", "in": "query",
+			"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Filter"}}}]}}},
+			"components": {"schemas": {"Filter": {"type": "object"}}}}`},
+	}
+
+	for _, c := range cases {
+		_, err := generate([]byte(c.document), "client")
+		assert.For(t, c.id).ThatActual(err).IsNotNil()
+	}
+}
+
+// typeCheck verifies that the specified generated source is gofmt-ed and that
+// it compiles.
+func typeCheck(t *testing.T, source []byte) {
+	formatted, err := format.Source(source)
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActualString(string(formatted)).Equals(string(source))
+	}
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "client.go", source, 0)
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+	config := &types.Config{Importer: importer.For("source", nil)}
+	_, err = config.Check("petstore", fileSet, []*ast.File{file}, nil)
+	assert.For(t).ThatActual(err).IsNil()
+}
+
+func TestNames(t *testing.T) {
+	cases := []struct {
+		name             string
+		expectedExported string
+		expectedArgument string
+	}{
+		{"petId", "PetID", "petID"},
+		{"pet_id", "PetID", "petID"},
+		{"id", "ID", "id"},
+		{"homepage-url", "HomepageURL", "homepageURL"},
+		{"HTTPServer", "HTTPServer", "httpServer"},
+		{"type", "Type", "typeParam"},
+		{"body", "Body", "bodyParam"},
+		{"2fa", "N2fa", "n2fa"},
+		{"", "Value", "value"},
+	}
+
+	for _, c := range cases {
+		assert.For(t, c.name).ThatActualString(exportedName(c.name)).Equals(c.expectedExported)
+		assert.For(t, c.name).ThatActualString(argumentName(c.name)).Equals(c.expectedArgument)
+	}
+}
@@ -0,0 +1,38 @@
+// This program generates REST clients from OpenAPI 3 documents.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	inputPath := flag.String("i", "", "path of the OpenAPI 3 document (JSON) to generate a client from.")
+	outputPath := flag.String("o", "", "path of the generated source file (standard output by default).")
+	packageName := flag.String("p", "client", "name of auto-generated package.")
+	flag.Parse()
+	if *inputPath == "" {
+		flag.Usage()
+		fmt.Println(`Example: go run scripts/openapi/*.go -i petstore.json -p petstore -o petstore/client.go`)
+		os.Exit(1)
+	}
+
+	document, err := ioutil.ReadFile(*inputPath)
+	panicIfNotNil(err)
+	source, err := generate(document, *packageName)
+	panicIfNotNil(err)
+	if *outputPath == "" {
+		_, err = os.Stdout.Write(source)
+	} else {
+		err = ioutil.WriteFile(*outputPath, source, 0644)
+	}
+	panicIfNotNil(err)
+}
+
+func panicIfNotNil(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
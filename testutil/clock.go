@@ -0,0 +1,134 @@
+package testutil
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry/clock"
+)
+
+// FakeClock is a clock whose time only passes when it's advanced manually
+// (for test purposes only); it's safe for concurrent use.
+type FakeClock struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+// NewFakeClock creates a fake clock whose time is the specified one.
+func NewFakeClock(now time.Time) *FakeClock {
+	fake := &FakeClock{now: now}
+	fake.cond = sync.NewCond(&fake.mutex)
+	return fake
+}
+
+// Now returns the fake time.
+func (fake *FakeClock) Now() time.Time {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.now
+}
+
+// Sleep blocks until the clock is advanced by at least the specified duration.
+func (fake *FakeClock) Sleep(duration time.Duration) {
+	<-fake.After(duration)
+}
+
+// After returns a channel on which the fake time is sent once the clock is
+// advanced by at least the specified duration.
+func (fake *FakeClock) After(duration time.Duration) <-chan time.Time {
+	return fake.NewTimer(duration).C()
+}
+
+// NewTimer creates a timer that fires once the clock is advanced by at least
+// the specified duration; it fires immediately if the duration isn't positive.
+func (fake *FakeClock) NewTimer(duration time.Duration) clock.Timer {
+	timer := &fakeTimer{clock: fake, c: make(chan time.Time, 1)}
+	timer.Reset(duration)
+	return timer
+}
+
+// Advance moves the clock forward by the specified duration and fires
+// the timers that are due, in the order of their deadlines.
+func (fake *FakeClock) Advance(duration time.Duration) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.now = fake.now.Add(duration)
+	sort.SliceStable(fake.timers, func(i, j int) bool { return fake.timers[i].deadline.Before(fake.timers[j].deadline) })
+	for len(fake.timers) > 0 && !fake.timers[0].deadline.After(fake.now) {
+		fake.timers[0].fire()
+		fake.timers = fake.timers[1:]
+	}
+}
+
+// Timers returns the number of timers that are waiting to fire (including
+// the ones that goroutines are sleeping or waiting on).
+func (fake *FakeClock) Timers() int {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return len(fake.timers)
+}
+
+// WaitForTimers blocks until at least the specified number of timers are
+// waiting to fire; it's useful to wait for goroutines to start sleeping
+// before advancing the clock.
+func (fake *FakeClock) WaitForTimers(count int) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	for len(fake.timers) < count {
+		fake.cond.Wait()
+	}
+}
+
+func (timer *fakeTimer) C() <-chan time.Time {
+	return timer.c
+}
+
+func (timer *fakeTimer) Stop() bool {
+	timer.clock.mutex.Lock()
+	defer timer.clock.mutex.Unlock()
+	return timer.remove()
+}
+
+func (timer *fakeTimer) Reset(duration time.Duration) bool {
+	fake := timer.clock
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	active := timer.remove()
+	timer.deadline = fake.now.Add(duration)
+	if duration <= 0 {
+		timer.fire()
+		return active
+	}
+	fake.timers = append(fake.timers, timer)
+	fake.cond.Broadcast()
+	return active
+}
+
+// fire sends the clock's time on the timer's channel; the clock must be locked.
+func (timer *fakeTimer) fire() {
+	select {
+	case timer.c <- timer.clock.now:
+	default: // like time.Timer's, the channel holds one time at most
+	}
+}
+
+// remove removes the timer from the clock's timers, if it's there; the clock
+// must be locked.
+func (timer *fakeTimer) remove() bool {
+	for i, t := range timer.clock.timers {
+		if t == timer {
+			timer.clock.timers = append(timer.clock.timers[:i], timer.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
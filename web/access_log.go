@@ -0,0 +1,268 @@
+package web
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+)
+
+const (
+	truncatedBodySuffix      = "...(truncated)"
+	unredactableBodyLogValue = "(omitted: truncated JSON cannot be redacted)"
+)
+
+type retryAttemptContextKey struct{}
+
+// WithRetryAttempt returns a copy of the specified context that carries
+// the specified (1-based) attempt number of the request it's attached to;
+// retry helpers use it so that access logs can tell retries apart.
+func WithRetryAttempt(parent context.Context, attempt int) context.Context {
+	return context.WithValue(parent, retryAttemptContextKey{}, attempt)
+}
+
+// RetryAttempt returns the attempt number carried by the specified context,
+// or 1 if the context does not carry one.
+func RetryAttempt(ctx context.Context) int {
+	if attempt, ok := ctx.Value(retryAttemptContextKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// AccessLogConfig configures the structured logging round tripper (see
+// NewAccessLoggerRoundTripper). It's configured using its With* methods,
+// which return the modified config to allow chaining.
+type AccessLogConfig struct {
+	statusClassLevels   map[int]log.Level
+	errorLevel          log.Level
+	maxBodyLength       int
+	successSamplingRate float64
+	slowThreshold       time.Duration
+	redactor            *Redactor
+	random              func() float64 `test-hook:"verify-unexported"`
+}
+
+// NewAccessLogConfig creates a config that logs informational (1xx),
+// successful (2xx), and redirection (3xx) calls at the info level; client
+// errors (4xx) at the warn level; and server errors (5xx) and failed calls
+// at the error level. By default, all calls are logged without bodies, and
+// no call is considered slow.
+func NewAccessLogConfig() *AccessLogConfig {
+	return &AccessLogConfig{
+		statusClassLevels: map[int]log.Level{
+			1: log.InfoLevel,
+			2: log.InfoLevel,
+			3: log.InfoLevel,
+			4: log.WarnLevel,
+			5: log.ErrorLevel,
+		},
+		errorLevel:          log.ErrorLevel,
+		successSamplingRate: 1,
+		redactor:            NewDefaultRedactor(),
+		random:              rand.Float64,
+	}
+}
+
+// WithStatusClassLevel configures the level at which calls whose responses
+// have status codes of the specified class (e.g., 4 for 4xx) are logged.
+func (config *AccessLogConfig) WithStatusClassLevel(statusClass int, level log.Level) *AccessLogConfig {
+	config.statusClassLevels[statusClass] = level
+	return config
+}
+
+// WithErrorLevel configures the level at which calls that fail without
+// a response (e.g., due to connection errors) are logged.
+func (config *AccessLogConfig) WithErrorLevel(level log.Level) *AccessLogConfig {
+	config.errorLevel = level
+	return config
+}
+
+// WithMaxBodyLength configures the maximum number of bytes of (redacted)
+// request and response bodies to log; longer bodies are truncated.
+// Bodies are not logged when maxBodyLength is not positive (the default).
+func (config *AccessLogConfig) WithMaxBodyLength(maxBodyLength int) *AccessLogConfig {
+	config.maxBodyLength = maxBodyLength
+	return config
+}
+
+// WithSuccessSamplingRate configures the fraction of successful calls
+// (ones that get a response with a status code less than 400 and are not
+// slow) to log; rates outside [0, 1] are clamped.
+func (config *AccessLogConfig) WithSuccessSamplingRate(rate float64) *AccessLogConfig {
+	if rate < 0 {
+		rate = 0
+	} else if rate > 1 {
+		rate = 1
+	}
+	config.successSamplingRate = rate
+	return config
+}
+
+// WithSlowThreshold configures the duration after which calls are considered
+// slow; slow calls are always logged, at the warn level or higher.
+// A non-positive threshold (the default) disables slow call detection.
+func (config *AccessLogConfig) WithSlowThreshold(threshold time.Duration) *AccessLogConfig {
+	config.slowThreshold = threshold
+	return config
+}
+
+// WithRedactor configures the redactor used to censor logged bodies.
+func (config *AccessLogConfig) WithRedactor(redactor *Redactor) *AccessLogConfig {
+	config.redactor = redactor
+	return config
+}
+
+// NewAccessLoggerRoundTripper creates a RoundTripper that decorates another
+// round tripper by using the specified leveled logger to log one line per call
+// with the call's details (method, host, path, status code, duration, sizes,
+// and retry attempt) as separate fields, as configured by the specified config.
+func NewAccessLoggerRoundTripper(
+	roundTripper http.RoundTripper, logger log.LeveledLogger, config *AccessLogConfig) http.RoundTripper {
+	return &accessLoggingRoundTripper{innerRoundTripper: roundTripper, logger: logger, config: config}
+}
+
+type accessLoggingRoundTripper struct {
+	innerRoundTripper http.RoundTripper
+	logger            log.LeveledLogger
+	config            *AccessLogConfig
+}
+
+func (roundTripper *accessLoggingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	var requestBody *bodyPrefixRecorder
+	if roundTripper.config.maxBodyLength > 0 {
+		// the body is recorded as it's sent, so that large requests aren't buffered just to be logged
+		requestBody = recordBodyPrefix(&request.Body, roundTripper.config.maxBodyLength)
+	}
+
+	start := time.Now()
+	response, err := roundTripper.innerRoundTripper.RoundTrip(request)
+	duration := time.Since(start)
+
+	level, slow, shouldLog := roundTripper.evaluate(response, err, duration)
+	if !shouldLog {
+		return response, err
+	}
+
+	args := []interface{}{
+		"method", request.Method,
+		"host", request.URL.Host,
+		"path", request.URL.Path,
+		"duration", duration,
+		"requestSize", request.ContentLength,
+		"attempt", RetryAttempt(request.Context()),
+	}
+	if slow {
+		args = append(args, "slow", true)
+	}
+	if requestBody != nil {
+		prefix, truncated := requestBody.recorded()
+		args = append(args, "requestBody", roundTripper.formatBody(request.Header, prefix, truncated))
+	}
+	if err != nil {
+		args = append(args, "err", err)
+	}
+	if response != nil {
+		args = append(args, "status", response.StatusCode, "responseSize", response.ContentLength)
+		if roundTripper.config.maxBodyLength > 0 {
+			// only a prefix is read, so that large responses aren't buffered just to be logged
+			responseBody, truncated, peekErr := peekBody(&response.Body, roundTripper.config.maxBodyLength)
+			if peekErr == nil && responseBody != nil {
+				args = append(args, "responseBody", roundTripper.formatBody(response.Header, responseBody, truncated))
+			}
+		}
+	}
+	level.Log(roundTripper.logger, "HTTP call", args...)
+	return response, err
+}
+
+// evaluate determines the level to log a call at, whether it's slow,
+// and whether it should be logged at all.
+func (roundTripper *accessLoggingRoundTripper) evaluate(
+	response *http.Response, err error, duration time.Duration) (level log.Level, slow bool, shouldLog bool) {
+	config := roundTripper.config
+	slow = config.slowThreshold > 0 && duration >= config.slowThreshold
+	if response == nil {
+		level = config.errorLevel
+	} else if statusClassLevel, found := config.statusClassLevels[response.StatusCode/100]; found {
+		level = statusClassLevel
+	} else {
+		level = log.ErrorLevel
+	}
+
+	if slow {
+		if level < log.WarnLevel {
+			level = log.WarnLevel
+		}
+		return level, slow, true
+	}
+
+	successful := err == nil && response != nil && response.StatusCode < http.StatusBadRequest
+	if successful && config.successSamplingRate < 1 {
+		return level, slow, config.random() < config.successSamplingRate
+	}
+	return level, slow, true
+}
+
+// formatBody redacts and truncates the specified body for logging; truncated
+// denotes whether the body is only a prefix of the actual one.
+func (roundTripper *accessLoggingRoundTripper) formatBody(header http.Header, body []byte, truncated bool) string {
+	redactor, contentType := roundTripper.config.redactor, header.Get("Content-Type")
+	if truncated && redactor.redactsJSONFields(contentType) {
+		return unredactableBodyLogValue
+	}
+	redacted := redactor.RedactBody(contentType, body)
+	if len(redacted) > roundTripper.config.maxBodyLength {
+		return string(redacted[:roundTripper.config.maxBodyLength]) + truncatedBodySuffix
+	} else if truncated {
+		return string(redacted) + truncatedBodySuffix
+	}
+	return string(redacted)
+}
+
+// bodyPrefixRecorder records up to maxLength bytes of the body it wraps as
+// they're read (e.g., by the transport sending a request), and whether more
+// bytes were read than recorded.
+type bodyPrefixRecorder struct {
+	io.ReadCloser
+	mutex     sync.Mutex
+	maxLength int
+	prefix    []byte
+	truncated bool
+}
+
+// recordBodyPrefix replaces the specified body with a recorder of its prefix;
+// it returns nil if there's no body.
+func recordBodyPrefix(body *io.ReadCloser, maxLength int) *bodyPrefixRecorder {
+	if *body == nil || *body == http.NoBody {
+		return nil
+	}
+	recorder := &bodyPrefixRecorder{ReadCloser: *body, maxLength: maxLength}
+	*body = recorder
+	return recorder
+}
+
+func (recorder *bodyPrefixRecorder) Read(b []byte) (int, error) {
+	n, err := recorder.ReadCloser.Read(b)
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if remaining := recorder.maxLength - len(recorder.prefix); n > remaining {
+		recorder.prefix = append(recorder.prefix, b[:remaining]...)
+		recorder.truncated = true
+	} else {
+		recorder.prefix = append(recorder.prefix, b[:n]...)
+	}
+	return n, err
+}
+
+// recorded returns a copy of the prefix recorded so far and whether more
+// bytes were read.
+func (recorder *bodyPrefixRecorder) recorded() ([]byte, bool) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return append([]byte{}, recorder.prefix...), recorder.truncated
+}
@@ -0,0 +1,251 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/tester/assert"
+)
+
+func TestRetryAttempt(t *testing.T) {
+	assert.For(t).ThatActual(RetryAttempt(context.Background())).Equals(1)
+	assert.For(t).ThatActual(RetryAttempt(WithRetryAttempt(context.Background(), 3))).Equals(3)
+}
+
+func TestAccessLoggerRoundTripper_levels(t *testing.T) {
+	cases := []struct {
+		id            string
+		statusCode    int
+		err           error
+		expectedLevel string
+	}{
+		{"success", 200, nil, "info"},
+		{"redirection", 302, nil, "info"},
+		{"client error", 404, nil, "warn"},
+		{"server error", 503, nil, "error"},
+		{"no response", 0, errors.New("connection reset"), "error"},
+	}
+
+	for _, c := range cases {
+		var response *http.Response
+		if c.statusCode > 0 {
+			response = &http.Response{StatusCode: c.statusCode, ContentLength: 3, Body: ioutil.NopCloser(strings.NewReader("ack"))}
+		}
+		logCapturer := testAccessLoggerRoundTripper(t, NewAccessLogConfig(), response, c.err)
+		entries := capturesByLevel(logCapturer)
+		if assert.For(t, c.id).ThatActual(len(entries[c.expectedLevel])).Equals(1).Passed() {
+			fields := toFields(entries[c.expectedLevel][0])
+			assert.For(t, c.id).ThatActual(fields["method"]).Equals("POST")
+			assert.For(t, c.id).ThatActual(fields["host"]).Equals("host")
+			assert.For(t, c.id).ThatActual(fields["path"]).Equals("/calls")
+			assert.For(t, c.id).ThatActual(fields["requestSize"]).Equals(int64(18))
+			assert.For(t, c.id).ThatActual(fields["attempt"]).Equals(2)
+			assert.For(t, c.id).ThatActual(fields["err"]).Equals(c.err)
+			if response != nil {
+				assert.For(t, c.id).ThatActual(fields["status"]).Equals(c.statusCode)
+				assert.For(t, c.id).ThatActual(fields["responseSize"]).Equals(int64(3))
+			}
+		}
+	}
+}
+
+func TestAccessLoggerRoundTripper_customLevel(t *testing.T) {
+	config := NewAccessLogConfig().WithStatusClassLevel(4, log.DebugLevel)
+	response := &http.Response{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader(""))}
+	entries := capturesByLevel(testAccessLoggerRoundTripper(t, config, response, nil))
+	assert.For(t).ThatActual(len(entries["debug"])).Equals(1)
+	assert.For(t).ThatActual(len(entries["warn"])).Equals(0)
+}
+
+func TestAccessLoggerRoundTripper_sampling(t *testing.T) {
+	cases := []struct {
+		id            string
+		statusCode    int
+		random        float64
+		expectedCount int
+	}{
+		{"sampled in", 200, 0.1, 1},
+		{"sampled out", 200, 0.9, 0},
+		{"errors are not sampled", 500, 0.9, 1},
+	}
+
+	for _, c := range cases {
+		config := NewAccessLogConfig().WithSuccessSamplingRate(0.5)
+		random := c.random
+		config.random = func() float64 { return random }
+		response := &http.Response{StatusCode: c.statusCode, Body: ioutil.NopCloser(strings.NewReader(""))}
+		logCapturer := testAccessLoggerRoundTripper(t, config, response, nil)
+		actualCount := len(logCapturer.InfoCaptures) + len(logCapturer.ErrorCaptures)
+		assert.For(t, c.id).ThatActual(actualCount).Equals(c.expectedCount)
+	}
+}
+
+func TestAccessLoggerRoundTripper_slow(t *testing.T) {
+	config := NewAccessLogConfig().WithSlowThreshold(time.Millisecond).WithSuccessSamplingRate(0)
+	logCapturer := testutil.NewLogCapturer(false)
+	roundTripper := NewAccessLoggerRoundTripper(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		time.Sleep(2 * time.Millisecond)
+		return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}), logCapturer, config)
+
+	request, _ := http.NewRequest("GET", "http://host", nil)
+	_, err := roundTripper.RoundTrip(request)
+	assert.For(t).ThatActual(err).IsNil()
+	if assert.For(t).ThatActual(len(logCapturer.WarnCaptures)).Equals(1).Passed() {
+		assert.For(t).ThatActual(toFields(logCapturer.WarnCaptures[0])["slow"]).Equals(true)
+	}
+}
+
+func TestAccessLoggerRoundTripper_bodies(t *testing.T) {
+	config := NewAccessLogConfig().
+		WithMaxBodyLength(16).
+		WithRedactor(NewRedactor().WithJSONFields(nil, "password"))
+	response := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       ioutil.NopCloser(strings.NewReader("a response body longer than the limit")),
+	}
+	logCapturer := testAccessLoggerRoundTripper(t, config, response, nil)
+	if assert.For(t).ThatActual(len(logCapturer.InfoCaptures)).Equals(1).Passed() {
+		fields := toFields(logCapturer.InfoCaptures[0])
+		assert.For(t).ThatActual(fields["requestBody"]).Equals(unredactableBodyLogValue)
+		assert.For(t).ThatActual(fields["responseBody"]).Equals("a response body " + truncatedBodySuffix)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActualString(string(body)).Equals("a response body longer than the limit")
+}
+
+func TestAccessLoggerRoundTripper_largeResponseBody(t *testing.T) {
+	config := NewAccessLogConfig().WithMaxBodyLength(16).WithRedactor(NewRedactor().WithJSONFields(nil, "password"))
+	cases := []struct {
+		id                 string
+		contentType        string
+		body               string
+		expectedLoggedBody string
+	}{
+		{"text", "text/plain", strings.Repeat("a large response body ", 1000), "a large response" + truncatedBodySuffix},
+		{"json", "application/json", `{"password":"42","padding":"` + strings.Repeat("a", 1000) + `"}`,
+			unredactableBodyLogValue},
+		{"exact length", "text/plain", "sixteen bytes!!!", "sixteen bytes!!!"},
+	}
+	for _, c := range cases {
+		reader := &countingReader{Reader: strings.NewReader(c.body)}
+		response := &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"Content-Type": {c.contentType}},
+			Body:       ioutil.NopCloser(reader),
+		}
+		logCapturer := testAccessLoggerRoundTripper(t, config, response, nil)
+		if assert.For(t, c.id).ThatActual(len(logCapturer.InfoCaptures)).Equals(1).Passed() {
+			fields := toFields(logCapturer.InfoCaptures[0])
+			assert.For(t, c.id).ThatActual(fields["responseBody"]).Equals(c.expectedLoggedBody)
+		}
+		assert.For(t, c.id).ThatActual(reader.read <= 17).IsTrue()
+
+		body, err := ioutil.ReadAll(response.Body)
+		assert.For(t, c.id).ThatActual(err).IsNil()
+		assert.For(t, c.id).ThatActualString(string(body)).Equals(c.body)
+	}
+}
+
+func TestAccessLoggerRoundTripper_largeRequestBody(t *testing.T) {
+	cases := []struct {
+		id                 string
+		samplingRate       float64
+		body               string
+		expectedLoggedBody interface{}
+	}{
+		{"large", 1, strings.Repeat("a large request body ", 1000), "a large request " + truncatedBodySuffix},
+		{"exact length", 1, "sixteen bytes!!!", "sixteen bytes!!!"},
+		{"not logged", 0, strings.Repeat("a large request body ", 1000), nil},
+	}
+	for _, c := range cases {
+		logCapturer := testutil.NewLogCapturer(true)
+		config := NewAccessLogConfig().WithMaxBodyLength(16).WithSuccessSamplingRate(c.samplingRate)
+		var sent string
+		roundTripper := NewAccessLoggerRoundTripper(roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			body, err := ioutil.ReadAll(request.Body)
+			sent = string(body)
+			return &http.Response{StatusCode: 200, Header: http.Header{}, Body: http.NoBody}, err
+		}), logCapturer, config)
+		request, _ := http.NewRequest("PUT", "http://host/uploads", strings.NewReader(c.body))
+		request.Header.Set("Content-Type", "text/plain")
+
+		_, err := roundTripper.RoundTrip(request)
+		assert.For(t, c.id).ThatActual(err).IsNil()
+		assert.For(t, c.id).ThatActualString(sent).Equals(c.body)
+		if c.expectedLoggedBody == nil {
+			assert.For(t, c.id).ThatActual(len(logCapturer.InfoCaptures)).Equals(0)
+		} else if assert.For(t, c.id).ThatActual(len(logCapturer.InfoCaptures)).Equals(1).Passed() {
+			fields := toFields(logCapturer.InfoCaptures[0])
+			assert.For(t, c.id).ThatActual(fields["requestBody"]).Equals(c.expectedLoggedBody)
+		}
+	}
+}
+
+// countingReader counts the bytes read from the reader it wraps.
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (reader *countingReader) Read(b []byte) (int, error) {
+	n, err := reader.Reader.Read(b)
+	reader.read += n
+	return n, err
+}
+
+func TestAccessLogConfig_HooksAreHidden(t *testing.T) {
+	assert.For(t).ThatType(reflect.TypeOf(AccessLogConfig{})).HidesTestHooks()
+}
+
+func testAccessLoggerRoundTripper(
+	t *testing.T, config *AccessLogConfig, mockResponse *http.Response, mockError error) *testutil.LogCapturer {
+	logCapturer := testutil.NewLogCapturer(true)
+	request, _ := http.NewRequest("POST", "http://host/calls", strings.NewReader(`{"password":"42"}`+"\n"))
+	request.Header.Set("Content-Type", "application/json")
+	request = request.WithContext(WithRetryAttempt(request.Context(), 2))
+	// like transports, the mock sends (i.e., reads) the request body
+	mock := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		ioutil.ReadAll(request.Body)
+		return mockResponse, mockError
+	})
+	roundTripper := NewAccessLoggerRoundTripper(mock, logCapturer, config)
+	_, err := roundTripper.RoundTrip(request)
+	assert.For(t).ThatActual(err).Equals(mockError)
+	return logCapturer
+}
+
+func capturesByLevel(logCapturer *testutil.LogCapturer) map[string][]*testutil.CapturedLogEntry {
+	return map[string][]*testutil.CapturedLogEntry{
+		"debug": logCapturer.DebugCaptures,
+		"info":  logCapturer.InfoCaptures,
+		"warn":  logCapturer.WarnCaptures,
+		"error": logCapturer.ErrorCaptures,
+	}
+}
+
+func toFields(entry *testutil.CapturedLogEntry) map[string]interface{} {
+	fields := map[string]interface{}{}
+	for i := 0; i+1 < len(entry.Arguments); i += 2 {
+		fields[entry.Arguments[i].(string)] = entry.Arguments[i+1]
+	}
+	return fields
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/voicera/gooseberry/log"
+)
+
+// ToCurlCommand renders the specified request as an equivalent curl command
+// that can be run to reproduce it. Sensitive data is censored using
+// the specified redactor (the default redactor if nil; see NewDefaultRedactor).
+// The request's body is restored so that it can still be sent.
+func ToCurlCommand(request *http.Request, redactor *Redactor) (string, error) {
+	if redactor == nil {
+		redactor = NewDefaultRedactor()
+	}
+	body, err := drainBody(&request.Body)
+	if err != nil {
+		return "", err
+	}
+
+	arguments := []string{"curl", "-X", quoteForShell(request.Method), quoteForShell(redactor.RedactURL(request.URL).String())}
+	if request.Host != "" && request.Host != request.URL.Host {
+		arguments = append(arguments, "-H", quoteForShell("Host: "+request.Host))
+	}
+	header := redactor.RedactHeader(request.Header)
+	for _, key := range sortedKeys(header) {
+		for _, value := range header[key] {
+			arguments = append(arguments, "-H", quoteForShell(key+": "+value))
+		}
+	}
+	if len(body) > 0 {
+		redactedBody := redactor.RedactBody(request.Header.Get("Content-Type"), body)
+		arguments = append(arguments, "--data-binary", quoteForShell(string(redactedBody)))
+	}
+	return strings.Join(arguments, " "), nil
+}
+
+// NewCurlLoggerRoundTripper creates a RoundTripper that decorates another
+// round tripper by using the specified leveled logger to log requests as curl
+// commands (see ToCurlCommand) at the info level while the specified toggle
+// is enabled (always if nil).
+func NewCurlLoggerRoundTripper(
+	roundTripper http.RoundTripper, logger log.LeveledLogger, redactor *Redactor, toggle *Toggle) http.RoundTripper {
+	if toggle == nil {
+		toggle = NewToggle(true)
+	}
+	return &curlLoggingRoundTripper{innerRoundTripper: roundTripper, logger: logger, redactor: redactor, toggle: toggle}
+}
+
+type curlLoggingRoundTripper struct {
+	innerRoundTripper http.RoundTripper
+	logger            log.LeveledLogger
+	redactor          *Redactor
+	toggle            *Toggle
+}
+
+func (roundTripper *curlLoggingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	if roundTripper.toggle.IsEnabled() {
+		if command, err := ToCurlCommand(request, roundTripper.redactor); err == nil {
+			roundTripper.logger.Info("cURL", "command", command)
+		} else {
+			roundTripper.logger.Error("Error rendering a cURL command", "err", err)
+		}
+	}
+	return roundTripper.innerRoundTripper.RoundTrip(request)
+}
+
+// quoteForShell wraps the specified string in single quotes, escaping the ones
+// it contains, so that a POSIX shell treats it literally.
+func quoteForShell(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -9,8 +9,15 @@ import (
 type HTTPError struct {
 	StatusCode int
 	Body       string
+
+	// Truncated denotes whether or not Body was truncated because the error
+	// response's body exceeded a size limit.
+	Truncated bool
 }
 
 func (err *HTTPError) Error() string {
+	if err.Truncated {
+		return fmt.Sprintf("HTTP Status Code %d: %s%s", err.StatusCode, err.Body, truncatedBodySuffix)
+	}
 	return fmt.Sprintf("HTTP Status Code %d: %s", err.StatusCode, err.Body)
 }
@@ -0,0 +1,362 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+	"github.com/voicera/gooseberry/validate"
+)
+
+// ErrInjectedConnectionDrop is returned by the fault-injecting round tripper
+// in lieu of a response when it simulates a dropped connection.
+var ErrInjectedConnectionDrop = errors.New("connection dropped by fault injection")
+
+// LatencyDistribution returns latencies to inject into calls.
+type LatencyDistribution func() time.Duration
+
+// NewFixedLatency creates a distribution that always returns
+// the specified latency.
+func NewFixedLatency(latency time.Duration) LatencyDistribution {
+	return func() time.Duration { return latency }
+}
+
+// NewUniformLatency creates a distribution that returns latencies
+// uniformly distributed in [min, max).
+func NewUniformLatency(min, max time.Duration) LatencyDistribution {
+	return func() time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rand.Int63n(int64(max-min)))
+	}
+}
+
+// NewNormalLatency creates a distribution that returns normally distributed
+// latencies with the specified mean and standard deviation; negative samples
+// are clamped to zero.
+func NewNormalLatency(mean, standardDeviation time.Duration) LatencyDistribution {
+	return func() time.Duration {
+		latency := mean + time.Duration(rand.NormFloat64()*float64(standardDeviation))
+		if latency < 0 {
+			return 0
+		}
+		return latency
+	}
+}
+
+// FaultRule describes which calls to inject faults into and which faults
+// to inject, each with its own probability. A rule matches all calls unless
+// it's narrowed down using its For* methods; faults are added using its With*
+// methods, whose probabilities must be in [0, 1]. All methods return
+// the modified rule to allow chaining.
+//
+// The faults of a matching rule are applied in the following order: latency
+// is injected first, then the connection may be dropped, or else a synthetic
+// response may be returned instead of calling the upstream; finally,
+// the upstream's response body may be truncated.
+type FaultRule struct {
+	name        string
+	host        string
+	pathPattern *regexp.Regexp
+	methods     map[string]bool
+
+	latencyProbability float64
+	latency            LatencyDistribution
+
+	dropProbability float64
+
+	syntheticResponseProbability float64
+	syntheticStatusCode          int
+	syntheticBody                string
+
+	truncationProbability float64
+	truncatedBodyLength   int64
+}
+
+// NewFaultRule creates a named rule that matches all calls and injects
+// no faults; the name is used to identify the rule in logs.
+func NewFaultRule(name string) *FaultRule {
+	return &FaultRule{name: name}
+}
+
+// ForHost narrows down the rule to calls to the specified host (including
+// the port, if any); hosts are compared case-insensitively.
+func (rule *FaultRule) ForHost(host string) *FaultRule {
+	rule.host = strings.ToLower(host)
+	return rule
+}
+
+// ForPath narrows down the rule to calls whose URL paths match
+// the specified pattern.
+func (rule *FaultRule) ForPath(pattern *regexp.Regexp) *FaultRule {
+	rule.pathPattern = pattern
+	return rule
+}
+
+// ForMethods narrows down the rule to calls that use any of
+// the specified HTTP methods.
+func (rule *FaultRule) ForMethods(methods ...string) *FaultRule {
+	rule.methods = make(map[string]bool, len(methods))
+	for _, method := range methods {
+		rule.methods[strings.ToUpper(method)] = true
+	}
+	return rule
+}
+
+// WithLatency injects latency sampled from the specified distribution
+// into calls, with the specified probability.
+func (rule *FaultRule) WithLatency(probability float64, latency LatencyDistribution) *FaultRule {
+	rule.latencyProbability, rule.latency = probability, latency
+	return rule
+}
+
+// WithConnectionDrop fails calls with ErrInjectedConnectionDrop, without
+// calling the upstream, with the specified probability.
+func (rule *FaultRule) WithConnectionDrop(probability float64) *FaultRule {
+	rule.dropProbability = probability
+	return rule
+}
+
+// WithSyntheticResponse responds to calls with the specified status code
+// and body, without calling the upstream, with the specified probability.
+func (rule *FaultRule) WithSyntheticResponse(probability float64, statusCode int, body string) *FaultRule {
+	rule.syntheticResponseProbability = probability
+	rule.syntheticStatusCode, rule.syntheticBody = statusCode, body
+	return rule
+}
+
+// WithTruncatedBody truncates response bodies after the specified number
+// of bytes, with the specified probability; reading a truncated body fails
+// with io.ErrUnexpectedEOF, as it would if the connection were cut midway.
+func (rule *FaultRule) WithTruncatedBody(probability float64, length int64) *FaultRule {
+	rule.truncationProbability, rule.truncatedBodyLength = probability, length
+	return rule
+}
+
+// GetName returns the name of the rule.
+func (rule *FaultRule) GetName() string {
+	return rule.name
+}
+
+func (rule *FaultRule) validate() error {
+	probabilities := []struct {
+		name  string
+		value float64
+	}{
+		{"latencyProbability", rule.latencyProbability},
+		{"dropProbability", rule.dropProbability},
+		{"syntheticResponseProbability", rule.syntheticResponseProbability},
+		{"truncationProbability", rule.truncationProbability},
+	}
+	for _, probability := range probabilities {
+		if err := validate.InRange(probability.value, 0, 1, probability.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRules(rules []*FaultRule) error {
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rule *FaultRule) matches(request *http.Request) bool {
+	if rule.host != "" && strings.ToLower(request.URL.Host) != rule.host {
+		return false
+	}
+	if rule.pathPattern != nil && !rule.pathPattern.MatchString(request.URL.Path) {
+		return false
+	}
+	return rule.methods == nil || rule.methods[request.Method]
+}
+
+// FaultInjector holds the rules used by fault-injecting round trippers;
+// rules can be changed at runtime, as it's safe for concurrent use.
+// For each call, the first rule (in the order of addition) that matches
+// the call is applied.
+type FaultInjector struct {
+	mutex  sync.RWMutex
+	rules  []*FaultRule
+	random func() float64 `test-hook:"verify-unexported"`
+}
+
+// NewFaultInjector creates an injector with the specified rules; it fails if
+// any rule is invalid.
+func NewFaultInjector(rules ...*FaultRule) (*FaultInjector, error) {
+	if err := validateRules(rules); err != nil {
+		return nil, err
+	}
+	return &FaultInjector{rules: rules, random: rand.Float64}, nil
+}
+
+// SetRules replaces the rules of the injector, unless any of the specified
+// rules is invalid.
+func (injector *FaultInjector) SetRules(rules ...*FaultRule) error {
+	if err := validateRules(rules); err != nil {
+		return err
+	}
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	injector.rules = rules
+	return nil
+}
+
+// AddRule adds the specified rule after the existing ones, unless it's invalid.
+func (injector *FaultInjector) AddRule(rule *FaultRule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	injector.rules = append(injector.rules[:len(injector.rules):len(injector.rules)], rule)
+	return nil
+}
+
+// RemoveRule removes the rules with the specified name; it returns true if
+// any rule was removed.
+func (injector *FaultInjector) RemoveRule(name string) bool {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	rules := make([]*FaultRule, 0, len(injector.rules))
+	for _, rule := range injector.rules {
+		if rule.name != name {
+			rules = append(rules, rule)
+		}
+	}
+	removed := len(rules) < len(injector.rules)
+	injector.rules = rules
+	return removed
+}
+
+// GetRules returns a copy of the rules of the injector.
+func (injector *FaultInjector) GetRules() []*FaultRule {
+	injector.mutex.RLock()
+	defer injector.mutex.RUnlock()
+	return append([]*FaultRule(nil), injector.rules...)
+}
+
+func (injector *FaultInjector) match(request *http.Request) *FaultRule {
+	injector.mutex.RLock()
+	defer injector.mutex.RUnlock()
+	for _, rule := range injector.rules {
+		if rule.matches(request) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (injector *FaultInjector) roll(probability float64) bool {
+	return probability > 0 && injector.random() < probability
+}
+
+// NewFaultInjectingRoundTripper creates a RoundTripper that decorates another
+// round tripper by injecting faults into calls according to the rules of
+// the specified injector (e.g., to test resilience to slow and failing
+// upstreams). Every injected fault is logged at the warn level using
+// the specified logger, with the URL redacted by the default redactor (see
+// NewDefaultRedactor).
+func NewFaultInjectingRoundTripper(
+	roundTripper http.RoundTripper, injector *FaultInjector, logger log.LeveledLogger) http.RoundTripper {
+	return &faultInjectingRoundTripper{
+		innerRoundTripper: roundTripper,
+		injector:          injector,
+		logger:            logger,
+		redactor:          NewDefaultRedactor(),
+	}
+}
+
+type faultInjectingRoundTripper struct {
+	innerRoundTripper http.RoundTripper
+	injector          *FaultInjector
+	logger            log.LeveledLogger
+	redactor          *Redactor // of logged URLs
+}
+
+func (roundTripper *faultInjectingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	injector := roundTripper.injector
+	rule := injector.match(request)
+	if rule == nil {
+		return roundTripper.innerRoundTripper.RoundTrip(request)
+	}
+
+	if rule.latency != nil && injector.roll(rule.latencyProbability) {
+		latency := rule.latency()
+		roundTripper.logFault(request, rule, "latency", "latency", latency)
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-request.Context().Done():
+			timer.Stop()
+			closeRequestBody(request)
+			return nil, request.Context().Err()
+		}
+	}
+
+	if injector.roll(rule.dropProbability) {
+		roundTripper.logFault(request, rule, "connectionDrop")
+		closeRequestBody(request)
+		return nil, ErrInjectedConnectionDrop
+	}
+
+	if injector.roll(rule.syntheticResponseProbability) {
+		roundTripper.logFault(request, rule, "syntheticResponse", "status", rule.syntheticStatusCode)
+		closeRequestBody(request)
+		return newSyntheticResponse(request, rule.syntheticStatusCode, rule.syntheticBody), nil
+	}
+
+	response, err := roundTripper.innerRoundTripper.RoundTrip(request)
+	if err == nil && response.Body != nil && injector.roll(rule.truncationProbability) {
+		roundTripper.logFault(request, rule, "truncatedBody", "truncatedLength", rule.truncatedBodyLength)
+		response.Body = LimitReadCloser(response.Body, rule.truncatedBodyLength, io.ErrUnexpectedEOF)
+	}
+	return response, err
+}
+
+func (roundTripper *faultInjectingRoundTripper) logFault(
+	request *http.Request, rule *FaultRule, fault string, details ...interface{}) {
+	args := append([]interface{}{
+		"rule", rule.name,
+		"fault", fault,
+		"method", request.Method,
+		"url", roundTripper.redactor.RedactURL(request.URL).String(),
+	}, details...)
+	roundTripper.logger.Warn("Injected fault", args...)
+}
+
+// closeRequestBody closes the body of a request that's not delegated to
+// the inner round tripper, as round trippers must always close request bodies.
+func closeRequestBody(request *http.Request) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+}
+
+func newSyntheticResponse(request *http.Request, statusCode int, body string) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}
+}
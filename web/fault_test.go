@@ -0,0 +1,180 @@
+package web
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/tester/assert"
+)
+
+func TestFaultRule_matches(t *testing.T) {
+	rule := NewFaultRule("rule").
+		ForHost("API.example.com").
+		ForPath(regexp.MustCompile(`^/calls/\d+$`)).
+		ForMethods("get", "DELETE")
+	cases := []struct {
+		method   string
+		url      string
+		expected bool
+	}{
+		{"GET", "https://api.example.com/calls/42", true},
+		{"DELETE", "https://Api.Example.com/calls/42", true},
+		{"POST", "https://api.example.com/calls/42", false},
+		{"GET", "https://example.com/calls/42", false},
+		{"GET", "https://api.example.com/calls/x", false},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, c.url, nil)
+		assert.For(t, c.method, c.url).ThatActual(rule.matches(request)).Equals(c.expected)
+	}
+	assert.For(t).ThatActual(NewFaultRule("all").matches(httptest.NewRequest("PUT", "/", nil))).IsTrue()
+}
+
+func TestFaultInjector_rules(t *testing.T) {
+	injector, err := NewFaultInjector(NewFaultRule("a"), NewFaultRule("b"))
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(injector.AddRule(NewFaultRule("c"))).IsNil()
+	assert.For(t).ThatActual(ruleNames(injector.GetRules())).Equals([]string{"a", "b", "c"})
+	assert.For(t).ThatActual(injector.RemoveRule("b")).IsTrue()
+	assert.For(t).ThatActual(injector.RemoveRule("b")).IsFalse()
+	assert.For(t).ThatActual(ruleNames(injector.GetRules())).Equals([]string{"a", "c"})
+	assert.For(t).ThatActual(injector.SetRules()).IsNil()
+	assert.For(t).ThatActual(len(injector.GetRules())).Equals(0)
+	assert.For(t).ThatType(reflect.TypeOf(FaultInjector{})).HidesTestHooks()
+}
+
+func TestFaultInjector_invalidRules(t *testing.T) {
+	cases := []struct {
+		id               string
+		rule             *FaultRule
+		expectedArgument string
+	}{
+		{"latency", NewFaultRule("rule").WithLatency(1.5, NewFixedLatency(time.Millisecond)), "latencyProbability"},
+		{"drop", NewFaultRule("rule").WithConnectionDrop(-0.5), "dropProbability"},
+		{"synthetic", NewFaultRule("rule").WithSyntheticResponse(2, 503, ""), "syntheticResponseProbability"},
+		{"truncated", NewFaultRule("rule").WithTruncatedBody(-1, 3), "truncationProbability"},
+	}
+
+	for _, c := range cases {
+		_, err := NewFaultInjector(NewFaultRule("valid"), c.rule)
+		if validationError, ok := err.(*validate.ValidationError); assert.For(t, c.id).ThatActual(ok).IsTrue().Passed() {
+			assert.For(t, c.id).ThatActualString(validationError.ArgumentName).Equals(c.expectedArgument)
+		}
+		injector, _ := NewFaultInjector()
+		assert.For(t, c.id).ThatActual(injector.AddRule(c.rule)).Equals(err)
+		assert.For(t, c.id).ThatActual(injector.SetRules(c.rule)).Equals(err)
+		assert.For(t, c.id).ThatActual(len(injector.GetRules())).Equals(0)
+	}
+}
+
+func TestFaultInjectingRoundTripper(t *testing.T) {
+	cases := []struct {
+		id             string
+		rule           *FaultRule
+		expectedStatus int
+		expectedBody   string
+		expectedErr    error
+		expectedFault  string
+		upstreamCalled bool
+	}{
+		{"no match", NewFaultRule("other").ForHost("other").WithConnectionDrop(1), 200, "upstream", nil, "", true},
+		{"not rolled", NewFaultRule("rule").WithConnectionDrop(0.5), 200, "upstream", nil, "", true},
+		{"latency", NewFaultRule("rule").WithLatency(1, NewFixedLatency(time.Millisecond)),
+			200, "upstream", nil, "latency", true},
+		{"drop", NewFaultRule("rule").WithConnectionDrop(1), 0, "", ErrInjectedConnectionDrop, "connectionDrop", false},
+		{"synthetic", NewFaultRule("rule").WithSyntheticResponse(1, 503, "synthetic"),
+			503, "synthetic", nil, "syntheticResponse", false},
+		{"truncated", NewFaultRule("rule").WithTruncatedBody(1, 3),
+			200, "ups", io.ErrUnexpectedEOF, "truncatedBody", true},
+	}
+
+	for _, c := range cases {
+		upstreamCalled := false
+		upstream := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			upstreamCalled = true
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader("upstream"))}, nil
+		})
+		injector, _ := NewFaultInjector(c.rule)
+		injector.random = func() float64 { return 0.75 }
+		logger := testutil.NewLogCapturer(false)
+		roundTripper := NewFaultInjectingRoundTripper(upstream, injector, logger)
+
+		requestBody := &closeRecorder{Reader: strings.NewReader("ping")}
+		response, err := roundTripper.RoundTrip(httptest.NewRequest("GET", "http://host/calls?token=42", requestBody))
+		assert.For(t, c.id).ThatActual(upstreamCalled).Equals(c.upstreamCalled)
+		if !c.upstreamCalled {
+			assert.For(t, c.id).ThatActual(requestBody.closed).IsTrue()
+		}
+		if response == nil {
+			assert.For(t, c.id).ThatActual(err).Equals(c.expectedErr)
+		} else {
+			body, readErr := ioutil.ReadAll(response.Body)
+			assert.For(t, c.id).ThatActual(response.StatusCode).Equals(c.expectedStatus)
+			assert.For(t, c.id).ThatActualString(string(body)).Equals(c.expectedBody)
+			assert.For(t, c.id).ThatActual(readErr).Equals(c.expectedErr)
+		}
+
+		if c.expectedFault == "" {
+			assert.For(t, c.id).ThatActual(len(logger.WarnCaptures)).Equals(0)
+		} else if assert.For(t, c.id).ThatActual(len(logger.WarnCaptures)).Equals(1).Passed() {
+			fields := toFields(logger.WarnCaptures[0])
+			assert.For(t, c.id).ThatActual(logger.WarnCaptures[0].Message).Equals("Injected fault")
+			assert.For(t, c.id).ThatActual(fields["rule"]).Equals("rule")
+			assert.For(t, c.id).ThatActual(fields["fault"]).Equals(c.expectedFault)
+			assert.For(t, c.id).ThatActual(fields["url"]).Equals(
+				"http://host/calls?token=" + strings.Replace(strippedOutHeaderValue, " ", "+", -1))
+		}
+	}
+}
+
+func TestFaultInjectingRoundTripper_latencyHonorsCancellation(t *testing.T) {
+	injector, _ := NewFaultInjector(NewFaultRule("slow").WithLatency(1, NewFixedLatency(time.Hour)))
+	roundTripper := NewFaultInjectingRoundTripper(http.DefaultTransport, injector, testutil.NewLogCapturer(false))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	requestBody := &closeRecorder{Reader: strings.NewReader("ping")}
+	request := httptest.NewRequest("GET", "http://host/", requestBody).WithContext(ctx)
+	_, err := roundTripper.RoundTrip(request)
+	assert.For(t).ThatActual(err).Equals(context.DeadlineExceeded)
+	assert.For(t).ThatActual(requestBody.closed).IsTrue()
+}
+
+// closeRecorder is a request body that records whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (body *closeRecorder) Close() error {
+	body.closed = true
+	return nil
+}
+
+func TestLatencyDistributions(t *testing.T) {
+	assert.For(t).ThatActual(NewFixedLatency(time.Second)()).Equals(time.Second)
+	for i := 0; i < 100; i++ {
+		uniform := NewUniformLatency(time.Millisecond, 2*time.Millisecond)()
+		assert.For(t, i).ThatActual(uniform >= time.Millisecond && uniform < 2*time.Millisecond).IsTrue()
+		assert.For(t, i).ThatActual(NewNormalLatency(0, time.Second)() >= 0).IsTrue()
+	}
+	assert.For(t).ThatActual(NewUniformLatency(time.Second, time.Second)()).Equals(time.Second)
+}
+
+func ruleNames(rules []*FaultRule) []string {
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.GetName()
+	}
+	return names
+}
@@ -0,0 +1,21 @@
+/*
+Package graphql provides a GraphQL client built on top of REST clients, which
+allows it to reuse their transport chains (e.g., authentication, logging, and
+retries).
+
+For example,
+
+	client := graphql.NewClient(
+	    rest.NewJSONClient(httpClient).WithBaseURL("https://api.example.com/"), "graphql")
+	result := &struct {
+	    Repository struct{ Stars int `json:"stargazerCount"` } `json:"repository"`
+	}{}
+	err := client.Query(graphql.NewRequest(`query Stars($name: String!) {
+	    repository(name: $name) { stargazerCount }
+	}`).WithOperationName("Stars").WithVariable("name", "gooseberry"), result)
+
+sends a query with variables and decodes the data of its response into result.
+If the response has errors, err is an *errors.AggregateError whose errors are
+*graphql.Error instances.
+*/
+package graphql
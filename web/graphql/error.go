@@ -0,0 +1,48 @@
+package graphql
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Error represents an error in the errors array of a GraphQL response.
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Location represents a location in a GraphQL document that an error
+// is associated with.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (err *Error) Error() string {
+	if len(err.Path) == 0 {
+		return err.Message
+	}
+	return err.Message + " (path: " + err.PathString() + ")"
+}
+
+// PathString formats the path of the error as a dot-separated string
+// (e.g., "repository.issues.0.title").
+func (err *Error) PathString() string {
+	buffer := &bytes.Buffer{}
+	for i, segment := range err.Path {
+		if i > 0 {
+			buffer.WriteRune('.')
+		}
+		fmt.Fprint(buffer, segment)
+	}
+	return buffer.String()
+}
+
+// Code returns the code in the extensions of the error (e.g.,
+// "UNAUTHENTICATED"), or an empty string if there's none.
+func (err *Error) Code() string {
+	code, _ := err.Extensions["code"].(string)
+	return code
+}
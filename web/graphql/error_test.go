@@ -0,0 +1,24 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestError(t *testing.T) {
+	cases := []struct {
+		err             *Error
+		expectedMessage string
+		expectedCode    string
+	}{
+		{&Error{Message: "boom"}, "boom", ""},
+		{&Error{Message: "boom", Path: []interface{}{"a", float64(1), "b"}}, "boom (path: a.1.b)", ""},
+		{&Error{Message: "boom", Extensions: map[string]interface{}{"code": "INTERNAL"}}, "boom", "INTERNAL"},
+	}
+
+	for _, c := range cases {
+		assert.For(t, c.expectedMessage).ThatActualString(c.err.Error()).Equals(c.expectedMessage)
+		assert.For(t, c.expectedMessage).ThatActualString(c.err.Code()).Equals(c.expectedCode)
+	}
+}
@@ -0,0 +1,174 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	gooseberryerrors "github.com/voicera/gooseberry/errors"
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+)
+
+const (
+	errorsHeader                = "GraphQL request failed"
+	persistedQueryNotFound      = "PersistedQueryNotFound"
+	persistedQueryNotFoundCode  = "PERSISTED_QUERY_NOT_FOUND"
+	persistedQueryProtocolLevel = 1
+)
+
+// Request represents a GraphQL request (a query or a mutation). It's
+// configured using its With* methods, which return the modified request
+// to allow chaining.
+type Request struct {
+	query         string
+	operationName string
+	variables     map[string]interface{}
+}
+
+// NewRequest creates a request for the specified GraphQL document.
+func NewRequest(query string) *Request {
+	return &Request{query: query}
+}
+
+// WithOperationName configures the name of the operation to execute, which is
+// required if the document contains multiple operations.
+func (request *Request) WithOperationName(operationName string) *Request {
+	request.operationName = operationName
+	return request
+}
+
+// WithVariable sets the value of the specified variable.
+func (request *Request) WithVariable(name string, value interface{}) *Request {
+	if request.variables == nil {
+		request.variables = map[string]interface{}{}
+	}
+	request.variables[name] = value
+	return request
+}
+
+// WithVariables sets the values of the specified variables.
+func (request *Request) WithVariables(variables map[string]interface{}) *Request {
+	for name, value := range variables {
+		request.WithVariable(name, value)
+	}
+	return request
+}
+
+// Client represents a GraphQL client.
+type Client struct {
+	restClient       rest.Client
+	endpoint         string
+	persistedQueries bool
+}
+
+// NewClient creates a client that posts requests to the specified endpoint
+// (resolved against the base URL of the REST client, if any) using
+// the specified REST client, which must use JSON to encode requests
+// and decode responses (see rest.NewJSONClient).
+func NewClient(restClient rest.Client, endpoint string) *Client {
+	return &Client{restClient: restClient, endpoint: endpoint}
+}
+
+// WithPersistedQueries configures the client to use automatic persisted
+// queries: it sends the SHA-256 hash of a query instead of the query itself,
+// and sends both only if the server has yet to persist the query.
+// Returns modified self.
+func (client *Client) WithPersistedQueries() *Client {
+	client.persistedQueries = true
+	return client
+}
+
+// Query sends the specified request and decodes the data of the response into
+// the specified result (unless it's nil). If the response has errors,
+// it returns an *errors.AggregateError whose errors are *Error instances;
+// partial data, if any, is still decoded into the result.
+func (client *Client) Query(request *Request, result interface{}) error {
+	if request == nil || request.query == "" {
+		return validate.NewValidationError(errors.New("query is required"), "request")
+	}
+	payload := &requestPayload{
+		OperationName: request.operationName,
+		Variables:     request.variables,
+		Query:         request.query,
+	}
+	if client.persistedQueries {
+		hash := sha256.Sum256([]byte(request.query))
+		payload.Extensions = &requestExtensions{PersistedQuery: &persistedQuery{
+			Version:    persistedQueryProtocolLevel,
+			SHA256Hash: hex.EncodeToString(hash[:]),
+		}}
+		payload.Query = ""
+		response, err := client.post(payload)
+		if err != nil || !response.isPersistedQueryNotFound() {
+			return response.decode(result, err)
+		}
+		payload.Query = request.query
+	}
+	response, err := client.post(payload)
+	return response.decode(result, err)
+}
+
+// post posts the specified payload; GraphQL servers may respond with
+// a non-2xx status code along with errors, which are extracted in that case.
+func (client *Client) post(payload *requestPayload) (*responsePayload, error) {
+	response := &responsePayload{}
+	if _, err := client.restClient.Post(client.endpoint, payload, response); err != nil {
+		httpError, ok := err.(*web.HTTPError)
+		if !ok || json.Unmarshal([]byte(httpError.Body), response) != nil || len(response.Errors) == 0 {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+type requestPayload struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    *requestExtensions     `json:"extensions,omitempty"`
+}
+
+type requestExtensions struct {
+	PersistedQuery *persistedQuery `json:"persistedQuery"`
+}
+
+type persistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+type responsePayload struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []*Error        `json:"errors"`
+}
+
+func (response *responsePayload) isPersistedQueryNotFound() bool {
+	for _, err := range response.Errors {
+		if err.Message == persistedQueryNotFound || err.Code() == persistedQueryNotFoundCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (response *responsePayload) decode(result interface{}, err error) error {
+	if err != nil {
+		return err
+	}
+	if result != nil && len(response.Data) > 0 && string(response.Data) != "null" {
+		if err := json.Unmarshal(response.Data, result); err != nil {
+			return err
+		}
+	}
+	if len(response.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, len(response.Errors))
+	for i, err := range response.Errors {
+		errs[i] = err
+	}
+	return gooseberryerrors.NewAggregateError(errorsHeader, errs...)
+}
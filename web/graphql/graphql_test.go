@@ -0,0 +1,136 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gooseberryerrors "github.com/voicera/gooseberry/errors"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+	"github.com/voicera/tester/assert"
+)
+
+const (
+	starsQuery = "query Stars($name: String!) { repository(name: $name) { stargazerCount } }"
+	starsHash  = "df1944b4b66364f97636f39db4c563277934faf11ba48aaad83a112c31705634"
+)
+
+type starsResult struct {
+	Repository *struct {
+		Stars int `json:"stargazerCount"`
+	} `json:"repository"`
+}
+
+func TestClient_Query(t *testing.T) {
+	var received map[string]interface{}
+	server := newServer(t, func(writer http.ResponseWriter, payload map[string]interface{}) {
+		received = payload
+		writer.Write([]byte(`{"data":{"repository":{"stargazerCount":42}}}`))
+	})
+	defer server.Close()
+
+	result := &starsResult{}
+	err := newClient(server).Query(NewRequest(starsQuery).
+		WithOperationName("Stars").
+		WithVariables(map[string]interface{}{"name": "gooseberry"}), result)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(result.Repository.Stars).Equals(42)
+	assert.For(t).ThatActual(received).Equals(map[string]interface{}{
+		"query":         starsQuery,
+		"operationName": "Stars",
+		"variables":     map[string]interface{}{"name": "gooseberry"},
+	}).ThenDiffOnFail()
+}
+
+func TestClient_Query_errors(t *testing.T) {
+	cases := []struct {
+		id         string
+		statusCode int
+		body       string
+		hasData    bool
+	}{
+		{"partial data", 200, `{"data":{"repository":{"stargazerCount":42}},"errors":[
+			{"message":"not found","path":["repository","issues",0],"locations":[{"line":1,"column":2}]},
+			{"message":"denied","extensions":{"code":"FORBIDDEN"}}]}`, true},
+		{"no data", 200, `{"data":null,"errors":[{"message":"not found","path":["repository","issues",0]},
+			{"message":"denied","extensions":{"code":"FORBIDDEN"}}]}`, false},
+		{"bad request", 400, `{"errors":[{"message":"not found","path":["repository","issues",0]},
+			{"message":"denied","extensions":{"code":"FORBIDDEN"}}]}`, false},
+	}
+
+	for _, c := range cases {
+		server := newServer(t, func(writer http.ResponseWriter, _ map[string]interface{}) {
+			writer.WriteHeader(c.statusCode)
+			writer.Write([]byte(c.body))
+		})
+		result := &starsResult{}
+		err := newClient(server).Query(NewRequest(starsQuery), result)
+		server.Close()
+
+		aggregateError, ok := err.(*gooseberryerrors.AggregateError)
+		if !assert.For(t, c.id).ThatActual(ok).IsTrue().Passed() {
+			continue
+		}
+		assert.For(t, c.id).ThatActual(result.Repository != nil).Equals(c.hasData)
+		assert.For(t, c.id).ThatActual(len(aggregateError.Errors)).Equals(2)
+		assert.For(t, c.id).ThatActualString(aggregateError.Error()).Equals(
+			"GraphQL request failed\nnot found (path: repository.issues.0)\ndenied\n")
+		assert.For(t, c.id).ThatActual(aggregateError.Errors[1].(*Error).Code()).Equals("FORBIDDEN")
+	}
+}
+
+func TestClient_Query_httpError(t *testing.T) {
+	server := newServer(t, func(writer http.ResponseWriter, _ map[string]interface{}) {
+		writer.WriteHeader(503)
+		writer.Write([]byte("unavailable"))
+	})
+	defer server.Close()
+	err := newClient(server).Query(NewRequest(starsQuery), nil)
+	assert.For(t).ThatActual(err).Equals(&web.HTTPError{StatusCode: 503, Body: "unavailable"})
+	assert.For(t).ThatActual(newClient(server).Query(NewRequest(""), nil)).IsNotNil()
+}
+
+func TestClient_Query_persistedQueries(t *testing.T) {
+	for _, persisted := range []bool{false, true} {
+		var payloads []map[string]interface{}
+		server := newServer(t, func(writer http.ResponseWriter, payload map[string]interface{}) {
+			payloads = append(payloads, payload)
+			if payload["query"] == nil && !persisted {
+				writer.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound"}]}`))
+				return
+			}
+			writer.Write([]byte(`{"data":{"repository":{"stargazerCount":42}}}`))
+		})
+
+		result := &starsResult{}
+		err := newClient(server).WithPersistedQueries().Query(NewRequest(starsQuery), result)
+		server.Close()
+		assert.For(t, persisted).ThatActual(err).IsNil()
+		assert.For(t, persisted).ThatActual(result.Repository.Stars).Equals(42)
+
+		extensions := map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": float64(1), "sha256Hash": starsHash},
+		}
+		expected := []map[string]interface{}{{"extensions": extensions}}
+		if !persisted {
+			expected = append(expected, map[string]interface{}{"extensions": extensions, "query": starsQuery})
+		}
+		assert.For(t, persisted).ThatActual(payloads).Equals(expected).ThenDiffOnFail()
+	}
+}
+
+func newServer(t *testing.T, handle func(http.ResponseWriter, map[string]interface{})) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.For(t).ThatActual(request.Method).Equals("POST")
+		assert.For(t).ThatActual(request.URL.Path).Equals("/graphql")
+		payload := map[string]interface{}{}
+		assert.For(t).ThatActual(json.NewDecoder(request.Body).Decode(&payload)).IsNil()
+		handle(writer, payload)
+	}))
+}
+
+func newClient(server *httptest.Server) *Client {
+	return NewClient(rest.NewJSONClient(server.Client()).WithBaseURL(server.URL), "graphql")
+}
@@ -0,0 +1,408 @@
+package web
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	harVersion         = "1.2"
+	harCreatorName     = "gooseberry"
+	harCreatorVersion  = "1.0"
+	harUnknownSize     = -1
+	harUnknownDuration = -1.0
+
+	defaultHARMaxEntries = 1000
+)
+
+// HARRecorder records HTTP calls made via round trippers created using
+// NewHARRecordingRoundTripper, and writes them as HTTP Archive (HAR 1.2)
+// documents that can be loaded into browser devtools and other HAR viewers.
+// It keeps the latest calls only, up to a maximum number of entries, so that
+// a long-lived recorder doesn't exhaust memory. It's safe for concurrent use.
+type HARRecorder struct {
+	mutex      sync.Mutex
+	entries    []*harEntry // a ring buffer once full
+	oldest     int
+	maxEntries int
+	redactor   *Redactor
+	toggle     *Toggle
+}
+
+// NewHARRecorder creates a recorder that records the latest 1000 calls while
+// the specified toggle is enabled (always if nil). Sensitive data is censored
+// using the specified redactor (the default redactor if nil; see
+// NewDefaultRedactor).
+func NewHARRecorder(redactor *Redactor, toggle *Toggle) *HARRecorder {
+	if redactor == nil {
+		redactor = NewDefaultRedactor()
+	}
+	if toggle == nil {
+		toggle = NewToggle(true)
+	}
+	return &HARRecorder{redactor: redactor, toggle: toggle, maxEntries: defaultHARMaxEntries}
+}
+
+// WithMaxEntries configures the maximum number of calls to keep; once reached,
+// recording a call discards the oldest one. Recorded calls are discarded.
+// Returns modified self.
+func (recorder *HARRecorder) WithMaxEntries(maxEntries int) *HARRecorder {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	recorder.maxEntries, recorder.entries, recorder.oldest = maxEntries, nil, 0
+	return recorder
+}
+
+// Len returns the number of recorded calls.
+func (recorder *HARRecorder) Len() int {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return len(recorder.entries)
+}
+
+// Reset discards all recorded calls.
+func (recorder *HARRecorder) Reset() {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.entries, recorder.oldest = nil, 0
+}
+
+// WriteTo writes the recorded calls to the specified writer as a HAR document.
+func (recorder *HARRecorder) WriteTo(writer io.Writer) (int64, error) {
+	recorder.mutex.Lock()
+	document := &harDocument{Log: &harLog{
+		Version: harVersion,
+		Creator: &harCreator{Name: harCreatorName, Version: harCreatorVersion},
+		Entries: append(append([]*harEntry{}, recorder.entries[recorder.oldest:]...),
+			recorder.entries[:recorder.oldest]...),
+	}}
+	recorder.mutex.Unlock()
+
+	marshalled, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	written, err := writer.Write(marshalled)
+	return int64(written), err
+}
+
+// SaveToFile writes the recorded calls to a HAR file at the specified path.
+func (recorder *HARRecorder) SaveToFile(filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err = recorder.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (recorder *HARRecorder) record(entry *harEntry) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	if len(recorder.entries) < recorder.maxEntries {
+		recorder.entries = append(recorder.entries, entry)
+		return
+	}
+	recorder.entries[recorder.oldest] = entry
+	recorder.oldest = (recorder.oldest + 1) % len(recorder.entries)
+}
+
+// NewHARRecordingRoundTripper creates a RoundTripper that decorates another
+// round tripper by recording calls, including their timings, headers,
+// and bodies, using the specified recorder. While recording, response bodies
+// are read fully before the response is returned.
+func NewHARRecordingRoundTripper(roundTripper http.RoundTripper, recorder *HARRecorder) http.RoundTripper {
+	return &harRecordingRoundTripper{innerRoundTripper: roundTripper, recorder: recorder}
+}
+
+type harRecordingRoundTripper struct {
+	innerRoundTripper http.RoundTripper
+	recorder          *HARRecorder
+}
+
+func (roundTripper *harRecordingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !roundTripper.recorder.toggle.IsEnabled() {
+		return roundTripper.innerRoundTripper.RoundTrip(request)
+	}
+
+	requestBody, err := drainBody(&request.Body)
+	if err != nil {
+		return nil, err
+	}
+	timer := &harTimer{}
+	tracedRequest := request.WithContext(httptrace.WithClientTrace(request.Context(), timer.newClientTrace()))
+
+	timer.start = time.Now()
+	response, responseError := roundTripper.innerRoundTripper.RoundTrip(tracedRequest)
+	timer.responded = time.Now()
+	var responseBody []byte
+	if response != nil && responseError == nil {
+		if responseBody, err = drainBody(&response.Body); err != nil {
+			// http.Client ignores responses returned with errors; so, it's closed here
+			response.Body.Close()
+			response, responseError = nil, err
+		}
+	}
+	timer.received = time.Now()
+
+	redactor := roundTripper.recorder.redactor
+	entry := &harEntry{
+		StartedDateTime: timer.start.Format(time.RFC3339Nano),
+		Request:         newHARRequest(request, requestBody, redactor),
+		Response:        newHARResponse(response, responseBody, redactor),
+		Cache:           struct{}{},
+		Timings:         timer.timings(),
+	}
+	entry.Time = entry.Timings.total()
+	if responseError != nil {
+		entry.Error = responseError.Error()
+	}
+	roundTripper.recorder.record(entry)
+	return response, responseError
+}
+
+type harDocument struct {
+	Log *harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator *harCreator `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string       `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         *harRequest  `json:"request"`
+	Response        *harResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         *harTimings  `json:"timings"`
+	Error           string       `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	QueryString []*harNameValue `json:"queryString"`
+	PostData    *harPostData    `json:"postData,omitempty"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	Content     *harContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type harTimings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func (timings *harTimings) total() float64 {
+	total := 0.0
+	for _, timing := range []float64{timings.DNS, timings.Connect, timings.Send, timings.Wait, timings.Receive} {
+		if timing > 0 { // SSL is included in Connect as per the spec
+			total += timing
+		}
+	}
+	return total
+}
+
+func newHARRequest(request *http.Request, body []byte, redactor *Redactor) *harRequest {
+	header, url := redactor.RedactHeader(request.Header), redactor.RedactURL(request.URL)
+	harRequest := &harRequest{
+		Method:      request.Method,
+		URL:         url.String(),
+		HTTPVersion: request.Proto,
+		Cookies:     []*harNameValue{},
+		Headers:     toHARNameValues(header),
+		QueryString: toHARNameValues(url.Query()),
+		HeadersSize: harUnknownSize,
+		BodySize:    len(body),
+	}
+	if harRequest.HTTPVersion == "" {
+		harRequest.HTTPVersion = "HTTP/1.1"
+	}
+	for _, cookie := range (&http.Request{Header: header}).Cookies() {
+		harRequest.Cookies = append(harRequest.Cookies, &harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	if len(body) > 0 {
+		harRequest.PostData = &harPostData{
+			MimeType: request.Header.Get("Content-Type"),
+			Text:     string(redactor.RedactBody(request.Header.Get("Content-Type"), body)),
+		}
+	}
+	return harRequest
+}
+
+func newHARResponse(response *http.Response, body []byte, redactor *Redactor) *harResponse {
+	if response == nil {
+		return &harResponse{
+			Cookies:     []*harNameValue{},
+			Headers:     []*harNameValue{},
+			Content:     &harContent{},
+			HeadersSize: harUnknownSize,
+			BodySize:    harUnknownSize,
+		}
+	}
+	header := redactor.RedactHeader(response.Header)
+	harResponse := &harResponse{
+		Status:      response.StatusCode,
+		StatusText:  http.StatusText(response.StatusCode),
+		HTTPVersion: response.Proto,
+		Cookies:     []*harNameValue{},
+		Headers:     toHARNameValues(header),
+		Content: &harContent{
+			Size:     len(body),
+			MimeType: response.Header.Get("Content-Type"),
+			Text:     string(redactor.RedactBody(response.Header.Get("Content-Type"), body)),
+		},
+		RedirectURL: response.Header.Get("Location"),
+		HeadersSize: harUnknownSize,
+		BodySize:    len(body),
+	}
+	for _, cookie := range (&http.Response{Header: header}).Cookies() {
+		harResponse.Cookies = append(harResponse.Cookies, &harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	return harResponse
+}
+
+func toHARNameValues(values map[string][]string) []*harNameValue {
+	nameValues := []*harNameValue{}
+	for _, name := range sortedKeys(values) {
+		for _, value := range values[name] {
+			nameValues = append(nameValues, &harNameValue{Name: name, Value: value})
+		}
+	}
+	return nameValues
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// harTimer keeps track of the phases of a call using an httptrace.ClientTrace.
+type harTimer struct {
+	mutex                                      sync.Mutex
+	start, responded, received                 time.Time
+	dnsStart, dnsDone, connectStart, connected time.Time
+	tlsStart, tlsDone, wroteRequest, firstByte time.Time
+}
+
+func (timer *harTimer) newClientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { timer.mark(&timer.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { timer.mark(&timer.dnsDone) },
+		ConnectStart:         func(string, string) { timer.mark(&timer.connectStart) },
+		ConnectDone:          func(string, string, error) { timer.mark(&timer.connected) },
+		TLSHandshakeStart:    func() { timer.mark(&timer.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { timer.mark(&timer.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timer.mark(&timer.wroteRequest) },
+		GotFirstResponseByte: func() { timer.mark(&timer.firstByte) },
+	}
+}
+
+func (timer *harTimer) mark(timestamp *time.Time) {
+	timer.mutex.Lock()
+	defer timer.mutex.Unlock()
+	if timestamp.IsZero() { // only the first attempt counts (e.g., for dual-stack dialing)
+		*timestamp = time.Now()
+	}
+}
+
+func (timer *harTimer) timings() *harTimings {
+	timer.mutex.Lock()
+	defer timer.mutex.Unlock()
+	timings := &harTimings{
+		DNS:     millisecondsBetween(timer.dnsStart, timer.dnsDone),
+		Connect: millisecondsBetween(timer.connectStart, timer.tlsDone),
+		SSL:     millisecondsBetween(timer.tlsStart, timer.tlsDone),
+		Send:    0,
+		Wait:    millisecondsBetween(timer.start, timer.responded),
+		Receive: millisecondsBetween(timer.responded, timer.received),
+	}
+	if timings.SSL == harUnknownDuration {
+		timings.Connect = millisecondsBetween(timer.connectStart, timer.connected)
+	}
+	if !timer.wroteRequest.IsZero() {
+		sendStart := timer.start
+		for _, t := range []time.Time{timer.dnsDone, timer.connected, timer.tlsDone} {
+			if t.After(sendStart) {
+				sendStart = t
+			}
+		}
+		timings.Send = millisecondsBetween(sendStart, timer.wroteRequest)
+		timings.Wait = millisecondsBetween(timer.wroteRequest, timer.firstByte)
+		if timings.Wait == harUnknownDuration {
+			timings.Wait = millisecondsBetween(timer.wroteRequest, timer.responded)
+		}
+	}
+	for _, timing := range []*float64{&timings.Send, &timings.Wait, &timings.Receive} {
+		if *timing < 0 { // required timings cannot be unknown as per the spec
+			*timing = 0
+		}
+	}
+	return timings
+}
+
+func millisecondsBetween(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return harUnknownDuration
+	}
+	return float64(end.Sub(start)) / float64(time.Millisecond)
+}
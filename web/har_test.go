@@ -0,0 +1,152 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestHARRecordingRoundTripper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("Set-Cookie", "session=42")
+		writer.WriteHeader(http.StatusCreated)
+		writer.Write([]byte(`{"token":"42","sid":"CA42"}`))
+	}))
+	defer server.Close()
+
+	toggle := NewToggle(true)
+	recorder := NewHARRecorder(nil, toggle)
+	client := &http.Client{Transport: NewHARRecordingRoundTripper(http.DefaultTransport, recorder)}
+
+	response, err := client.Post(server.URL+"/calls?to=42&api_key=s3cr3t", "text/plain", strings.NewReader("ping!"))
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		assert.For(t).ThatActualString(string(body)).Equals(`{"token":"42","sid":"CA42"}`)
+	}
+
+	toggle.Disable()
+	_, err = client.Get(server.URL)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(recorder.Len()).Equals(1)
+
+	document := decodeHAR(t, recorder)
+	assert.For(t).ThatActualString(document.Log.Version).Equals("1.2")
+	if assert.For(t).ThatActual(len(document.Log.Entries)).Equals(1).Passed() {
+		entry := document.Log.Entries[0]
+		assert.For(t).ThatActualString(entry.Request.Method).Equals("POST")
+		assert.For(t).ThatActualString(entry.Request.URL).Equals(
+			server.URL + "/calls?to=42&api_key=" + strings.Replace(strippedOutHeaderValue, " ", "+", -1))
+		assert.For(t).ThatActual(entry.Request.QueryString).Equals([]*harNameValue{
+			{Name: "api_key", Value: strippedOutHeaderValue}, {Name: "to", Value: "42"}}).ThenDiffOnFail()
+		assert.For(t).ThatActual(entry.Request.PostData).Equals(&harPostData{MimeType: "text/plain", Text: "ping!"})
+		assert.For(t).ThatActual(entry.Response.Status).Equals(201)
+		assert.For(t).ThatActualString(entry.Response.StatusText).Equals("Created")
+		assert.For(t).ThatActualString(entry.Response.Content.Text).Equals(`{"` + tokenReplacement + `,"sid":"CA42"}`)
+		assert.For(t).ThatActual(entry.Timings.Wait >= 0 && entry.Timings.Send >= 0).IsTrue()
+		assert.For(t).ThatActual(entry.Time >= entry.Timings.Wait).IsTrue()
+		for _, header := range entry.Response.Headers {
+			if header.Name == "Set-Cookie" {
+				assert.For(t).ThatActualString(header.Value).Equals(strippedOutHeaderValue)
+			}
+		}
+	}
+
+	recorder.Reset()
+	assert.For(t).ThatActual(recorder.Len()).Equals(0)
+}
+
+func TestHARRecordingRoundTripper_onError(t *testing.T) {
+	expectedError := errors.New("to err is human")
+	recorder := NewHARRecorder(nil, NewToggle(true))
+	roundTripper := NewHARRecordingRoundTripper(&mockRoundTripper{err: expectedError}, recorder)
+	request, _ := http.NewRequest("GET", "http://host", nil)
+	_, err := roundTripper.RoundTrip(request)
+	assert.For(t).ThatActual(err).Equals(expectedError)
+
+	document := decodeHAR(t, recorder)
+	if assert.For(t).ThatActual(len(document.Log.Entries)).Equals(1).Passed() {
+		assert.For(t).ThatActualString(document.Log.Entries[0].Error).Equals("to err is human")
+		assert.For(t).ThatActual(document.Log.Entries[0].Response.Status).Equals(0)
+	}
+}
+
+func TestHARRecordingRoundTripper_onBodyError(t *testing.T) {
+	expectedError := errors.New("connection reset")
+	body := &closeRecorder{Reader: io.MultiReader(strings.NewReader("partial"), &failingReader{expectedError})}
+	recorder := NewHARRecorder(nil, nil)
+	roundTripper := NewHARRecordingRoundTripper(
+		&mockRoundTripper{response: &http.Response{StatusCode: 200, Header: http.Header{}, Body: body}}, recorder)
+	request, _ := http.NewRequest("GET", "http://host", nil)
+	response, err := roundTripper.RoundTrip(request)
+	assert.For(t).ThatActual(response == nil).IsTrue()
+	assert.For(t).ThatActual(err).Equals(expectedError)
+	assert.For(t).ThatActual(body.closed).IsTrue()
+
+	document := decodeHAR(t, recorder)
+	if assert.For(t).ThatActual(len(document.Log.Entries)).Equals(1).Passed() {
+		assert.For(t).ThatActualString(document.Log.Entries[0].Error).Equals("connection reset")
+	}
+}
+
+// failingReader fails every read with its error.
+type failingReader struct {
+	err error
+}
+
+func (reader *failingReader) Read([]byte) (int, error) {
+	return 0, reader.err
+}
+
+func TestHARRecorder_WithMaxEntries(t *testing.T) {
+	recorder := NewHARRecorder(nil, NewToggle(true)).WithMaxEntries(2)
+	roundTripper := NewHARRecordingRoundTripper(&mockRoundTripper{err: errors.New("dropped")}, recorder)
+	for _, path := range []string{"a", "b", "c"} {
+		request, _ := http.NewRequest("GET", "http://host/"+path, nil)
+		roundTripper.RoundTrip(request)
+	}
+	assert.For(t).ThatActual(recorder.Len()).Equals(2)
+
+	document := decodeHAR(t, recorder)
+	var urls []string
+	for _, entry := range document.Log.Entries {
+		urls = append(urls, entry.Request.URL)
+	}
+	assert.For(t).ThatActual(urls).Equals([]string{"http://host/b", "http://host/c"}).ThenDiffOnFail()
+}
+
+func TestHARRecorder_SaveToFile(t *testing.T) {
+	folder, err := ioutil.TempDir("", "har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	filePath := filepath.Join(folder, "session.har")
+	err = NewHARRecorder(nil, NewToggle(true)).SaveToFile(filePath)
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		content, err := ioutil.ReadFile(filePath)
+		assert.For(t).ThatActual(err).IsNil()
+		assert.For(t).ThatActual(bytes.Contains(content, []byte(`"entries": []`))).IsTrue()
+	}
+}
+
+func decodeHAR(t *testing.T, recorder *HARRecorder) *harDocument {
+	buffer := &bytes.Buffer{}
+	_, err := recorder.WriteTo(buffer)
+	assert.For(t).ThatActual(err).IsNil()
+	document := &harDocument{}
+	assert.For(t).ThatActual(json.Unmarshal(buffer.Bytes(), document)).IsNil()
+	return document
+}
@@ -0,0 +1,24 @@
+/*
+Package jsonrpc provides a JSON-RPC 2.0 client built on top of REST clients,
+which allows it to reuse their transport chains (e.g., authentication,
+logging, and retries).
+
+For example,
+
+	client := jsonrpc.NewClient(
+	    rest.NewJSONClient(httpClient).WithBaseURL("https://rpc.example.com/"), "rpc")
+	sum := 0
+	err := client.Call("add", []int{40, 2}, &sum)
+
+calls the add method with positional parameters and decodes its result into
+sum; if the call fails, err is a *jsonrpc.Error with the code, message, and
+data of the error object. Calls can also be batched:
+
+	batch := client.NewBatch()
+	first := batch.Call("add", []int{1, 2}, &sum)
+	second := batch.Call("subtract", map[string]int{"minuend": 3, "subtrahend": 1}, &difference)
+	batch.Notify("log", []string{"batched"})
+	err = batch.Send() // fails only if the batch as a whole fails
+	if first.Err != nil { ... }
+*/
+package jsonrpc
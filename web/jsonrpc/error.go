@@ -0,0 +1,39 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Codes of errors that are defined by the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+var (
+	errNoData = errors.New("error object has no data")
+)
+
+// Error represents a JSON-RPC error object.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", err.Code, err.Message)
+}
+
+// DecodeData decodes the data of the error object into the specified value.
+func (err *Error) DecodeData(value interface{}) error {
+	if len(err.Data) == 0 {
+		return errNoData
+	}
+	return json.Unmarshal(err.Data, value)
+}
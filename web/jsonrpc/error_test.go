@@ -0,0 +1,17 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestError_DecodeData(t *testing.T) {
+	err := &Error{Code: CodeInternalError, Message: "oops", Data: json.RawMessage(`[1,2]`)}
+	data := []int{}
+	assert.For(t).ThatActual(err.DecodeData(&data)).IsNil()
+	assert.For(t).ThatActual(data).Equals([]int{1, 2})
+	assert.For(t).ThatActualString(err.Error()).Equals("JSON-RPC error -32603: oops")
+	assert.For(t).ThatActual((&Error{}).DecodeData(&data)).Equals(errNoData)
+}
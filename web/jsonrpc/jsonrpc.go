@@ -0,0 +1,206 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+	"go.uber.org/atomic"
+)
+
+const (
+	protocolVersion = "2.0"
+)
+
+var (
+	errMissingResponse = errors.New("no response for call in batch")
+	errEmptyBatch      = validate.NewValidationError(errors.New("batch has no calls"), "batch")
+)
+
+// Client represents a JSON-RPC 2.0 client; it's safe for concurrent use.
+type Client struct {
+	restClient rest.Client
+	endpoint   string
+	lastID     *atomic.Uint64
+}
+
+// NewClient creates a client that posts requests to the specified endpoint
+// (resolved against the base URL of the REST client, if any) using
+// the specified REST client, which must use JSON to encode requests
+// and decode responses (see rest.NewJSONClient).
+func NewClient(restClient rest.Client, endpoint string) *Client {
+	return &Client{restClient: restClient, endpoint: endpoint, lastID: atomic.NewUint64(0)}
+}
+
+// Call calls the specified method with the specified params (a struct, map,
+// or slice; nil for none) and decodes the result into the specified result
+// (unless it's nil). If the server responds with an error object, even with
+// an HTTP error status code, the returned error is an *Error.
+func (client *Client) Call(method string, params interface{}, result interface{}) error {
+	request := client.newRequest(method, params, true)
+	raw := json.RawMessage{}
+	if _, err := client.restClient.Post(client.endpoint, request, &raw); err != nil {
+		return toError(err)
+	}
+	response := &response{}
+	if err := json.Unmarshal(raw, response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if response.ID == nil || *response.ID != *request.ID {
+		return fmt.Errorf("response ID does not match request ID %d", *request.ID)
+	}
+	return response.decodeResult(result)
+}
+
+// Notify sends a notification, a call to the specified method with
+// the specified params for which the server sends no response.
+func (client *Client) Notify(method string, params interface{}) error {
+	_, err := client.restClient.Post(client.endpoint, client.newRequest(method, params, false), nil)
+	return toError(err)
+}
+
+// NewBatch creates an empty batch of calls and notifications.
+func (client *Client) NewBatch() *Batch {
+	return &Batch{client: client}
+}
+
+func (client *Client) newRequest(method string, params interface{}, expectsResponse bool) *request {
+	request := &request{Version: protocolVersion, Method: method, Params: params}
+	if expectsResponse {
+		id := client.lastID.Inc()
+		request.ID = &id
+	}
+	return request
+}
+
+// Batch represents a batch of calls and notifications that are sent together
+// in one request; it's not safe for concurrent use.
+type Batch struct {
+	client   *Client
+	requests []*request
+	calls    map[uint64]*Call
+}
+
+// Call represents a call in a batch; its error, if any, is set when
+// the batch is sent.
+type Call struct {
+	// Method is the name of the called method.
+	Method string
+
+	// Err is the error of the call (an *Error if the server responded with
+	// an error object), or nil if the call succeeded.
+	Err error
+
+	result interface{}
+}
+
+// Call adds a call to the specified method with the specified params to
+// the batch; when the batch is sent, the result of the call is decoded into
+// the specified result (unless it's nil).
+func (batch *Batch) Call(method string, params interface{}, result interface{}) *Call {
+	request := batch.client.newRequest(method, params, true)
+	call := &Call{Method: method, result: result}
+	if batch.calls == nil {
+		batch.calls = map[uint64]*Call{}
+	}
+	batch.calls[*request.ID] = call
+	batch.requests = append(batch.requests, request)
+	return call
+}
+
+// Notify adds a notification to the batch.
+func (batch *Batch) Notify(method string, params interface{}) {
+	batch.requests = append(batch.requests, batch.client.newRequest(method, params, false))
+}
+
+// Send sends the batch and sets the errors of its calls; it returns an error
+// only if the batch as a whole failed (e.g., if the server was unreachable,
+// or if it rejected the batch with a single error object).
+func (batch *Batch) Send() error {
+	if len(batch.requests) == 0 {
+		return errEmptyBatch
+	}
+	if len(batch.calls) == 0 { // the server sends no response for notifications
+		_, err := batch.client.restClient.Post(batch.client.endpoint, batch.requests, nil)
+		return toError(err)
+	}
+
+	raw := json.RawMessage{}
+	if _, err := batch.client.restClient.Post(batch.client.endpoint, batch.requests, &raw); err != nil {
+		return toError(err)
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '[' {
+		response := &response{}
+		if err := json.Unmarshal(raw, response); err != nil {
+			return err
+		}
+		if response.Error != nil {
+			return response.Error
+		}
+		return errors.New("batch response is not an array")
+	}
+
+	responses := []*response{}
+	if err := json.Unmarshal(raw, &responses); err != nil {
+		return err
+	}
+	for _, call := range batch.calls {
+		call.Err = errMissingResponse
+	}
+	for _, response := range responses {
+		if response.ID == nil {
+			continue
+		}
+		call, found := batch.calls[*response.ID]
+		if !found {
+			continue
+		}
+		if response.Error != nil {
+			call.Err = response.Error
+		} else {
+			call.Err = response.decodeResult(call.result)
+		}
+	}
+	return nil
+}
+
+// toError returns the error object in the body of the specified error if it's
+// an *web.HTTPError, as many servers send error objects with 4xx and 5xx status
+// codes; otherwise, it returns the specified error.
+func toError(err error) error {
+	if httpError, ok := err.(*web.HTTPError); ok {
+		response := &response{}
+		if json.Unmarshal([]byte(httpError.Body), response) == nil && response.Error != nil {
+			return response.Error
+		}
+	}
+	return err
+}
+
+type request struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *uint64     `json:"id,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+	ID      *uint64         `json:"id"`
+}
+
+func (response *response) decodeResult(result interface{}) error {
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
@@ -0,0 +1,215 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/gooseberry/web/rest"
+	"github.com/voicera/tester/assert"
+)
+
+func TestClient_Call(t *testing.T) {
+	server, _ := newServer(t)
+	defer server.Close()
+	client := newClient(server)
+
+	sum := 0
+	assert.For(t).ThatActual(client.Call("add", []int{40, 2}, &sum)).IsNil()
+	assert.For(t).ThatActual(sum).Equals(42)
+
+	difference := 0
+	params := map[string]int{"minuend": 42, "subtrahend": 2}
+	assert.For(t).ThatActual(client.Call("subtract", params, &difference)).IsNil()
+	assert.For(t).ThatActual(difference).Equals(40)
+
+	err := client.Call("divide", []int{1, 0}, nil)
+	if rpcError, ok := err.(*Error); assert.For(t).ThatActual(ok).IsTrue().Passed() {
+		assert.For(t).ThatActual(rpcError.Code).Equals(CodeInvalidParams)
+		assert.For(t).ThatActualString(rpcError.Error()).Equals("JSON-RPC error -32602: division by zero")
+		data := map[string]string{}
+		assert.For(t).ThatActual(rpcError.DecodeData(&data)).IsNil()
+		assert.For(t).ThatActual(data).Equals(map[string]string{"param": "divisor"})
+	}
+
+	err = client.Call("unknown", nil, nil)
+	if rpcError, ok := err.(*Error); assert.For(t).ThatActual(ok).IsTrue().Passed() {
+		assert.For(t).ThatActual(rpcError.Code).Equals(CodeMethodNotFound)
+		assert.For(t).ThatActual(rpcError.DecodeData(&struct{}{})).Equals(errNoData)
+	}
+}
+
+func TestClient_Notify(t *testing.T) {
+	server, requests := newServer(t)
+	defer server.Close()
+	client := newClient(server)
+
+	assert.For(t).ThatActual(client.Notify("log", []string{"hello"})).IsNil()
+	assert.For(t).ThatActualString(<-requests).Equals(`{"jsonrpc":"2.0","method":"log","params":["hello"]}`)
+
+	batch := client.NewBatch()
+	batch.Notify("log", []string{"hello"})
+	batch.Notify("log", nil)
+	assert.For(t).ThatActual(batch.Send()).IsNil()
+	assert.For(t).ThatActualString(<-requests).Equals(
+		`[{"jsonrpc":"2.0","method":"log","params":["hello"]},{"jsonrpc":"2.0","method":"log"}]`)
+}
+
+func TestBatch_Send(t *testing.T) {
+	server, requests := newServer(t)
+	defer server.Close()
+	client := newClient(server)
+
+	sum, difference, quotient := 0, 0, 0
+	batch := client.NewBatch()
+	add := batch.Call("add", []int{1, 2}, &sum)
+	batch.Notify("log", []string{"batched"})
+	subtract := batch.Call("subtract", map[string]int{"minuend": 3, "subtrahend": 1}, &difference)
+	divide := batch.Call("divide", []int{1, 0}, &quotient)
+	assert.For(t).ThatActual(batch.Send()).IsNil()
+	<-requests
+
+	assert.For(t).ThatActual(add.Err).IsNil()
+	assert.For(t).ThatActual(sum).Equals(3)
+	assert.For(t).ThatActual(subtract.Err).IsNil()
+	assert.For(t).ThatActual(difference).Equals(2)
+	if rpcError, ok := divide.Err.(*Error); assert.For(t).ThatActual(ok).IsTrue().Passed() {
+		assert.For(t).ThatActual(rpcError.Code).Equals(CodeInvalidParams)
+	}
+	assert.For(t).ThatActual(divide.Method).Equals("divide")
+	assert.For(t).ThatActual(client.NewBatch().Send()).Equals(errEmptyBatch)
+}
+
+func TestBatch_Send_rejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`))
+	}))
+	defer server.Close()
+
+	batch := newClient(server).NewBatch()
+	call := batch.Call("add", []int{1, 2}, nil)
+	err := batch.Send()
+	if rpcError, ok := err.(*Error); assert.For(t).ThatActual(ok).IsTrue().Passed() {
+		assert.For(t).ThatActual(rpcError.Code).Equals(CodeParseError)
+	}
+	assert.For(t).ThatActual(call.Err).IsNil()
+}
+
+func TestClient_errorObjectWithErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+		writer.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32603,"message":"internal error"},"id":null}`))
+	}))
+	defer server.Close()
+	client := newClient(server)
+
+	batch := client.NewBatch()
+	batch.Call("add", []int{1, 2}, nil)
+	for id, err := range map[string]error{"call": client.Call("add", []int{1, 2}, nil), "batch": batch.Send()} {
+		if rpcError, ok := err.(*Error); assert.For(t, id).ThatActual(ok).IsTrue().Passed() {
+			assert.For(t, id).ThatActual(rpcError.Code).Equals(CodeInternalError)
+			assert.For(t, id).ThatActualString(rpcError.Message).Equals("internal error")
+		}
+	}
+}
+
+func TestClient_errorStatusWithoutErrorObject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		http.Error(writer, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := newClient(server).Call("add", []int{1, 2}, nil)
+	if httpError, ok := err.(*web.HTTPError); assert.For(t).ThatActual(ok).IsTrue().Passed() {
+		assert.For(t).ThatActual(httpError.StatusCode).Equals(http.StatusServiceUnavailable)
+	}
+}
+
+func TestBatch_Send_missingResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Write([]byte(`[{"jsonrpc":"2.0","result":3,"id":1}]`))
+	}))
+	defer server.Close()
+
+	sum := 0
+	batch := newClient(server).NewBatch()
+	first := batch.Call("add", []int{1, 2}, &sum)
+	second := batch.Call("add", []int{2, 3}, nil)
+	assert.For(t).ThatActual(batch.Send()).IsNil()
+	assert.For(t).ThatActual(first.Err).IsNil()
+	assert.For(t).ThatActual(sum).Equals(3)
+	assert.For(t).ThatActual(second.Err).Equals(errMissingResponse)
+}
+
+// newServer creates a server with add, subtract, divide, and log methods;
+// it sends the bodies of the requests it receives to the returned channel.
+func newServer(t *testing.T) (*httptest.Server, <-chan string) {
+	requests := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, httpRequest *http.Request) {
+		body, err := ioutil.ReadAll(httpRequest.Body)
+		assert.For(t).ThatActual(err).IsNil()
+		requests <- string(body)
+
+		if body[0] != '[' {
+			if response := serve(t, body); response != nil {
+				json.NewEncoder(writer).Encode(response)
+			}
+			return
+		}
+		batch := []json.RawMessage{}
+		assert.For(t).ThatActual(json.Unmarshal(body, &batch)).IsNil()
+		responses := []map[string]interface{}{}
+		for _, request := range batch {
+			if response := serve(t, request); response != nil {
+				responses = append(responses, response)
+			}
+		}
+		if len(responses) > 0 {
+			json.NewEncoder(writer).Encode(responses)
+		}
+	}))
+	return server, requests
+}
+
+func serve(t *testing.T, body []byte) map[string]interface{} {
+	request := &struct {
+		Version string          `json:"jsonrpc"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+		ID      *uint64         `json:"id"`
+	}{}
+	assert.For(t).ThatActual(json.Unmarshal(body, request)).IsNil()
+	assert.For(t).ThatActual(request.Version).Equals("2.0")
+	if request.ID == nil {
+		return nil
+	}
+
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": *request.ID}
+	operands := []int{}
+	switch request.Method {
+	case "add":
+		json.Unmarshal(request.Params, &operands)
+		response["result"] = operands[0] + operands[1]
+	case "subtract":
+		named := map[string]int{}
+		json.Unmarshal(request.Params, &named)
+		response["result"] = named["minuend"] - named["subtrahend"]
+	case "divide":
+		json.Unmarshal(request.Params, &operands)
+		response["error"] = &Error{
+			Code:    CodeInvalidParams,
+			Message: "division by zero",
+			Data:    json.RawMessage(`{"param":"divisor"}`),
+		}
+	default:
+		response["error"] = &Error{Code: CodeMethodNotFound, Message: "method not found"}
+	}
+	return response
+}
+
+func newClient(server *httptest.Server) *Client {
+	return NewClient(rest.NewJSONClient(server.Client()).WithBaseURL(server.URL), "rpc")
+}
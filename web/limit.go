@@ -0,0 +1,32 @@
+package web
+
+import "io"
+
+// LimitReadCloser returns a ReadCloser that reads from the specified one but
+// fails reads beyond the specified number of bytes with errExceeded, unless
+// the body ends exactly there; to tell an exact-length body from an overlong
+// one, it tries to read one more byte once the limit is reached.
+func LimitReadCloser(body io.ReadCloser, limit int64, errExceeded error) io.ReadCloser {
+	return &limitedReadCloser{ReadCloser: body, remaining: limit, errExceeded: errExceeded}
+}
+
+type limitedReadCloser struct {
+	io.ReadCloser
+	remaining   int64
+	errExceeded error
+}
+
+func (body *limitedReadCloser) Read(b []byte) (int, error) {
+	if body.remaining <= 0 {
+		if n, err := body.ReadCloser.Read(make([]byte, 1)); n == 0 {
+			return 0, err
+		}
+		return 0, body.errExceeded
+	}
+	if int64(len(b)) > body.remaining {
+		b = b[:body.remaining]
+	}
+	n, err := body.ReadCloser.Read(b)
+	body.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestLimitReadCloser(t *testing.T) {
+	errExceeded := errors.New("too long")
+	cases := []struct {
+		id           string
+		body         string
+		limit        int64
+		expectedBody string
+		expectedErr  error
+	}{
+		{"shorter", "ping", 5, "ping", nil},
+		{"exact length", "ping", 4, "ping", nil},
+		{"longer", "ping!", 4, "ping", errExceeded},
+		{"empty", "", 0, "", nil},
+	}
+	for _, c := range cases {
+		body, err := ioutil.ReadAll(LimitReadCloser(ioutil.NopCloser(strings.NewReader(c.body)), c.limit, errExceeded))
+		assert.For(t, c.id).ThatActualString(string(body)).Equals(c.expectedBody)
+		assert.For(t, c.id).ThatActual(err).Equals(c.expectedErr)
+	}
+}
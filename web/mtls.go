@@ -0,0 +1,382 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/validate"
+	"go.uber.org/atomic"
+)
+
+const (
+	defaultCertificateReloadInterval = time.Minute
+	defaultTLSHandshakeTimeout       = 10 * time.Second
+)
+
+// MutualTLSConfig configures a mutual TLS transport (see
+// NewMutualTLSTransport). It's configured using its With* methods,
+// which return the modified config to allow chaining.
+type MutualTLSConfig struct {
+	certificateFile     string
+	keyFile             string
+	caFile              string
+	caPool              *x509.CertPool
+	pinnedPublicKeys    map[string]bool
+	serverName          string
+	reloadInterval      time.Duration
+	tlsHandshakeTimeout time.Duration
+	minTLSVersion       uint16
+	dialer              *net.Dialer
+}
+
+// NewMutualTLSConfig creates a config that presents the client certificate
+// in the specified PEM certificate and key files to servers, and verifies
+// servers using the system's CA pool. The certificate files are checked for
+// changes every minute, and reloaded when they change (e.g., on rotation).
+// Empty file paths mean that no client certificate is presented.
+func NewMutualTLSConfig(certificateFile, keyFile string) *MutualTLSConfig {
+	return &MutualTLSConfig{
+		certificateFile:     certificateFile,
+		keyFile:             keyFile,
+		reloadInterval:      defaultCertificateReloadInterval,
+		tlsHandshakeTimeout: defaultTLSHandshakeTimeout,
+		minTLSVersion:       tls.VersionTLS12,
+		dialer:              &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+}
+
+// WithCAFile configures the PEM file of the CA certificates used to verify
+// servers, instead of the system's CA pool; the file is watched for changes
+// along with the client certificate files. Servers reached through proxies
+// can only be verified against the reloadable CAs using WithServerName.
+func (config *MutualTLSConfig) WithCAFile(caFile string) *MutualTLSConfig {
+	config.caFile = caFile
+	return config
+}
+
+// WithCAPool configures the pool of CA certificates used to verify servers,
+// instead of the system's CA pool; it cannot be combined with WithCAFile.
+func (config *MutualTLSConfig) WithCAPool(caPool *x509.CertPool) *MutualTLSConfig {
+	config.caPool = caPool
+	return config
+}
+
+// WithPinnedPublicKeys configures the public keys that servers' certificate
+// chains must include (in addition to being verified), each specified as
+// the base64-encoded SHA-256 hash of a certificate's DER-encoded
+// SubjectPublicKeyInfo (see SPKIFingerprint).
+func (config *MutualTLSConfig) WithPinnedPublicKeys(fingerprints ...string) *MutualTLSConfig {
+	config.pinnedPublicKeys = make(map[string]bool, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		config.pinnedPublicKeys[fingerprint] = true
+	}
+	return config
+}
+
+// WithServerName configures the name used to verify servers' certificates
+// (and sent via SNI) instead of the host name of the requested URLs.
+func (config *MutualTLSConfig) WithServerName(serverName string) *MutualTLSConfig {
+	config.serverName = serverName
+	return config
+}
+
+// WithReloadInterval configures how often the certificate files are checked
+// for changes; a non-positive interval disables reloading.
+func (config *MutualTLSConfig) WithReloadInterval(interval time.Duration) *MutualTLSConfig {
+	config.reloadInterval = interval
+	return config
+}
+
+// WithTLSHandshakeTimeout configures the maximum duration of TLS handshakes.
+func (config *MutualTLSConfig) WithTLSHandshakeTimeout(timeout time.Duration) *MutualTLSConfig {
+	config.tlsHandshakeTimeout = timeout
+	return config
+}
+
+// WithMinTLSVersion configures the minimum TLS version (TLS 1.2 by default).
+func (config *MutualTLSConfig) WithMinTLSVersion(version uint16) *MutualTLSConfig {
+	config.minTLSVersion = version
+	return config
+}
+
+// MutualTLSTransport is an http.Transport that presents a client certificate
+// to servers and verifies them as configured by a MutualTLSConfig; it reloads
+// its certificates when their files change, without a restart. New
+// connections use the reloaded certificates, and idle connections are closed
+// on reload. Connections made through proxies (see http.Transport.Proxy) are
+// configured alike, except that they're verified against CAs loaded from
+// a file only if a server name is configured. Close must be called to stop
+// watching the files.
+type MutualTLSTransport struct {
+	*http.Transport
+	config    *MutualTLSConfig
+	tlsConfig atomic.Value // *tls.Config
+	fileStats map[string]os.FileInfo
+	reloadMu  sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMutualTLSTransport creates a transport as configured by the specified
+// config; it fails if the certificate files cannot be loaded.
+func NewMutualTLSTransport(config *MutualTLSConfig) (*MutualTLSTransport, error) {
+	if config.caFile != "" && config.caPool != nil {
+		return nil, validate.NewValidationError(errors.New("a CA file and a CA pool cannot be combined"), "config")
+	}
+	if (config.certificateFile == "") != (config.keyFile == "") {
+		return nil, validate.NewValidationError(
+			errors.New("certificate and key files must be specified together"), "config")
+	}
+
+	transport := &MutualTLSTransport{config: config, done: make(chan struct{})}
+	transport.Transport = &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: config.dialer.DialContext,
+		DialTLS:     transport.dialTLS,
+		// used instead of DialTLS for connections made through proxies
+		TLSClientConfig: &tls.Config{
+			ServerName:            config.serverName,
+			MinVersion:            config.minTLSVersion,
+			RootCAs:               config.caPool,
+			GetClientCertificate:  transport.getClientCertificate,
+			InsecureSkipVerify:    config.caFile != "", // see newPeerCertificateVerifier
+			VerifyPeerCertificate: transport.newPeerCertificateVerifier(config.serverName),
+		},
+		TLSHandshakeTimeout:   config.tlsHandshakeTimeout,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if err := transport.Reload(); err != nil {
+		return nil, err
+	}
+	if config.reloadInterval > 0 {
+		go transport.watch()
+	}
+	return transport, nil
+}
+
+// Reload reloads the certificate files; on failure, the transport keeps
+// using the certificates it loaded last.
+func (transport *MutualTLSTransport) Reload() error {
+	transport.reloadMu.Lock()
+	defer transport.reloadMu.Unlock()
+	fileStats := transport.statFiles()
+	tlsConfig, err := transport.config.newTLSConfig()
+	if err != nil {
+		return err
+	}
+	transport.tlsConfig.Store(tlsConfig)
+	transport.fileStats = fileStats
+	transport.CloseIdleConnections()
+	return nil
+}
+
+// GetTLSConfig returns a copy of the TLS config that the transport uses
+// for new connections (server names are set per connection).
+func (transport *MutualTLSTransport) GetTLSConfig() *tls.Config {
+	return transport.tlsConfig.Load().(*tls.Config).Clone()
+}
+
+// Close stops watching the certificate files and closes idle connections;
+// it's safe to call it more than once.
+func (transport *MutualTLSTransport) Close() error {
+	transport.closeOnce.Do(func() { close(transport.done) })
+	transport.CloseIdleConnections()
+	return nil
+}
+
+func (transport *MutualTLSTransport) watch() {
+	ticker := time.NewTicker(transport.config.reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-transport.done:
+			return
+		case <-ticker.C:
+			if !transport.filesChanged() {
+				continue
+			}
+			if err := transport.Reload(); err != nil {
+				gooseberry.Logger.Error("Error reloading TLS certificates", "err", err)
+			} else {
+				gooseberry.Logger.Info("Reloaded TLS certificates",
+					"certificateFile", transport.config.certificateFile, "caFile", transport.config.caFile)
+			}
+		}
+	}
+}
+
+func (transport *MutualTLSTransport) filesChanged() bool {
+	transport.reloadMu.Lock()
+	defer transport.reloadMu.Unlock()
+	for path, stat := range transport.statFiles() {
+		previous := transport.fileStats[path]
+		if previous == nil || stat == nil {
+			if previous != stat {
+				return true
+			}
+			continue
+		}
+		if !stat.ModTime().Equal(previous.ModTime()) || stat.Size() != previous.Size() {
+			return true
+		}
+	}
+	return false
+}
+
+func (transport *MutualTLSTransport) statFiles() map[string]os.FileInfo {
+	fileStats := map[string]os.FileInfo{}
+	for _, path := range []string{transport.config.certificateFile, transport.config.keyFile, transport.config.caFile} {
+		if path != "" {
+			fileStats[path], _ = os.Stat(path) // follows symbolic links, which are commonly swapped on rotation
+		}
+	}
+	return fileStats
+}
+
+// getClientCertificate presents the loaded client certificate, if any.
+func (transport *MutualTLSTransport) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	tlsConfig := transport.tlsConfig.Load().(*tls.Config)
+	if len(tlsConfig.Certificates) == 0 {
+		return &tls.Certificate{}, nil // no certificate is sent
+	}
+	return &tlsConfig.Certificates[0], nil
+}
+
+// dialTLS dials direct connections using the loaded certificates, verifying
+// servers using the configured server name or else the dialed host.
+func (transport *MutualTLSTransport) dialTLS(network, address string) (net.Conn, error) {
+	tlsConfig := transport.tlsConfig.Load().(*tls.Config).Clone()
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ServerName = host
+	}
+	tlsConfig.InsecureSkipVerify = transport.config.caFile != "" // see newPeerCertificateVerifier
+	tlsConfig.VerifyPeerCertificate = transport.newPeerCertificateVerifier(tlsConfig.ServerName)
+
+	connection, err := transport.config.dialer.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConnection := tls.Client(connection, tlsConfig)
+	if timeout := transport.config.tlsHandshakeTimeout; timeout > 0 {
+		tlsConnection.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := tlsConnection.Handshake(); err != nil {
+		connection.Close()
+		return nil, err
+	}
+	tlsConnection.SetDeadline(time.Time{})
+	return tlsConnection, nil
+}
+
+// newPeerCertificateVerifier creates a verification of servers' certificates
+// that runs after the standard verification, if any. CAs loaded from a file
+// are reloadable, so the standard verification is skipped, and servers are
+// verified against the loaded CAs using the specified server name instead.
+// The pinned public keys, if any, are checked, too.
+func (transport *MutualTLSTransport) newPeerCertificateVerifier(
+	serverName string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCertificates [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if transport.config.caFile != "" {
+			var err error
+			if verifiedChains, err = transport.verifyAgainstLoadedCAs(rawCertificates, serverName); err != nil {
+				return err
+			}
+		}
+		if len(transport.config.pinnedPublicKeys) > 0 {
+			return transport.config.verifyPinnedPublicKeys(nil, verifiedChains)
+		}
+		return nil
+	}
+}
+
+func (transport *MutualTLSTransport) verifyAgainstLoadedCAs(
+	rawCertificates [][]byte, serverName string) ([][]*x509.Certificate, error) {
+	if serverName == "" {
+		return nil, errors.New("servers reached through proxies cannot be verified using a CA file without a server name")
+	}
+	if len(rawCertificates) == 0 {
+		return nil, errors.New("server presented no certificates")
+	}
+	options := x509.VerifyOptions{
+		Roots:         transport.tlsConfig.Load().(*tls.Config).RootCAs,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	var leaf *x509.Certificate
+	for i, rawCertificate := range rawCertificates {
+		certificate, err := x509.ParseCertificate(rawCertificate)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			leaf = certificate
+		} else {
+			options.Intermediates.AddCert(certificate)
+		}
+	}
+	return leaf.Verify(options)
+}
+
+func (config *MutualTLSConfig) newTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		RootCAs:    config.caPool,
+		ServerName: config.serverName,
+		MinVersion: config.minTLSVersion,
+	}
+	if config.certificateFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.certificateFile, config.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if config.caFile != "" {
+		pem, err := ioutil.ReadFile(config.caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no CA certificates found in " + config.caFile)
+		}
+	}
+	if len(config.pinnedPublicKeys) > 0 {
+		tlsConfig.VerifyPeerCertificate = config.verifyPinnedPublicKeys
+	}
+	return tlsConfig, nil
+}
+
+// verifyPinnedPublicKeys runs after the standard verification of the chains.
+func (config *MutualTLSConfig) verifyPinnedPublicKeys(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		for _, certificate := range chain {
+			if config.pinnedPublicKeys[SPKIFingerprint(certificate)] {
+				return nil
+			}
+		}
+	}
+	return errors.New("no pinned public key found in the server's certificate chain")
+}
+
+// SPKIFingerprint returns the base64-encoded SHA-256 hash of the specified
+// certificate's DER-encoded SubjectPublicKeyInfo, as used for pinning.
+func SPKIFingerprint(certificate *x509.Certificate) string {
+	hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,355 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/tester/assert"
+)
+
+func TestMutualTLSTransport(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.directory)
+	server := pki.newServer()
+	defer server.Close()
+
+	for _, serverName := range []string{testServerName, ""} { // the latter verifies the dialed IP address
+		pki.writeClientCertificate("client-1")
+		transport, err := NewMutualTLSTransport(NewMutualTLSConfig(pki.certificateFile, pki.keyFile).
+			WithCAFile(pki.caFile).WithServerName(serverName).WithReloadInterval(0))
+		if !assert.For(t, serverName).ThatActual(err).IsNil().Passed() {
+			continue
+		}
+		assert.For(t, serverName).ThatActualString(getClientName(t, transport, server.URL)).Equals("client-1")
+
+		pki.writeClientCertificate("client-2")
+		assert.For(t, serverName).ThatActual(transport.Reload()).IsNil()
+		assert.For(t, serverName).ThatActualString(getClientName(t, transport, server.URL)).Equals("client-2")
+		transport.Close()
+	}
+}
+
+func TestMutualTLSTransport_watchesFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.directory)
+	server := pki.newServer()
+	defer server.Close()
+
+	transport, err := NewMutualTLSTransport(NewMutualTLSConfig(pki.certificateFile, pki.keyFile).
+		WithCAPool(pki.caPool).WithReloadInterval(5 * time.Millisecond))
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+	defer transport.Close()
+	assert.For(t).ThatActualString(getClientName(t, transport, server.URL)).Equals("client-1")
+
+	pki.writeClientCertificate("client-2")
+	future := time.Now().Add(time.Hour) // guarantees a different modification time
+	os.Chtimes(pki.certificateFile, future, future)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && getCertificateName(transport.GetTLSConfig()) != "client-2" {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.For(t).ThatActualString(getClientName(t, transport, server.URL)).Equals("client-2")
+	assert.For(t).ThatActual(transport.Close()).IsNil()
+	assert.For(t).ThatActual(transport.Close()).IsNil()
+}
+
+func TestMutualTLSTransport_pinning(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.directory)
+	server := pki.newServer()
+	defer server.Close()
+
+	cases := []struct {
+		id          string
+		pin         string
+		expectError bool
+	}{
+		{"pinned CA", SPKIFingerprint(pki.caCertificate), false},
+		{"unknown pin", "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", true},
+	}
+
+	for _, c := range cases {
+		transport, err := NewMutualTLSTransport(NewMutualTLSConfig(pki.certificateFile, pki.keyFile).
+			WithCAPool(pki.caPool).WithPinnedPublicKeys(c.pin).WithReloadInterval(0))
+		if !assert.For(t, c.id).ThatActual(err).IsNil().Passed() {
+			continue
+		}
+		_, err = (&http.Client{Transport: transport}).Get(server.URL)
+		assert.For(t, c.id).ThatActual(err != nil).Equals(c.expectError)
+		transport.Close()
+	}
+}
+
+func TestMutualTLSTransport_proxy(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.directory)
+	server := pki.newServer()
+	defer server.Close()
+	var tunnels []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		tunnels = append(tunnels, request.Host)
+		serveTunnel(t, writer, request)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	cases := []struct {
+		id     string
+		config *MutualTLSConfig
+	}{
+		{"CA pool", NewMutualTLSConfig(pki.certificateFile, pki.keyFile).WithCAPool(pki.caPool)},
+		{"CA file", NewMutualTLSConfig(pki.certificateFile, pki.keyFile).WithCAFile(pki.caFile).
+			WithServerName(testServerName)},
+		{"pinned CA", NewMutualTLSConfig(pki.certificateFile, pki.keyFile).WithCAFile(pki.caFile).
+			WithServerName(testServerName).WithPinnedPublicKeys(SPKIFingerprint(pki.caCertificate))},
+	}
+	for _, c := range cases {
+		tunnels = nil
+		transport, err := NewMutualTLSTransport(c.config.WithReloadInterval(0))
+		if !assert.For(t, c.id).ThatActual(err).IsNil().Passed() {
+			continue
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		assert.For(t, c.id).ThatActualString(getClientName(t, transport, server.URL)).Equals("client-1")
+		assert.For(t, c.id).ThatActual(tunnels).Equals([]string{server.Listener.Addr().String()})
+		transport.Close()
+	}
+
+	failures := []struct {
+		id     string
+		config *MutualTLSConfig
+	}{
+		{"untrusted server", NewMutualTLSConfig(pki.certificateFile, pki.keyFile)},
+		{"wrong server name", NewMutualTLSConfig(pki.certificateFile, pki.keyFile).WithCAFile(pki.caFile).
+			WithServerName("other.test")},
+		{"CA file without server name", NewMutualTLSConfig(pki.certificateFile, pki.keyFile).WithCAFile(pki.caFile)},
+		{"unknown pin", NewMutualTLSConfig(pki.certificateFile, pki.keyFile).WithCAPool(pki.caPool).
+			WithPinnedPublicKeys("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=")},
+	}
+	for _, c := range failures {
+		transport, err := NewMutualTLSTransport(c.config.WithReloadInterval(0))
+		if !assert.For(t, c.id).ThatActual(err).IsNil().Passed() {
+			continue
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		_, err = (&http.Client{Transport: transport}).Get(server.URL)
+		assert.For(t, c.id).ThatActual(err).IsNotNil()
+		transport.Close()
+	}
+}
+
+// serveTunnel serves a CONNECT request by tunneling the connection to
+// the requested host.
+func serveTunnel(t *testing.T, writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodConnect {
+		http.Error(writer, "expected CONNECT", http.StatusMethodNotAllowed)
+		return
+	}
+	upstream, err := net.Dial("tcp", request.Host)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+	connection, _, err := writer.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		upstream.Close()
+		return
+	}
+	go func() {
+		io.Copy(upstream, connection)
+		upstream.Close()
+	}()
+	io.Copy(connection, upstream)
+	connection.Close()
+}
+
+func TestMutualTLSTransport_failures(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.directory)
+	server := pki.newServer()
+	defer server.Close()
+
+	transport, err := NewMutualTLSTransport(NewMutualTLSConfig("", "").WithCAPool(pki.caPool).WithReloadInterval(0))
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		_, err = (&http.Client{Transport: transport}).Get(server.URL)
+		assert.For(t, "no client certificate").ThatActual(err).IsNotNil()
+		transport.Close()
+	}
+
+	cases := []struct {
+		id     string
+		config *MutualTLSConfig
+	}{
+		{"missing files", NewMutualTLSConfig(pki.directory+"/missing.pem", pki.keyFile)},
+		{"missing key", NewMutualTLSConfig(pki.certificateFile, "")},
+		{"CA file and pool", NewMutualTLSConfig("", "").WithCAFile(pki.caFile).WithCAPool(pki.caPool)},
+		{"invalid CA file", NewMutualTLSConfig("", "").WithCAFile(pki.keyFile)},
+	}
+
+	for _, c := range cases {
+		_, err := NewMutualTLSTransport(c.config)
+		assert.For(t, c.id).ThatActual(err).IsNotNil()
+	}
+
+	transport, err = NewMutualTLSTransport(NewMutualTLSConfig(pki.certificateFile, pki.keyFile).
+		WithCAFile(pki.caFile).WithServerName("other.test").WithReloadInterval(0))
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		_, err = (&http.Client{Transport: transport}).Get(server.URL)
+		assert.For(t, "wrong server name with CA file").ThatActual(err).IsNotNil()
+		transport.Close()
+	}
+
+	_, err = NewMutualTLSTransport(NewMutualTLSConfig("", "").WithCAFile(pki.caFile).WithCAPool(pki.caPool))
+	_, isValidationError := err.(*validate.ValidationError)
+	assert.For(t).ThatActual(isValidationError).IsTrue()
+}
+
+const testServerName = "server.test"
+
+type testPKI struct {
+	t               *testing.T
+	directory       string
+	caFile          string
+	certificateFile string
+	keyFile         string
+	caCertificate   *x509.Certificate
+	caKey           *ecdsa.PrivateKey
+	caPool          *x509.CertPool
+	serialNumber    int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	directory, err := ioutil.TempDir("", "mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki := &testPKI{
+		t:               t,
+		directory:       directory,
+		caFile:          filepath.Join(directory, "ca.pem"),
+		certificateFile: filepath.Join(directory, "client.pem"),
+		keyFile:         filepath.Join(directory, "client-key.pem"),
+		caPool:          x509.NewCertPool(),
+	}
+	caTemplate := pki.newTemplate("ca")
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	pki.caCertificate, pki.caKey = pki.issue(caTemplate, nil, nil)
+	pki.caPool.AddCert(pki.caCertificate)
+	pki.writePEM(pki.caFile, "CERTIFICATE", pki.caCertificate.Raw)
+	pki.writeClientCertificate("client-1")
+	return pki
+}
+
+func (pki *testPKI) newServer() *httptest.Server {
+	template := pki.newTemplate("server")
+	template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	template.DNSNames = []string{testServerName}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	certificate, key := pki.issue(template, pki.caCertificate, pki.caKey)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(request.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{certificate.Raw}, PrivateKey: key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.caPool,
+	}
+	server.Config.ErrorLog = stdlog.New(ioutil.Discard, "", 0) // rejected handshakes are expected
+	server.StartTLS()
+	return server
+}
+
+func (pki *testPKI) writeClientCertificate(name string) {
+	template := pki.newTemplate(name)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	certificate, key := pki.issue(template, pki.caCertificate, pki.caKey)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		pki.t.Fatal(err)
+	}
+	pki.writePEM(pki.keyFile, "EC PRIVATE KEY", keyDER)
+	pki.writePEM(pki.certificateFile, "CERTIFICATE", certificate.Raw)
+}
+
+func (pki *testPKI) newTemplate(name string) *x509.Certificate {
+	pki.serialNumber++
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(pki.serialNumber),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+// issue issues a certificate signed by the specified parent (self-signed
+// if the parent is nil).
+func (pki *testPKI) issue(
+	template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		pki.t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		pki.t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		pki.t.Fatal(err)
+	}
+	return certificate, key
+}
+
+func (pki *testPKI) writePEM(path, blockType string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		pki.t.Fatal(err)
+	}
+}
+
+func getClientName(t *testing.T, transport http.RoundTripper, url string) string {
+	response, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	return string(body)
+}
+
+func getCertificateName(tlsConfig *tls.Config) string {
+	certificate, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		return ""
+	}
+	return certificate.Subject.CommonName
+}
@@ -0,0 +1,413 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	strippedOutHeaderValue = "*******STRIPPED OUT*******"
+	tokenReplacement       = `token":"` + strippedOutHeaderValue + `"`
+	jsonPathSeparator      = "."
+	urlEncodedMediaType    = "application/x-www-form-urlencoded"
+)
+
+var (
+	tokenReplacer            = regexp.MustCompile(`token":".*?"`)
+	sensitiveDataHeaderKeys  = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+	sensitiveQueryParameters = []string{
+		"access_token", "api_key", "apikey", "client_secret", "password", "refresh_token", "token"}
+)
+
+// Masker masks a sensitive value before it gets logged.
+type Masker func(value string) string
+
+// MaskAll is a Masker that strips out the entire value.
+func MaskAll(string) string {
+	return strippedOutHeaderValue
+}
+
+// NewPartialMasker creates a Masker that replaces all but the last
+// revealedSuffixLength characters of a value with asterisks; for example,
+// NewPartialMasker(4) masks "+14108675310" as "********5310".
+// Values that are not longer than the revealed suffix are masked entirely.
+func NewPartialMasker(revealedSuffixLength int) Masker {
+	return func(value string) string {
+		runes := []rune(value)
+		maskedLength := len(runes) - revealedSuffixLength
+		if maskedLength <= 0 || revealedSuffixLength <= 0 {
+			return strings.Repeat("*", len(runes))
+		}
+		return strings.Repeat("*", maskedLength) + string(runes[maskedLength:])
+	}
+}
+
+// Redactor censors sensitive data in HTTP requests and responses (e.g., before
+// logging them). A Redactor is configured using its With* methods, which
+// return the modified redactor to allow chaining; configure it before use as
+// it's not safe to modify it concurrently with redaction.
+type Redactor struct {
+	deniedHeaders  map[string]Masker
+	allowedHeaders map[string]bool
+	jsonFields     map[string]Masker
+	formFields     map[string]Masker
+	queryParams    map[string]Masker
+	patterns       []*redactionPattern
+}
+
+type redactionPattern struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// NewRedactor creates a redactor that censors nothing until configured.
+func NewRedactor() *Redactor {
+	return &Redactor{
+		deniedHeaders:  map[string]Masker{},
+		allowedHeaders: map[string]bool{},
+		jsonFields:     map[string]Masker{},
+		formFields:     map[string]Masker{},
+		queryParams:    map[string]Masker{},
+	}
+}
+
+// NewDefaultRedactor creates a redactor that strips out credentials and
+// cookies from headers, credentials and tokens from query parameters (e.g.,
+// access_token and api_key), and JSON tokens from bodies. It's the redactor
+// that NewLeveledLoggerRoundTripper uses.
+func NewDefaultRedactor() *Redactor {
+	return NewRedactor().
+		WithDeniedHeaders(MaskAll, sensitiveDataHeaderKeys...).
+		WithQueryParameters(MaskAll, sensitiveQueryParameters...).
+		WithPattern(tokenReplacer, tokenReplacement)
+}
+
+// WithDeniedHeaders configures the redactor to mask the values of the
+// specified headers using the specified masker (MaskAll if nil).
+func (redactor *Redactor) WithDeniedHeaders(masker Masker, keys ...string) *Redactor {
+	for _, key := range keys {
+		redactor.deniedHeaders[http.CanonicalHeaderKey(key)] = orMaskAll(masker)
+	}
+	return redactor
+}
+
+// WithAllowedHeaders configures the redactor to strip out the values of all
+// headers except for the specified ones. Denied headers are masked even if
+// they're allowed.
+func (redactor *Redactor) WithAllowedHeaders(keys ...string) *Redactor {
+	for _, key := range keys {
+		redactor.allowedHeaders[http.CanonicalHeaderKey(key)] = true
+	}
+	return redactor
+}
+
+// WithJSONFields configures the redactor to mask the values of the specified
+// fields in JSON bodies using the specified masker (MaskAll if nil).
+// A field name (e.g., "password") matches fields at any depth; a path of
+// dot-separated field names (e.g., "user.phone") matches from the root.
+// Arrays are transparent to paths: "users.phone" matches the phone field
+// of every element in the users array. Matching is case-insensitive.
+func (redactor *Redactor) WithJSONFields(masker Masker, namesOrPaths ...string) *Redactor {
+	for _, nameOrPath := range namesOrPaths {
+		redactor.jsonFields[strings.ToLower(nameOrPath)] = orMaskAll(masker)
+	}
+	return redactor
+}
+
+// WithFormFields configures the redactor to mask the values of the specified
+// fields in URL-encoded bodies using the specified masker (MaskAll if nil).
+// Matching is case-insensitive.
+func (redactor *Redactor) WithFormFields(masker Masker, names ...string) *Redactor {
+	for _, name := range names {
+		redactor.formFields[strings.ToLower(name)] = orMaskAll(masker)
+	}
+	return redactor
+}
+
+// WithQueryParameters configures the redactor to mask the values of
+// the specified query parameters of URLs using the specified masker (MaskAll
+// if nil). Matching is case-insensitive.
+func (redactor *Redactor) WithQueryParameters(masker Masker, names ...string) *Redactor {
+	for _, name := range names {
+		redactor.queryParams[strings.ToLower(name)] = orMaskAll(masker)
+	}
+	return redactor
+}
+
+// WithPattern configures the redactor to replace matches of the specified
+// pattern in dumps with the specified replacement, which may refer to
+// submatches as regexp.Regexp.ReplaceAllString does.
+func (redactor *Redactor) WithPattern(pattern *regexp.Regexp, replacement string) *Redactor {
+	redactor.patterns = append(redactor.patterns, &redactionPattern{pattern: pattern, replacement: replacement})
+	return redactor
+}
+
+// RedactHeader returns a copy of the specified header with sensitive
+// values masked.
+func (redactor *Redactor) RedactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for key, values := range header {
+		masker, denied := redactor.deniedHeaders[http.CanonicalHeaderKey(key)]
+		if !denied && len(redactor.allowedHeaders) > 0 && !redactor.allowedHeaders[http.CanonicalHeaderKey(key)] {
+			masker, denied = MaskAll, true
+		}
+		redactedValues := make([]string, len(values))
+		for i, value := range values {
+			if denied {
+				redactedValues[i] = masker(value)
+			} else {
+				redactedValues[i] = value
+			}
+		}
+		redacted[key] = redactedValues
+	}
+	return redacted
+}
+
+// RedactURL returns a copy of the specified URL with the values of sensitive
+// query parameters masked and the password of its user info, if any, removed.
+// The order of query parameters is kept.
+func (redactor *Redactor) RedactURL(u *url.URL) *url.URL {
+	redacted := *u
+	if _, set := u.User.Password(); set {
+		redacted.User = url.User(u.User.Username())
+	}
+	if u.RawQuery == "" || len(redactor.queryParams) == 0 {
+		return &redacted
+	}
+	pairs := strings.Split(u.RawQuery, "&")
+	for i, pair := range pairs {
+		keyValue := strings.SplitN(pair, "=", 2)
+		key, err := url.QueryUnescape(keyValue[0])
+		if err != nil || len(keyValue) < 2 {
+			continue
+		}
+		if masker, found := redactor.queryParams[strings.ToLower(key)]; found {
+			value, _ := url.QueryUnescape(keyValue[1])
+			// asterisks are allowed in queries, so they're kept readable
+			pairs[i] = keyValue[0] + "=" + strings.Replace(url.QueryEscape(masker(value)), "%2A", "*", -1)
+		}
+	}
+	redacted.RawQuery = strings.Join(pairs, "&")
+	return &redacted
+}
+
+// RedactBody returns a copy of the specified body with sensitive fields
+// masked according to the specified content type; bodies of unknown content
+// types, or ones that fail to parse, are only subject to pattern redaction.
+func (redactor *Redactor) RedactBody(contentType string, body []byte) []byte {
+	return []byte(redactor.RedactString(string(redactor.redactBodyFields(contentType, body))))
+}
+
+// RedactString replaces matches of the redactor's patterns in the
+// specified string.
+func (redactor *Redactor) RedactString(s string) string {
+	for _, p := range redactor.patterns {
+		s = p.pattern.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// DumpRequest returns the redacted wire representation of the specified
+// outgoing request (see httputil.DumpRequestOut). The request's body is
+// restored so that it can still be sent.
+func (redactor *Redactor) DumpRequest(request *http.Request) (string, error) {
+	body, err := drainBody(&request.Body)
+	if err != nil {
+		return "", err
+	}
+	redactedRequest := *request
+	redactedRequest.URL = redactor.RedactURL(request.URL)
+	redactedRequest.Header = redactor.RedactHeader(request.Header)
+	if body != nil {
+		redactedBody := redactor.redactBodyFields(request.Header.Get("Content-Type"), body)
+		redactedRequest.Body = ioutil.NopCloser(bytes.NewReader(redactedBody))
+		redactedRequest.ContentLength = redactedContentLength(request.ContentLength, redactedBody)
+	}
+	dump, err := httputil.DumpRequestOut(&redactedRequest, true)
+	if err != nil {
+		return "", err
+	}
+	return redactor.RedactString(string(dump)), nil
+}
+
+// DumpResponse returns the redacted wire representation of the specified
+// response (see httputil.DumpResponse). The response's body is restored so
+// that it can still be read.
+func (redactor *Redactor) DumpResponse(response *http.Response) (string, error) {
+	body, err := drainBody(&response.Body)
+	if err != nil {
+		return "", err
+	}
+	redactedResponse := *response
+	redactedResponse.Header = redactor.RedactHeader(response.Header)
+	if body != nil {
+		redactedBody := redactor.redactBodyFields(response.Header.Get("Content-Type"), body)
+		redactedResponse.Body = ioutil.NopCloser(bytes.NewReader(redactedBody))
+		redactedResponse.ContentLength = redactedContentLength(response.ContentLength, redactedBody)
+	}
+	dump, err := httputil.DumpResponse(&redactedResponse, true)
+	if err != nil {
+		return "", err
+	}
+	return redactor.RedactString(string(dump)), nil
+}
+
+func (redactor *Redactor) redactBodyFields(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case len(body) == 0:
+		return body
+	case mediaType == urlEncodedMediaType && len(redactor.formFields) > 0:
+		return redactor.redactForm(body)
+	case strings.HasSuffix(mediaType, "json") && len(redactor.jsonFields) > 0:
+		return redactor.redactJSON(body)
+	}
+	return body
+}
+
+func (redactor *Redactor) redactForm(body []byte) []byte {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return body
+	}
+	for key, fieldValues := range values {
+		if masker, found := redactor.formFields[strings.ToLower(key)]; found {
+			for i, value := range fieldValues {
+				fieldValues[i] = masker(value)
+			}
+		}
+	}
+	return []byte(values.Encode())
+}
+
+func (redactor *Redactor) redactJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return body
+	}
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(redactor.redactJSONValue(document, "")); err != nil {
+		return body
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+}
+
+func (redactor *Redactor) redactJSONValue(value interface{}, path string) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, field := range typed {
+			fieldPath := strings.ToLower(key)
+			if path != "" {
+				fieldPath = path + jsonPathSeparator + fieldPath
+			}
+			if masker := redactor.findJSONFieldMasker(key, fieldPath); masker != nil {
+				typed[key] = maskJSONValue(field, masker)
+			} else {
+				typed[key] = redactor.redactJSONValue(field, fieldPath)
+			}
+		}
+	case []interface{}:
+		for i, element := range typed {
+			typed[i] = redactor.redactJSONValue(element, path)
+		}
+	}
+	return value
+}
+
+func (redactor *Redactor) findJSONFieldMasker(key, path string) Masker {
+	if masker, found := redactor.jsonFields[path]; found {
+		return masker
+	}
+	return redactor.jsonFields[strings.ToLower(key)]
+}
+
+func maskJSONValue(value interface{}, masker Masker) interface{} {
+	switch typed := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return masker(typed)
+	case json.Number:
+		return masker(typed.String())
+	default: // objects, arrays, and booleans are stripped out as a whole
+		return strippedOutHeaderValue
+	}
+}
+
+// drainBody reads the specified body fully and replaces it with an equivalent
+// reader, so that it can be read again.
+func drainBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	content, err := ioutil.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	if err = (*body).Close(); err != nil {
+		return nil, err
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(content))
+	return content, nil
+}
+
+// peekBody reads at most the first maxLength bytes of the specified body and
+// replaces it with an equivalent reader that replays said bytes before reading
+// the rest of the original body, so that the body can be read in full again
+// without buffering all of it. Whether the body is longer than maxLength
+// (i.e., whether the peeked bytes are truncated) is determined by reading
+// one more byte.
+func peekBody(body *io.ReadCloser, maxLength int) (prefix []byte, truncated bool, err error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, false, nil
+	}
+	original := *body
+	content, err := ioutil.ReadAll(io.LimitReader(original, int64(maxLength)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	*body = &struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(content), original), original}
+	if len(content) > maxLength {
+		return content[:maxLength], true, nil
+	}
+	return content, false, nil
+}
+
+// redactsJSONFields reports whether bodies of the specified content type
+// have JSON fields to redact, which requires complete documents.
+func (redactor *Redactor) redactsJSONFields(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasSuffix(mediaType, "json") && len(redactor.jsonFields) > 0
+}
+
+// redactedContentLength keeps unknown (-1) and unset (0) content lengths
+// as they are and adjusts known ones to match the redacted body.
+func redactedContentLength(originalContentLength int64, redactedBody []byte) int64 {
+	if originalContentLength <= 0 {
+		return originalContentLength
+	}
+	return int64(len(redactedBody))
+}
+
+func orMaskAll(masker Masker) Masker {
+	if masker == nil {
+		return MaskAll
+	}
+	return masker
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestPartialMasker(t *testing.T) {
+	cases := []struct {
+		id                   string
+		revealedSuffixLength int
+		value                string
+		expected             string
+	}{
+		{"phone number", 4, "+14108675310", "********5310"},
+		{"too short to reveal", 4, "5310", "****"},
+		{"nothing to reveal", 0, "secret", "******"},
+		{"empty", 4, "", ""},
+	}
+
+	for _, c := range cases {
+		actual := NewPartialMasker(c.revealedSuffixLength)(c.value)
+		assert.For(t, c.id).ThatActualString(actual).Equals(c.expected)
+	}
+}
+
+func TestRedactor_RedactHeader(t *testing.T) {
+	header := http.Header{
+		"Authorization": {"Basic Zm9vOmJhcg=="},
+		"X-Phone":       {"+14108675310"},
+		"Accept":        {"application/json"},
+		"X-Request-Id":  {"42"},
+	}
+
+	cases := []struct {
+		id       string
+		redactor *Redactor
+		expected http.Header
+	}{
+		{"default", NewDefaultRedactor(), http.Header{
+			"Authorization": {strippedOutHeaderValue},
+			"X-Phone":       {"+14108675310"},
+			"Accept":        {"application/json"},
+			"X-Request-Id":  {"42"},
+		}},
+		{"deny list", NewRedactor().WithDeniedHeaders(NewPartialMasker(4), "x-phone"), http.Header{
+			"Authorization": {"Basic Zm9vOmJhcg=="},
+			"X-Phone":       {"********5310"},
+			"Accept":        {"application/json"},
+			"X-Request-Id":  {"42"},
+		}},
+		{"allow list", NewDefaultRedactor().WithAllowedHeaders("Accept", "Authorization"), http.Header{
+			"Authorization": {strippedOutHeaderValue},
+			"X-Phone":       {strippedOutHeaderValue},
+			"Accept":        {"application/json"},
+			"X-Request-Id":  {strippedOutHeaderValue},
+		}},
+	}
+
+	for _, c := range cases {
+		assert.For(t, c.id).ThatActual(c.redactor.RedactHeader(header)).Equals(c.expected).ThenDiffOnFail()
+	}
+	assert.For(t).ThatActualString(header.Get("Authorization")).Equals("Basic Zm9vOmJhcg==")
+}
+
+func TestRedactor_RedactBody(t *testing.T) {
+	redactor := NewRedactor().
+		WithJSONFields(nil, "password", "user.ssn").
+		WithJSONFields(NewPartialMasker(4), "phone").
+		WithFormFields(NewPartialMasker(4), "To", "From").
+		WithPattern(regexp.MustCompile(`api_key=\w+`), "api_key=***")
+
+	cases := []struct {
+		id          string
+		contentType string
+		body        string
+		expected    string
+	}{
+		{"empty", "application/json", "", ""},
+		{"JSON field names", "application/json; charset=utf-8",
+			`{"password":"hunter2","phones":[{"phone":"+14108675310"}],"ssn":"not a path match"}`,
+			`{"password":"` + strippedOutHeaderValue + `","phones":[{"phone":"********5310"}],"ssn":"not a path match"}`},
+		{"JSON paths", "application/vnd.api+json",
+			`{"user":{"name":"<Dave>","ssn":123456789,"password":{"old":"a","new":"b"}}}`,
+			`{"user":{"name":"<Dave>","password":"` + strippedOutHeaderValue + `","ssn":"` +
+				strippedOutHeaderValue + `"}}`},
+		{"malformed JSON", "application/json", `{"password":`, `{"password":`},
+		{"form fields", "application/x-www-form-urlencoded",
+			"From=%2B15005550006&To=%2B14108675310&Url=http%3A%2F%2Fdemo",
+			"From=%2A%2A%2A%2A%2A%2A%2A%2A0006&To=%2A%2A%2A%2A%2A%2A%2A%2A5310&Url=http%3A%2F%2Fdemo"},
+		{"patterns", "text/plain", "api_key=s3cr3t&password=hunter2", "api_key=***&password=hunter2"},
+	}
+
+	for _, c := range cases {
+		actual := string(redactor.RedactBody(c.contentType, []byte(c.body)))
+		assert.For(t, c.id).ThatActualString(actual).Equals(c.expected)
+	}
+}
+
+func TestRedactor_DumpRequest(t *testing.T) {
+	redactor := NewDefaultRedactor().WithJSONFields(nil, "password")
+	request, _ := http.NewRequest("POST", "http://host", strings.NewReader(`{"password":"hunter2"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer 42")
+
+	dump, err := redactor.DumpRequest(request)
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActualString(dump).Equals("POST / HTTP/1.1\r\nHost: host\r\n" +
+			"User-Agent: Go-http-client/1.1\r\nContent-Length: 41\r\n" +
+			"Authorization: " + strippedOutHeaderValue + "\r\nContent-Type: application/json\r\n" +
+			"Accept-Encoding: gzip\r\n\r\n" + `{"password":"` + strippedOutHeaderValue + `"}`)
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActualString(string(body)).Equals(`{"password":"hunter2"}`)
+	assert.For(t).ThatActualString(request.Header.Get("Authorization")).Equals("Bearer 42")
+}
+
+func TestRedactor_DumpResponse(t *testing.T) {
+	response := &http.Response{
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		StatusCode:    200,
+		Header:        http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"session=42"}},
+		ContentLength: -1,
+		Body:          ioutil.NopCloser(strings.NewReader(`{"access_token":"42"}`)),
+	}
+
+	dump, err := NewDefaultRedactor().DumpResponse(response)
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActual(strings.Contains(dump, "Set-Cookie: "+strippedOutHeaderValue)).IsTrue()
+		assert.For(t).ThatActual(strings.Contains(dump, tokenReplacement)).IsTrue()
+		assert.For(t).ThatActual(strings.Contains(dump, "42")).IsFalse()
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActualString(string(body)).Equals(`{"access_token":"42"}`)
+}
@@ -0,0 +1,13 @@
+package rest
+
+import "fmt"
+
+// ResponseTooLargeError represents a response whose body exceeds the maximum
+// response size of a client (see ConfigurableClient.WithMaxResponseSize).
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (err *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body is larger than %d bytes", err.Limit)
+}
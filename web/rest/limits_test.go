@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/tester/assert"
+)
+
+func TestClient_WithMaxResponseSize(t *testing.T) {
+	cases := []struct {
+		id          string
+		body        string
+		chunked     bool
+		expectError bool
+	}{
+		{"within limit", `{"answer":42}`, false, false},
+		{"exact limit", `{"answer":4200}`, false, false},
+		{"over limit", `{"answer":420000}`, false, true},
+		{"over limit without content length", `{"answer":420000}`, true, true},
+	}
+
+	for _, c := range cases {
+		body, chunked := c.body, c.chunked
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			if chunked {
+				writer.(http.Flusher).Flush() // forces chunked encoding
+			}
+			writer.Write([]byte(body))
+		}))
+		result := map[string]int{}
+		_, err := NewJSONClient(server.Client()).(ConfigurableClient).WithMaxResponseSize(15).Get(server.URL, nil, &result)
+		server.Close()
+		if c.expectError {
+			assert.For(t, c.id).ThatActual(err).Equals(&ResponseTooLargeError{Limit: 15})
+		} else {
+			assert.For(t, c.id).ThatActual(err).IsNil()
+			assert.For(t, c.id).ThatActual(result["answer"] > 0).IsTrue()
+		}
+	}
+}
+
+func TestClient_WithMaxErrorBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusBadGateway)
+		writer.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		maxBytes          int64
+		expectedBody      string
+		expectedTruncated bool
+	}{
+		{10, strings.Repeat("x", 10), true},
+		{100, strings.Repeat("x", 100), false},
+		{0, strings.Repeat("x", 100), false},
+	}
+
+	for _, c := range cases {
+		_, err := NewJSONClient(server.Client()).(ConfigurableClient).WithMaxErrorBodySize(c.maxBytes).Get(server.URL, nil, nil)
+		assert.For(t, c.maxBytes).ThatActual(err).Equals(&web.HTTPError{
+			StatusCode: http.StatusBadGateway,
+			Body:       c.expectedBody,
+			Truncated:  c.expectedTruncated,
+		})
+	}
+	assert.For(t).ThatActualString((&web.HTTPError{StatusCode: 502, Body: "x", Truncated: true}).Error()).
+		Equals("HTTP Status Code 502: x...(truncated)")
+}
+
+func TestClient_timeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		delay, _ := time.ParseDuration(request.URL.Query().Get("delay"))
+		time.Sleep(delay)
+		writer.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Timeout: time.Hour}
+	client := NewJSONClient(httpClient).
+		WithBaseURL(server.URL).(ConfigurableClient).
+		WithTimeout(20*time.Millisecond).
+		WithMethodTimeout(http.MethodPost, time.Second)
+
+	cases := []struct {
+		id          string
+		call        func() error
+		expectError bool
+	}{
+		{"client default", func() error {
+			_, err := client.Get("?delay=100ms", nil, nil)
+			return err
+		}, true},
+		{"method default", func() error {
+			_, err := client.Post("?delay=100ms", nil, nil)
+			return err
+		}, false},
+		{"per-request", func() error {
+			_, err := DoWithOptions(client, http.MethodGet, "?delay=100ms", nil, nil, WithRequestTimeout(time.Second))
+			return err
+		}, false},
+		{"per-request shorter", func() error {
+			_, err := DoWithOptions(client, http.MethodPost, "?delay=100ms", nil, nil,
+				WithRequestTimeout(20*time.Millisecond))
+			return err
+		}, true},
+	}
+
+	for _, c := range cases {
+		assert.For(t, c.id).ThatActual(c.call() != nil).Equals(c.expectError)
+	}
+	assert.For(t).ThatActual(httpClient.Timeout).Equals(time.Hour)
+}
@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrRequestOptionsNotSupported is returned by DoWithOptions when request
+// options are specified for a client that does not support them.
+var ErrRequestOptionsNotSupported = errors.New("client does not support request options")
+
+// RequestOption configures a single request made using DoWithOptions.
+type RequestOption func(options *requestOptions)
+
+type requestOptions struct {
+	timeout    time.Duration
+	hasTimeout bool
+	header     http.Header
+	ctx        context.Context
+}
+
+// WithRequestTimeout configures the timeout of a request, which overrides
+// the client's default ones; non-positive timeouts defer to the timeout of
+// the underlying http.Client.
+func WithRequestTimeout(timeout time.Duration) RequestOption {
+	return func(options *requestOptions) {
+		options.timeout, options.hasTimeout = timeout, true
+	}
+}
+
+// WithRequestContext configures the context of a request, whose cancellation
+// (e.g., when its deadline passes) aborts the request.
+func WithRequestContext(ctx context.Context) RequestOption {
+	return func(options *requestOptions) {
+		options.ctx = ctx
+	}
+}
+
+// WithRequestHeader configures a header of a request (e.g., If-None-Match
+// for conditional requests), which replaces any value set by the client.
+func WithRequestHeader(key, value string) RequestOption {
+	return func(options *requestOptions) {
+		if options.header == nil {
+			options.header = http.Header{}
+		}
+		options.header.Set(key, value)
+	}
+}
+
+// RequestOptionsDoer is implemented by clients that support request options,
+// like the ones that this package creates.
+type RequestOptionsDoer interface {
+	// DoWithOptions makes a REST request like Client.Do does, configured by
+	// the specified options.
+	DoWithOptions(
+		method string, url string, body interface{}, result interface{}, options ...RequestOption) (*http.Response, error)
+}
+
+// DoWithOptions makes a REST request using the specified client like Client.Do
+// does, configured by the specified options. Clients that don't implement
+// RequestOptionsDoer fail with ErrRequestOptionsNotSupported, unless no options
+// are specified.
+func DoWithOptions(client Client, method string, url string, body interface{}, result interface{},
+	options ...RequestOption) (*http.Response, error) {
+	if doer, ok := client.(RequestOptionsDoer); ok {
+		return doer.DoWithOptions(method, url, body, result, options...)
+	}
+	if len(options) > 0 {
+		return nil, ErrRequestOptionsNotSupported
+	}
+	return client.Do(method, url, body, result)
+}
+
+func newRequestOptions(options []RequestOption) *requestOptions {
+	requestOptions := &requestOptions{}
+	for _, option := range options {
+		option(requestOptions)
+	}
+	return requestOptions
+}
@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/voicera/tester/assert"
+)
+
+// plainClient hides the request options support of the client it wraps.
+type plainClient struct {
+	Client
+}
+
+func TestDoWithOptions_unsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(`{"id":42}`))
+	}))
+	defer server.Close()
+	client := &plainClient{NewJSONClient(http.DefaultClient).WithBaseURL(server.URL)}
+
+	var result map[string]interface{}
+	_, err := DoWithOptions(client, http.MethodGet, "calls", nil, &result)
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActual(result).Equals(map[string]interface{}{"id": 42.0})
+	}
+
+	_, err = DoWithOptions(client, http.MethodGet, "calls", nil, &result, WithRequestTimeout(time.Second))
+	assert.For(t).ThatActual(err).Equals(ErrRequestOptionsNotSupported)
+}
+
+func TestDoWithOptions_headers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		json.NewEncoder(writer).Encode(map[string]string{
+			"ifNoneMatch": request.Header.Get("If-None-Match"),
+			"userAgent":   request.Header.Get(userAgentHeaderKey),
+		})
+	}))
+	defer server.Close()
+
+	client := NewJSONClient(http.DefaultClient).WithBaseURL(server.URL)
+	result := map[string]string{}
+	_, err := DoWithOptions(client, http.MethodGet, "calls", nil, &result, WithRequestHeader("If-None-Match", `"v1"`))
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		assert.For(t).ThatActual(result).Equals(map[string]string{"ifNoneMatch": `"v1"`, "userAgent": "gooseberry"})
+	}
+}
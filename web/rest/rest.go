@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/voicera/gooseberry"
 	"github.com/voicera/gooseberry/web"
@@ -14,6 +15,8 @@ const (
 	contentTypeHeaderKey = "Content-Type"
 	userAgentHeaderKey   = "User-Agent"
 	userAgentHeaderValue = "gooseberry"
+
+	defaultMaxErrorBodySize = 1 << 20
 )
 
 var (
@@ -36,17 +39,22 @@ type responseDecoder interface {
 type client struct {
 	requestCreator
 	responseDecoder
-	baseURL    string
-	httpClient *http.Client
+	baseURL          string
+	httpClient       *http.Client
+	maxResponseSize  int64
+	maxErrorBodySize int64
+	timeout          time.Duration
+	methodTimeouts   map[string]time.Duration
 }
 
 // NewJSONClient creates a new REST client that uses JSON to encode requests
 // and decode responses.
 func NewJSONClient(httpClient *http.Client) Client {
 	return &client{
-		httpClient:      httpClient,
-		requestCreator:  jsonRequestCreatorInstance,
-		responseDecoder: jsonResponseDecoderInstance,
+		httpClient:       httpClient,
+		requestCreator:   jsonRequestCreatorInstance,
+		responseDecoder:  jsonResponseDecoderInstance,
+		maxErrorBodySize: defaultMaxErrorBodySize,
 	}
 }
 
@@ -55,9 +63,10 @@ func NewJSONClient(httpClient *http.Client) Client {
 // for this client's methods must be a map[string]string instance.
 func NewURLEncodedRequestJSONResponseClient(httpClient *http.Client) Client {
 	return &client{
-		httpClient:      httpClient,
-		requestCreator:  urlEncodedRequestCreatorInstance,
-		responseDecoder: jsonResponseDecoderInstance,
+		httpClient:       httpClient,
+		requestCreator:   urlEncodedRequestCreatorInstance,
+		responseDecoder:  jsonResponseDecoderInstance,
+		maxErrorBodySize: defaultMaxErrorBodySize,
 	}
 }
 
@@ -66,9 +75,10 @@ func NewURLEncodedRequestJSONResponseClient(httpClient *http.Client) Client {
 // to the caller, which has access to the response object).
 func NewJSONRequestNoopResponseClient(httpClient *http.Client) Client {
 	return &client{
-		httpClient:      httpClient,
-		requestCreator:  jsonRequestCreatorInstance,
-		responseDecoder: noopResponseDecoderInstance,
+		httpClient:       httpClient,
+		requestCreator:   jsonRequestCreatorInstance,
+		responseDecoder:  noopResponseDecoderInstance,
+		maxErrorBodySize: defaultMaxErrorBodySize,
 	}
 }
 
@@ -95,10 +105,38 @@ type Client interface {
 	// Delete makes a DELETE request.
 	Delete(url string, body interface{}, result interface{}) (*http.Response, error)
 
-	// Do makes a REST request using JSON for input and output.
+	// Do makes a REST request using JSON for input and output; see
+	// DoWithOptions for per-request options, like timeouts.
 	Do(method string, url string, body interface{}, result interface{}) (*http.Response, error)
 }
 
+// ConfigurableClient is implemented by clients whose response size limits and
+// default timeouts can be configured, like the ones that this package creates.
+type ConfigurableClient interface {
+	Client
+
+	// WithMaxResponseSize configures the maximum size in bytes of successful
+	// responses' bodies (unlimited if not positive, which is the default);
+	// reading beyond said size fails with a *ResponseTooLargeError.
+	// Returns modified self.
+	WithMaxResponseSize(maxBytes int64) ConfigurableClient
+
+	// WithMaxErrorBodySize configures the maximum size in bytes of error
+	// responses' bodies (1 MiB by default; unlimited if not positive); longer
+	// bodies are truncated, which is denoted in the returned *web.HTTPError.
+	// Returns modified self.
+	WithMaxErrorBodySize(maxBytes int64) ConfigurableClient
+
+	// WithTimeout configures the default timeout of requests, which overrides
+	// the timeout of the underlying http.Client; returns modified self.
+	WithTimeout(timeout time.Duration) ConfigurableClient
+
+	// WithMethodTimeout configures the default timeout of requests that use
+	// the specified HTTP method, which overrides the client's default timeout;
+	// returns modified self.
+	WithMethodTimeout(method string, timeout time.Duration) ConfigurableClient
+}
+
 func (c *client) WithBaseURL(baseURL string) Client {
 	if strings.HasSuffix(baseURL, "/") {
 		c.baseURL = baseURL
@@ -108,6 +146,29 @@ func (c *client) WithBaseURL(baseURL string) Client {
 	return c
 }
 
+func (c *client) WithMaxResponseSize(maxBytes int64) ConfigurableClient {
+	c.maxResponseSize = maxBytes
+	return c
+}
+
+func (c *client) WithMaxErrorBodySize(maxBytes int64) ConfigurableClient {
+	c.maxErrorBodySize = maxBytes
+	return c
+}
+
+func (c *client) WithTimeout(timeout time.Duration) ConfigurableClient {
+	c.timeout = timeout
+	return c
+}
+
+func (c *client) WithMethodTimeout(method string, timeout time.Duration) ConfigurableClient {
+	if c.methodTimeouts == nil {
+		c.methodTimeouts = map[string]time.Duration{}
+	}
+	c.methodTimeouts[method] = timeout
+	return c
+}
+
 func (c *client) Get(url string, body interface{}, result interface{}) (*http.Response, error) {
 	return c.Do(http.MethodGet, url, body, result)
 }
@@ -134,13 +195,41 @@ func (c *client) Delete(url string, body interface{}, result interface{}) (*http
 
 func (c *client) Do(
 	method string, url string, body interface{}, result interface{}) (*http.Response, error) {
+	return c.do(method, url, body, result, &requestOptions{})
+}
+
+func (c *client) DoWithOptions(
+	method string, url string, body interface{}, result interface{}, options ...RequestOption) (*http.Response, error) {
+	return c.do(method, url, body, result, newRequestOptions(options))
+}
+
+func (c *client) do(
+	method string, url string, body interface{}, result interface{}, options *requestOptions) (*http.Response, error) {
 	request, err := c.CreateRequest(method, c.resolveURL(url), body)
 	if err != nil {
 		return nil, err
 	}
+	if options.ctx != nil {
+		request = request.WithContext(options.ctx)
+	}
 	request.Header.Set(userAgentHeaderKey, userAgentHeaderValue)
+	for key, values := range options.header {
+		request.Header[key] = values
+	}
 
-	response, err := c.httpClient.Do(request)
+	timeout, found := options.timeout, options.hasTimeout
+	if !found {
+		if timeout, found = c.methodTimeouts[method]; !found {
+			timeout = c.timeout
+		}
+	}
+	httpClient := c.httpClient
+	if timeout > 0 {
+		clientWithTimeout := *c.httpClient
+		clientWithTimeout.Timeout = timeout
+		httpClient = &clientWithTimeout
+	}
+	response, err := httpClient.Do(request)
 	if err != nil {
 		return response, err
 	}
@@ -152,14 +241,16 @@ func (c *client) Do(
 	}()
 
 	if response.StatusCode/100 != 2 { // if not 2xx Success; must be handled here
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return response, err
-		}
 		// TODO (Geish): handle other status codes
-		return nil, &web.HTTPError{StatusCode: response.StatusCode, Body: string(body)}
+		return c.readHTTPError(response)
 	}
 
+	if c.maxResponseSize > 0 {
+		if response.ContentLength > c.maxResponseSize {
+			return response, &ResponseTooLargeError{Limit: c.maxResponseSize}
+		}
+		response.Body = web.LimitReadCloser(response.Body, c.maxResponseSize, &ResponseTooLargeError{Limit: c.maxResponseSize})
+	}
 	if result != nil {
 		if err := c.DecodeResponse(response.Body, result); err != nil {
 			return response, err
@@ -168,6 +259,22 @@ func (c *client) Do(
 	return response, nil
 }
 
+func (c *client) readHTTPError(response *http.Response) (*http.Response, error) {
+	var reader io.Reader = response.Body
+	if c.maxErrorBodySize > 0 {
+		reader = io.LimitReader(response.Body, c.maxErrorBodySize+1) // one more byte to detect truncation
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return response, err
+	}
+	httpError := &web.HTTPError{StatusCode: response.StatusCode, Body: string(body)}
+	if c.maxErrorBodySize > 0 && int64(len(body)) > c.maxErrorBodySize {
+		httpError.Body, httpError.Truncated = string(body[:c.maxErrorBodySize]), true
+	}
+	return nil, httpError
+}
+
 func (c *client) resolveURL(path string) string {
 	// TODO (Geish): if path is an absolute URL, take it and ignore the base one
 	return c.baseURL + path
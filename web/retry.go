@@ -0,0 +1,83 @@
+package web
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+)
+
+// NewRetryingRoundTripper creates a RoundTripper that decorates another
+// round tripper by attempting requests up to maxAttempts times when they fail
+// with errors (e.g., dropped connections) or retryable status codes (429, 502,
+// 503, and 504). Attempts are apart by a delay that starts with the specified
+// one and doubles after every attempt. Requests with bodies are retried only
+// if their bodies can be replayed (see http.Request's GetBody). The context of
+// each attempt carries its number (see WithRetryAttempt).
+func NewRetryingRoundTripper(roundTripper http.RoundTripper, maxAttempts int, delay time.Duration) http.RoundTripper {
+	return NewBackoffRetryingRoundTripper(roundTripper, maxAttempts, backoff.NewExponential(delay, math.MaxInt64))
+}
+
+// NewBackoffRetryingRoundTripper creates a RoundTripper that retries like
+// the one created by NewRetryingRoundTripper, except that the delays between
+// attempts are determined by (a clone of, per request) the specified backoff.
+func NewBackoffRetryingRoundTripper(
+	roundTripper http.RoundTripper, maxAttempts int, b backoff.Backoff) http.RoundTripper {
+	return &retryingRoundTripper{innerRoundTripper: roundTripper, maxAttempts: maxAttempts, backoff: b}
+}
+
+type retryingRoundTripper struct {
+	innerRoundTripper http.RoundTripper
+	maxAttempts       int
+	backoff           backoff.Backoff
+}
+
+func (roundTripper *retryingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx, delays := request.Context(), roundTripper.backoff.Clone()
+	for attempt := 1; ; attempt++ {
+		attemptRequest := request.WithContext(WithRetryAttempt(ctx, attempt))
+		if attempt > 1 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptRequest.Body = body
+		}
+
+		response, err := roundTripper.innerRoundTripper.RoundTrip(attemptRequest)
+		if attempt >= roundTripper.maxAttempts || !isRetryable(response, err) ||
+			!canReplay(request) || ctx.Err() != nil {
+			return response, err
+		}
+		if response != nil {
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		timer := time.NewTimer(delays.Next())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func isRetryable(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func canReplay(request *http.Request) bool {
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
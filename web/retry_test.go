@@ -0,0 +1,108 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/backoff"
+	"github.com/voicera/tester/assert"
+)
+
+func TestRetryingRoundTripper(t *testing.T) {
+	errDropped := errors.New("dropped")
+	cases := []struct {
+		id               string
+		maxAttempts      int
+		statusCodes      []int // 0 denotes an error
+		expectedAttempts int
+		expectedStatus   int
+		expectedErr      error
+	}{
+		{"success", 3, []int{200}, 1, 200, nil},
+		{"not retryable", 3, []int{500, 200}, 1, 500, nil},
+		{"client error", 3, []int{404, 200}, 1, 404, nil},
+		{"error then success", 3, []int{0, 200}, 2, 200, nil},
+		{"throttled then success", 3, []int{429, 503, 200}, 3, 200, nil},
+		{"attempts exhausted", 2, []int{503, 503, 200}, 2, 503, nil},
+		{"errors exhausted", 2, []int{0, 0, 200}, 2, 0, errDropped},
+	}
+
+	for _, c := range cases {
+		attempts, bodies := []int{}, []string{}
+		roundTripper := NewRetryingRoundTripper(roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			attempts = append(attempts, RetryAttempt(request.Context()))
+			body, _ := ioutil.ReadAll(request.Body)
+			bodies = append(bodies, string(body))
+			if statusCode := c.statusCodes[len(attempts)-1]; statusCode != 0 {
+				return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+			return nil, errDropped
+		}), c.maxAttempts, time.Millisecond)
+
+		request, _ := http.NewRequest("POST", "http://host", strings.NewReader("ping!"))
+		response, err := roundTripper.RoundTrip(request)
+		assert.For(t, c.id).ThatActual(err).Equals(c.expectedErr)
+		if c.expectedStatus != 0 {
+			assert.For(t, c.id).ThatActual(response.StatusCode).Equals(c.expectedStatus)
+		}
+		assert.For(t, c.id).ThatActual(len(attempts)).Equals(c.expectedAttempts)
+		for i := range attempts {
+			assert.For(t, c.id, i).ThatActual(attempts[i]).Equals(i + 1)
+			assert.For(t, c.id, i).ThatActualString(bodies[i]).Equals("ping!")
+		}
+	}
+}
+
+func TestRetryingRoundTripper_bodyCannotBeReplayed(t *testing.T) {
+	attempts := 0
+	roundTripper := NewRetryingRoundTripper(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		attempts++
+		return nil, errors.New("dropped")
+	}), 3, time.Millisecond)
+
+	request, _ := http.NewRequest("POST", "http://host", ioutil.NopCloser(strings.NewReader("ping!")))
+	_, err := roundTripper.RoundTrip(request)
+	assert.For(t).ThatActual(err).IsNotNil()
+	assert.For(t).ThatActual(attempts).Equals(1)
+}
+
+func TestRetryingRoundTripper_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	roundTripper := NewRetryingRoundTripper(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		attempts++
+		cancel()
+		return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}), 3, time.Minute)
+
+	request, _ := http.NewRequest("GET", "http://host", nil)
+	response, err := roundTripper.RoundTrip(request.WithContext(ctx))
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(response.StatusCode).Equals(503)
+	assert.For(t).ThatActual(attempts).Equals(1)
+}
+
+func TestBackoffRetryingRoundTripper(t *testing.T) {
+	var times []time.Time
+	roundTripper := NewBackoffRetryingRoundTripper(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		times = append(times, time.Now())
+		return nil, errors.New("dropped")
+	}), 3, backoff.NewLinear(10*time.Millisecond, 10*time.Millisecond, time.Second))
+
+	for i := 0; i < 2; i++ { // to verify that every request starts with a fresh backoff
+		times = nil
+		request, _ := http.NewRequest("GET", "http://host", nil)
+		_, err := roundTripper.RoundTrip(request)
+		assert.For(t, i).ThatActual(err).IsNotNil()
+		if assert.For(t, i).ThatActual(len(times)).Equals(3).Passed() {
+			assert.For(t, i).ThatActual(times[1].Sub(times[0]) >= 10*time.Millisecond).IsTrue()
+			assert.For(t, i).ThatActual(times[2].Sub(times[1]) >= 20*time.Millisecond).IsTrue()
+			assert.For(t, i).ThatActual(times[2].Sub(times[0]) < time.Second).IsTrue()
+		}
+	}
+}
@@ -0,0 +1,23 @@
+/*
+Package server provides utilities for serving HTTP APIs: adapters that turn
+plain functions into JSON handlers, mapping of returned errors to responses
+(using RFC 7807 problem details), standard middleware (panic recovery, request
+IDs, access logging, request body size limits, and timeouts), and running
+servers with graceful shutdown.
+
+For example,
+
+	handler, err := server.NewJSONHandler(
+	    func(request *http.Request, call *Call) (*Call, error) { ... })
+	...
+	http.Handle("/calls", server.Chain(handler,
+	    server.Recover(logger), server.RequestID(), server.AccessLog(logger),
+	    server.LimitBodySize(1<<20), server.Timeout(10*time.Second)))
+	err = server.Run(ctx, &http.Server{Addr: ":8080"}, 30*time.Second)
+
+creates a handler that decodes JSON requests into Call instances and encodes
+returned calls as JSON responses, decorates it with middleware, and serves it
+until ctx is cancelled, after which in-flight requests have 30 seconds to
+complete.
+*/
+package server
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/validate"
+)
+
+const (
+	contentTypeHeaderKey = "Content-Type"
+	jsonContentType      = "application/json"
+	requestBodyArgument  = "body"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	requestType = reflect.TypeOf((*http.Request)(nil))
+)
+
+// StatusCoder can be implemented by results of JSON handlers to respond with
+// a status code other than 200 (OK); for example, 201 (Created).
+type StatusCoder interface {
+	// StatusCode returns the status code of the response.
+	StatusCode() int
+}
+
+// Validator can be implemented by requests of JSON handlers to validate them
+// after they're decoded; validation errors are written using WriteError.
+type Validator interface {
+	// Validate validates the request; it returns nil if the request is valid.
+	Validate() error
+}
+
+type jsonHandler struct {
+	function  reflect.Value
+	inputType reflect.Type
+}
+
+// NewJSONHandler creates a handler that adapts the specified function, whose
+// signature must be one of the following (where In and Out are any types):
+//
+//	func(*http.Request) (Out, error)
+//	func(*http.Request, In) (Out, error)
+//
+// The handler decodes the JSON request body (if any) into an In instance,
+// validates it if it's a Validator, calls the function, and encodes the result
+// as a JSON response. A nil result is written as 204 (No Content); errors are
+// written using WriteError.
+func NewJSONHandler(function interface{}) (http.Handler, error) {
+	value := reflect.ValueOf(function)
+	if value.Kind() != reflect.Func {
+		return nil, newInvalidFunctionError()
+	}
+	functionType := value.Type()
+	if functionType.NumIn() < 1 || functionType.NumIn() > 2 || functionType.In(0) != requestType ||
+		functionType.NumOut() != 2 || functionType.Out(1) != errorType {
+		return nil, newInvalidFunctionError()
+	}
+	handler := &jsonHandler{function: value}
+	if functionType.NumIn() == 2 {
+		handler.inputType = functionType.In(1)
+	}
+	return handler, nil
+}
+
+func (handler *jsonHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	arguments := []reflect.Value{reflect.ValueOf(request)}
+	if handler.inputType != nil {
+		input, err := handler.decode(request)
+		if err != nil {
+			WriteError(writer, request, err)
+			return
+		}
+		arguments = append(arguments, input)
+	}
+
+	outputs := handler.function.Call(arguments)
+	if err, _ := outputs[1].Interface().(error); err != nil {
+		WriteError(writer, request, err)
+		return
+	}
+	writeJSON(writer, request, outputs[0])
+}
+
+func (handler *jsonHandler) decode(request *http.Request) (reflect.Value, error) {
+	isPointer := handler.inputType.Kind() == reflect.Ptr
+	input := reflect.New(handler.inputType)
+	if isPointer {
+		input = reflect.New(handler.inputType.Elem())
+	}
+
+	if request.Body != nil {
+		if err := json.NewDecoder(request.Body).Decode(input.Interface()); err != nil && err != io.EOF {
+			if _, tooLarge := err.(*RequestBodyTooLargeError); tooLarge {
+				return input, err
+			}
+			return input, validate.NewValidationError(err, requestBodyArgument)
+		}
+	}
+
+	if validator, ok := input.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return input, err
+		}
+	}
+	if isPointer {
+		return input, nil
+	}
+	return input.Elem(), nil
+}
+
+func writeJSON(writer http.ResponseWriter, request *http.Request, result reflect.Value) {
+	if isNil(result) {
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	}
+	statusCode := http.StatusOK
+	if statusCoder, ok := result.Interface().(StatusCoder); ok {
+		statusCode = statusCoder.StatusCode()
+	}
+	writer.Header().Set(contentTypeHeaderKey, jsonContentType)
+	writer.WriteHeader(statusCode)
+	if err := json.NewEncoder(writer).Encode(result.Interface()); err != nil {
+		gooseberry.Logger.Error("Error writing response", "err", err, "url", request.URL.String())
+	}
+}
+
+func newInvalidFunctionError() error {
+	return validate.NewValidationError(errors.New("function must be a func(*http.Request[, In]) (Out, error)"), "function")
+}
+
+func isNil(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return value.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/tester/assert"
+)
+
+type call struct {
+	SID string `json:"sid"`
+	To  string `json:"to"`
+}
+
+func (c *call) Validate() error {
+	if c.To == "" {
+		return validate.NewValidationError(errors.New("to is required"), "to")
+	}
+	return nil
+}
+
+type createdCall struct {
+	*call
+}
+
+func (createdCall) StatusCode() int {
+	return http.StatusCreated
+}
+
+func TestNewJSONHandler_invalidFunction(t *testing.T) {
+	functions := []interface{}{
+		nil,
+		42,
+		func() (*call, error) { return nil, nil },
+		func(*http.Request) *call { return nil },
+		func(*http.Request, *call) (*call, string) { return nil, "" },
+		func(string, *call) (*call, error) { return nil, nil },
+	}
+
+	for i, function := range functions {
+		_, err := NewJSONHandler(function)
+		assert.For(t, i).ThatActual(err).IsNotNil()
+	}
+}
+
+func TestJSONHandler(t *testing.T) {
+	handler, err := NewJSONHandler(func(request *http.Request, c *call) (interface{}, error) {
+		switch c.To {
+		case "nobody":
+			return nil, nil
+		case "+1":
+			c.SID = "CA42"
+			return createdCall{c}, nil
+		case "fail":
+			return nil, errors.New("failed successfully")
+		}
+		return c, nil
+	})
+	if !assert.For(t).ThatActual(err).IsNil().Passed() {
+		return
+	}
+
+	cases := []struct {
+		id                 string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{"vanilla", `{"to":"+2"}`, 200, `{"sid":"","to":"+2"}` + "\n"},
+		{"status coder", `{"to":"+1"}`, 201, `{"sid":"CA42","to":"+1"}` + "\n"},
+		{"no content", `{"to":"nobody"}`, 204, ""},
+		{"malformed", `{"to":`, 400, ""},
+		{"invalid", `{}`, 400, `{"type":"about:blank","title":"Bad Request","status":400,` +
+			`"detail":"to is required","instance":"/calls","argument":"to"}` + "\n"},
+		{"internal error", `{"to":"fail"}`, 500, `{"type":"about:blank","title":"Internal Server Error",` +
+			`"status":500,"instance":"/calls"}` + "\n"},
+	}
+
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/calls", strings.NewReader(c.body)))
+		assert.For(t, c.id).ThatActual(recorder.Code).Equals(c.expectedStatusCode)
+		if c.expectedBody != "" {
+			assert.For(t, c.id).ThatActualString(recorder.Body.String()).Equals(c.expectedBody)
+		}
+	}
+}
+
+func TestJSONHandler_withoutInput(t *testing.T) {
+	handler, err := NewJSONHandler(func(request *http.Request) ([]string, error) {
+		return []string{request.URL.Query().Get("q")}, nil
+	})
+	if assert.For(t).ThatActual(err).IsNil().Passed() {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/search?q=42", nil))
+		assert.For(t).ThatActual(recorder.Code).Equals(200)
+		assert.For(t).ThatActualString(recorder.Header().Get("Content-Type")).Equals("application/json")
+		assert.For(t).ThatActualString(recorder.Body.String()).Equals(`["42"]` + "\n")
+	}
+}
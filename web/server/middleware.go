@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+	"github.com/voicera/gooseberry/web"
+)
+
+const (
+	// RequestIDHeaderKey is the header used to propagate request IDs.
+	RequestIDHeaderKey = "X-Request-Id"
+
+	requestIDLength = 16
+	timeoutMessage  = `{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"request timed out"}`
+)
+
+var errHijackingNotSupported = errors.New("response writer does not support hijacking")
+
+// Middleware decorates a handler with cross-cutting behavior.
+type Middleware func(http.Handler) http.Handler
+
+type requestIDContextKey struct{}
+
+// Chain decorates the specified handler with the specified middleware;
+// the first middleware is the outermost one (i.e., it runs first).
+func Chain(handler http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Recover creates a middleware that recovers from panics in handlers,
+// logs them (with stack traces) using the specified logger, and responds
+// with a 500 (Internal Server Error) problem.
+func Recover(logger log.LeveledLogger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					if recovered == http.ErrAbortHandler { // a sentinel that must not be recovered from
+						panic(recovered)
+					}
+					logger.Error("Recovered from panic", "panic", fmt.Sprint(recovered),
+						"url", request.URL.String(), "stack", string(debug.Stack()))
+					WriteProblem(writer, request, newProblem(http.StatusInternalServerError, ""))
+				}
+			}()
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// RequestID creates a middleware that propagates the request ID header of
+// requests, or generates a random one if absent, to the request's context
+// (see GetRequestID) and to the response's headers.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			requestID := request.Header.Get(RequestIDHeaderKey)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			writer.Header().Set(RequestIDHeaderKey, requestID)
+			ctx := context.WithValue(request.Context(), requestIDContextKey{}, requestID)
+			next.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
+
+// GetRequestID returns the request ID set by the RequestID middleware
+// in the specified context, or an empty string if there's none.
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// AccessLog creates a middleware that uses the specified logger to log one
+// line per request at the info level with the request's method, path, status
+// code, duration, response size, and ID (if any).
+func AccessLog(logger log.LeveledLogger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: writer, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, request)
+			logger.Info("HTTP request",
+				"method", request.Method,
+				"path", request.URL.Path,
+				"status", recorder.statusCode,
+				"duration", time.Since(start),
+				"responseSize", recorder.size,
+				"requestID", GetRequestID(request.Context()))
+		})
+	}
+}
+
+// LimitBodySize creates a middleware that limits the size of request bodies
+// to the specified number of bytes; reading beyond said limit fails with
+// a *RequestBodyTooLargeError.
+func LimitBodySize(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.ContentLength > maxBytes {
+				WriteError(writer, request, &RequestBodyTooLargeError{Limit: maxBytes})
+				return
+			}
+			if request.Body != nil {
+				request.Body = web.LimitReadCloser(request.Body, maxBytes, &RequestBodyTooLargeError{Limit: maxBytes})
+			}
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// Timeout creates a middleware that cancels the context of requests that
+// take longer than the specified duration and responds with
+// a 503 (Service Unavailable) problem (see http.TimeoutHandler).
+func Timeout(duration time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		timeoutHandler := http.TimeoutHandler(next, duration, timeoutMessage)
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			// expires no later than the context of the timeout handler, which derives from it
+			ctx, cancel := context.WithTimeout(request.Context(), duration)
+			defer cancel()
+			timeoutHandler.ServeHTTP(&timeoutProblemWriter{ResponseWriter: writer, ctx: ctx}, request.WithContext(ctx))
+		})
+	}
+}
+
+// timeoutProblemWriter sets the content type of the problem that
+// http.TimeoutHandler writes once requests time out.
+type timeoutProblemWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+func (writer *timeoutProblemWriter) WriteHeader(statusCode int) {
+	if statusCode == http.StatusServiceUnavailable && writer.ctx.Err() == context.DeadlineExceeded {
+		writer.Header().Set(contentTypeHeaderKey, problemContentType)
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+// statusRecorder records the status code and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	size        int
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	if !recorder.wroteHeader {
+		recorder.statusCode = statusCode
+		recorder.wroteHeader = true
+	}
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *statusRecorder) Write(b []byte) (int, error) {
+	recorder.wroteHeader = true
+	written, err := recorder.ResponseWriter.Write(b)
+	recorder.size += written
+	return written, err
+}
+
+// Flush implements http.Flusher if the recorded writer does, so that
+// streaming handlers can flush responses.
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		recorder.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the recorded writer does, so that
+// handlers can take over connections (e.g., for WebSockets).
+func (recorder *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := recorder.ResponseWriter.(http.Hijacker); ok {
+		recorder.wroteHeader = true
+		return hijacker.Hijack()
+	}
+	return nil, nil, errHijackingNotSupported
+}
+
+func newRequestID() string {
+	b := make([]byte, requestIDLength)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
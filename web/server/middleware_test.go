@@ -0,0 +1,135 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/tester/assert"
+)
+
+func TestChain(t *testing.T) {
+	order := []string{}
+	newMiddleware := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(writer, request)
+			})
+		}
+	}
+	handler := Chain(http.NotFoundHandler(), newMiddleware("outer"), newMiddleware("inner"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.For(t).ThatActual(order).Equals([]string{"outer", "inner"})
+}
+
+func TestRecover(t *testing.T) {
+	logger := testutil.NewLogCapturer(false)
+	handler := Recover(logger)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("oops") }))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.For(t).ThatActual(recorder.Code).Equals(500)
+	assert.For(t).ThatActualString(recorder.Header().Get("Content-Type")).Equals("application/problem+json")
+	if assert.For(t).ThatActual(len(logger.ErrorCaptures)).Equals(1).Passed() {
+		assert.For(t).ThatActual(logger.ErrorCaptures[0].Arguments[1]).Equals("oops")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var actual string
+	handler := RequestID()(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		actual = GetRequestID(request.Context())
+	}))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set(RequestIDHeaderKey, "42")
+	handler.ServeHTTP(recorder, request)
+	assert.For(t).ThatActualString(actual).Equals("42")
+	assert.For(t).ThatActualString(recorder.Header().Get(RequestIDHeaderKey)).Equals("42")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.For(t).ThatActual(len(actual)).Equals(2 * requestIDLength)
+	assert.For(t).ThatActualString(recorder.Header().Get(RequestIDHeaderKey)).Equals(actual)
+}
+
+func TestAccessLog(t *testing.T) {
+	logger := testutil.NewLogCapturer(false)
+	handler := Chain(http.NotFoundHandler(), RequestID(), AccessLog(logger))
+	request := httptest.NewRequest("GET", "/missing", nil)
+	request.Header.Set(RequestIDHeaderKey, "42")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	if assert.For(t).ThatActual(len(logger.InfoCaptures)).Equals(1).Passed() {
+		arguments := logger.InfoCaptures[0].Arguments
+		assert.For(t).ThatActual(arguments[:6]).Equals([]interface{}{"method", "GET", "path", "/missing", "status", 404})
+		assert.For(t).ThatActual(arguments[8:]).Equals([]interface{}{"responseSize", 19, "requestID", "42"})
+	}
+}
+
+func TestAccessLog_forwardsOptionalInterfaces(t *testing.T) {
+	var hijackErr error
+	handler := AccessLog(testutil.NewLogCapturer(false))(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			writer.(http.Flusher).Flush()
+			_, _, hijackErr = writer.(http.Hijacker).Hijack()
+		}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.For(t).ThatActual(recorder.Flushed).IsTrue()
+	assert.For(t).ThatActual(hijackErr).Equals(errHijackingNotSupported)
+}
+
+func TestLimitBodySize(t *testing.T) {
+	handler := LimitBodySize(4)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if _, err := ioutil.ReadAll(request.Body); err != nil {
+			WriteError(writer, request, err)
+		}
+	}))
+
+	cases := []struct {
+		id                 string
+		body               string
+		contentLength      int64
+		expectedStatusCode int
+	}{
+		{"under the limit", "abc", 3, 200},
+		{"at the limit", "abcd", 4, 200},
+		{"declared over the limit", "abcde", 5, 413},
+		{"streamed over the limit", "abcde", -1, 413},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+		request.ContentLength = c.contentLength
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert.For(t, c.id).ThatActual(recorder.Code).Equals(c.expectedStatusCode)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	handler := Timeout(time.Millisecond)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.For(t).ThatActual(recorder.Code).Equals(503)
+	assert.For(t).ThatActualString(recorder.Header().Get(contentTypeHeaderKey)).Equals(problemContentType)
+	assert.For(t).ThatActualString(recorder.Body.String()).Equals(timeoutMessage)
+}
+
+func TestTimeout_inTime(t *testing.T) {
+	handler := Timeout(time.Minute)(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	assert.For(t).ThatActual(recorder.Code).Equals(503)
+	assert.For(t).ThatActualString(recorder.Header().Get(contentTypeHeaderKey)).Equals("")
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/voicera/gooseberry"
+	"github.com/voicera/gooseberry/errors"
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/gooseberry/web"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypeBlank   = "about:blank"
+
+	validationErrorArgumentSuffix = "\nArgument: "
+)
+
+// Problem represents problem details for HTTP APIs as specified by RFC 7807.
+type Problem struct {
+	Type     string     `json:"type"`
+	Title    string     `json:"title"`
+	Status   int        `json:"status"`
+	Detail   string     `json:"detail,omitempty"`
+	Instance string     `json:"instance,omitempty"`
+	Argument string     `json:"argument,omitempty"`
+	Errors   []*Problem `json:"errors,omitempty"`
+}
+
+// RequestBodyTooLargeError represents an error reading a request body that
+// exceeds the limit set by the LimitBodySize middleware.
+type RequestBodyTooLargeError struct {
+	Limit int64
+}
+
+func (err *RequestBodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than %d bytes", err.Limit)
+}
+
+// WriteError writes a response that corresponds to the specified error:
+//   - *validate.ValidationError maps to a 400 (Bad Request) problem;
+//   - *RequestBodyTooLargeError maps to a 413 (Payload Too Large) problem;
+//   - *web.HTTPError is passed through as is (status code and body);
+//   - *errors.AggregateError maps to a problem that lists its errors and
+//     has the highest status code of theirs;
+//   - other errors map to a 500 (Internal Server Error) problem, whose details
+//     are logged instead of being exposed to the client.
+func WriteError(writer http.ResponseWriter, request *http.Request, err error) {
+	if httpError, ok := err.(*web.HTTPError); ok {
+		writer.WriteHeader(httpError.StatusCode)
+		if _, writeErr := writer.Write([]byte(httpError.Body)); writeErr != nil {
+			gooseberry.Logger.Error("Error writing response", "err", writeErr, "url", request.URL.String())
+		}
+		return
+	}
+	if isInternalError(err) {
+		gooseberry.Logger.Error("Internal server error", "err", err, "url", request.URL.String())
+	}
+	problem := NewProblem(err)
+	problem.Instance = request.URL.RequestURI()
+	WriteProblem(writer, request, problem)
+}
+
+// WriteProblem writes the specified problem as a JSON response.
+func WriteProblem(writer http.ResponseWriter, request *http.Request, problem *Problem) {
+	writer.Header().Set(contentTypeHeaderKey, problemContentType)
+	writer.WriteHeader(problem.Status)
+	if err := json.NewEncoder(writer).Encode(problem); err != nil {
+		gooseberry.Logger.Error("Error writing response", "err", err, "url", request.URL.String())
+	}
+}
+
+// NewProblem maps the specified error to problem details (see WriteError);
+// unlike WriteError, it doesn't log the details of internal errors.
+func NewProblem(err error) *Problem {
+	switch typed := err.(type) {
+	case *validate.ValidationError:
+		detail := strings.TrimSuffix(typed.Error(), validationErrorArgumentSuffix+typed.ArgumentName)
+		problem := newProblem(http.StatusBadRequest, detail)
+		problem.Argument = typed.ArgumentName
+		return problem
+	case *RequestBodyTooLargeError:
+		return newProblem(http.StatusRequestEntityTooLarge, typed.Error())
+	case *web.HTTPError:
+		return newProblem(typed.StatusCode, typed.Body)
+	case *errors.AggregateError:
+		problem := newProblem(0, typed.Header)
+		for _, inner := range typed.Errors {
+			innerProblem := NewProblem(inner)
+			problem.Errors = append(problem.Errors, innerProblem)
+			if innerProblem.Status > problem.Status {
+				problem.Status = innerProblem.Status
+			}
+		}
+		if problem.Status == 0 {
+			problem.Status = http.StatusInternalServerError
+		}
+		problem.Title = http.StatusText(problem.Status)
+		return problem
+	default:
+		return newProblem(http.StatusInternalServerError, "")
+	}
+}
+
+// isInternalError determines whether the specified error, or any error that
+// it aggregates, maps to a problem without details (see NewProblem).
+func isInternalError(err error) bool {
+	switch typed := err.(type) {
+	case *validate.ValidationError, *RequestBodyTooLargeError, *web.HTTPError:
+		return false
+	case *errors.AggregateError:
+		for _, inner := range typed.Errors {
+			if isInternalError(inner) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func newProblem(status int, detail string) *Problem {
+	return &Problem{Type: problemTypeBlank, Title: http.StatusText(status), Status: status, Detail: detail}
+}
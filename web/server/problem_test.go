@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/voicera/gooseberry"
+	gooseberryerrors "github.com/voicera/gooseberry/errors"
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/gooseberry/validate"
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/tester/assert"
+)
+
+func TestNewProblem(t *testing.T) {
+	cases := []struct {
+		id       string
+		err      error
+		expected *Problem
+	}{
+		{"validation error", validate.NewValidationError(errors.New("too short"), "name"),
+			&Problem{Type: "about:blank", Title: "Bad Request", Status: 400, Detail: "too short", Argument: "name"}},
+		{"body too large", &RequestBodyTooLargeError{Limit: 42},
+			&Problem{Type: "about:blank", Title: "Request Entity Too Large", Status: 413,
+				Detail: "request body is larger than 42 bytes"}},
+		{"HTTP error", &web.HTTPError{StatusCode: 409, Body: "conflict"},
+			&Problem{Type: "about:blank", Title: "Conflict", Status: 409, Detail: "conflict"}},
+		{"other error", errors.New("secret"),
+			&Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500}},
+		{"aggregate error", gooseberryerrors.NewAggregateError("invalid call",
+			validate.NewValidationError(errors.New("missing"), "to"),
+			&web.HTTPError{StatusCode: 404, Body: "no such number"}),
+			&Problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "invalid call", Errors: []*Problem{
+				{Type: "about:blank", Title: "Bad Request", Status: 400, Detail: "missing", Argument: "to"},
+				{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "no such number"},
+			}}},
+		{"empty aggregate error", gooseberryerrors.NewAggregateError("nothing"),
+			&Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Detail: "nothing"}},
+	}
+
+	originalLogger := gooseberry.Logger
+	defer func() { gooseberry.Logger = originalLogger }()
+	logger := testutil.NewLogCapturer(false)
+	gooseberry.Logger = logger
+
+	for _, c := range cases {
+		assert.For(t, c.id).ThatActual(NewProblem(c.err)).Equals(c.expected).ThenDiffOnFail()
+	}
+	assert.For(t).ThatActual(len(logger.ErrorCaptures)).Equals(0)
+}
+
+func TestWriteError_logsInternalErrorsOnce(t *testing.T) {
+	originalLogger := gooseberry.Logger
+	defer func() { gooseberry.Logger = originalLogger }()
+	logger := testutil.NewLogCapturer(false)
+	gooseberry.Logger = logger
+
+	cases := []struct {
+		id           string
+		err          error
+		expectedLogs int
+	}{
+		{"validation error", validate.NewValidationError(errors.New("missing"), "to"), 0},
+		{"other error", errors.New("secret"), 1},
+		{"aggregate error", gooseberryerrors.NewAggregateError("failed",
+			errors.New("secret"), errors.New("another secret"), &RequestBodyTooLargeError{Limit: 42}), 1},
+	}
+
+	for _, c := range cases {
+		logger.ErrorCaptures = nil
+		WriteError(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), c.err)
+		if assert.For(t, c.id).ThatActual(len(logger.ErrorCaptures)).Equals(c.expectedLogs).Passed() && c.expectedLogs > 0 {
+			assert.For(t, c.id).ThatActual(logger.ErrorCaptures[0].Arguments[:2]).Equals([]interface{}{"err", c.err})
+		}
+	}
+}
+
+func TestWriteError_HTTPErrorPassthrough(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	WriteError(recorder, httptest.NewRequest("GET", "/", nil), &web.HTTPError{StatusCode: 418, Body: "teapot"})
+	assert.For(t).ThatActual(recorder.Code).Equals(418)
+	assert.For(t).ThatActualString(recorder.Body.String()).Equals("teapot")
+}
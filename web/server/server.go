@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/voicera/gooseberry"
+)
+
+// Run runs the specified server until the specified context is done (e.g.,
+// when the process receives a termination signal), after which it shuts down
+// gracefully: it stops accepting new connections and waits for up to
+// the specified grace period for in-flight requests to complete.
+// It returns nil if the server shut down gracefully.
+func Run(ctx context.Context, server *http.Server, gracePeriod time.Duration) error {
+	serveErrors := make(chan error, 1)
+	go func() {
+		gooseberry.Logger.Info("Serving", "address", server.Addr)
+		serveErrors <- server.ListenAndServe()
+	}()
+	return awaitShutdown(ctx, server, gracePeriod, serveErrors)
+}
+
+func awaitShutdown(ctx context.Context, server *http.Server, gracePeriod time.Duration, serveErrors <-chan error) error {
+	select {
+	case err := <-serveErrors: // failed to start, or stopped by someone else
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	gooseberry.Logger.Info("Shutting down", "address", server.Addr, "gracePeriod", gracePeriod)
+	shutdownContext, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	if err := server.Shutdown(shutdownContext); err != nil {
+		gooseberry.Logger.Error("Error shutting down gracefully", "address", server.Addr, "err", err)
+		return err
+	}
+	if err := <-serveErrors; err != http.ErrServerClosed {
+		return err
+	}
+	gooseberry.Logger.Info("Shut down", "address", server.Addr)
+	return nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestRun_gracefulShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Addr: address, Handler: http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		writer.WriteHeader(http.StatusAccepted)
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErrors := make(chan error, 1)
+	go func() { runErrors <- Run(ctx, server, time.Second) }()
+
+	statusCodes := make(chan int, 1)
+	go func() {
+		for {
+			response, err := http.Get("http://" + address)
+			if err == nil {
+				response.Body.Close()
+				statusCodes <- response.StatusCode
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	<-started
+	cancel()
+	time.Sleep(10 * time.Millisecond) // let the shutdown start while the request is in flight
+	close(release)
+	assert.For(t).ThatActual(<-statusCodes).Equals(http.StatusAccepted)
+	assert.For(t).ThatActual(<-runErrors).IsNil()
+}
+
+func TestRun_listenError(t *testing.T) {
+	err := Run(context.Background(), &http.Server{Addr: "invalid address"}, time.Second)
+	assert.For(t).ThatActual(err).IsNotNil()
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"go.uber.org/atomic"
+)
+
+// Toggle is a thread-safe on/off switch that's used to enable and disable
+// features (e.g., recording or logging requests) at runtime.
+type Toggle struct {
+	enabled *atomic.Bool
+}
+
+// NewToggle creates a new toggle that's initially on if enabled is true.
+func NewToggle(enabled bool) *Toggle {
+	return &Toggle{enabled: atomic.NewBool(enabled)}
+}
+
+// Enable turns the toggle on.
+func (toggle *Toggle) Enable() {
+	toggle.enabled.Store(true)
+}
+
+// Disable turns the toggle off.
+func (toggle *Toggle) Disable() {
+	toggle.enabled.Store(false)
+}
+
+// IsEnabled checks whether or not the toggle is on.
+func (toggle *Toggle) IsEnabled() bool {
+	return toggle.enabled.Load()
+}
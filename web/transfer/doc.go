@@ -0,0 +1,30 @@
+/*
+Package transfer provides resumable downloads and uploads of large contents
+(e.g., recordings) over HTTP, with progress reporting. Transfers use
+the specified http.Client, so they share the round trippers used by
+the REST clients (e.g., authentication and logging).
+
+For example,
+
+	progress := transfer.NewProgressLogger(logger, log.InfoLevel, 10*time.Second)
+	err := transfer.NewDownload(httpClient, recordingURL).
+	    WithChecksum(sha256.New, expectedSHA256).
+	    WithProgress(progress).
+	    ToFile(ctx, "recording.wav")
+
+downloads a recording to a file, resuming it using Range requests if
+the connection drops midway, verifies its checksum, and logs its progress
+every 10 seconds. Similarly,
+
+	file, err := os.Open("recording.wav")
+	...
+	err = transfer.NewUpload(httpClient, uploadURL, file, size).
+	    WithChunkSize(8 << 20).
+	    WithProgress(progress).
+	    Run(ctx)
+
+uploads a file in 8 MiB chunks, each of which is sent with a Content-Range
+header; a failed chunk is retried after asking the server how many bytes
+it has already received.
+*/
+package transfer
@@ -0,0 +1,316 @@
+package transfer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eTagHeaderKey         = "ETag"
+	ifRangeHeaderKey      = "If-Range"
+	lastModifiedHeaderKey = "Last-Modified"
+	weakETagPrefix        = "W/"
+)
+
+var (
+	errCannotRestart = errors.New("cannot resume download, and cannot restart it after writing to the writer")
+)
+
+// ChecksumMismatchError represents a downloaded content whose checksum does
+// not match the expected one.
+type ChecksumMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (err *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch: expected %s but got %s", err.Expected, err.Actual)
+}
+
+// Download represents a resumable download of the content at a URL. It's
+// configured using its With* methods, which return the modified download
+// to allow chaining.
+//
+// When a download fails midway (e.g., due to a dropped connection), it's
+// resumed with a Range request for the remaining bytes. The request includes
+// an If-Range header with the content's (strong) ETag or, in lieu of that,
+// its Last-Modified time, so that a server sends the whole content again if
+// it has changed; downloads to files restart in that case.
+type Download struct {
+	retryPolicy
+	httpClient       *http.Client
+	url              string
+	header           http.Header
+	newHash          func() hash.Hash
+	expectedChecksum string
+	progress         ProgressFunc
+}
+
+// NewDownload creates a download of the content at the specified URL using
+// the specified client; by default, failed downloads are attempted up to
+// 3 times, 1 second apart.
+func NewDownload(httpClient *http.Client, url string) *Download {
+	return &Download{
+		retryPolicy: retryPolicy{maxAttempts: defaultMaxAttempts, retryDelay: defaultRetryDelay},
+		httpClient:  httpClient,
+		url:         url,
+		header:      http.Header{},
+	}
+}
+
+// WithHeader adds the specified header to the download's requests.
+func (download *Download) WithHeader(key, value string) *Download {
+	download.header.Add(key, value)
+	return download
+}
+
+// WithChecksum configures the download to verify that the hexadecimal
+// checksum of the downloaded content, as calculated using hashes created
+// by the specified function (e.g., sha256.New), matches the expected one.
+func (download *Download) WithChecksum(newHash func() hash.Hash, expectedChecksum string) *Download {
+	download.newHash = newHash
+	download.expectedChecksum = strings.ToLower(expectedChecksum)
+	return download
+}
+
+// WithMaxAttempts configures the maximum number of attempts to download.
+func (download *Download) WithMaxAttempts(maxAttempts int) *Download {
+	download.maxAttempts = maxAttempts
+	return download
+}
+
+// WithRetryDelay configures the delay between attempts to download.
+func (download *Download) WithRetryDelay(retryDelay time.Duration) *Download {
+	download.retryDelay = retryDelay
+	return download
+}
+
+// WithProgress configures the callback to call as the download makes progress.
+func (download *Download) WithProgress(progress ProgressFunc) *Download {
+	download.progress = progress
+	return download
+}
+
+// ToWriter downloads the content into the specified writer. Since bytes
+// written to the writer cannot be taken back, the download fails if it needs
+// to restart after writing (e.g., if the content changed midway).
+func (download *Download) ToWriter(ctx context.Context, writer io.Writer) error {
+	return download.run(ctx, &writerSink{Writer: writer})
+}
+
+// ToFile downloads the content into the file at the specified path, which is
+// created or truncated.
+func (download *Download) ToFile(ctx context.Context, path string) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return download.run(ctx, &fileSink{file: file})
+}
+
+// downloadState represents the state of a download across attempts.
+type downloadState struct {
+	offset    int64
+	total     int64
+	validator string
+	hash      hash.Hash
+	reporter  *progressReporter
+}
+
+func (download *Download) run(ctx context.Context, sink sink) error {
+	state := &downloadState{total: -1, reporter: newProgressReporter(download.url, download.progress)}
+	if download.newHash != nil {
+		state.hash = download.newHash()
+	}
+
+	var err error
+	for attempt := 1; attempt <= download.maxAttempts; attempt++ {
+		if attempt > 1 {
+			if waitErr := download.wait(ctx); waitErr != nil {
+				return waitErr
+			}
+		}
+		var retryable bool
+		if retryable, err = download.attempt(ctx, sink, state); err == nil {
+			return download.verify(state)
+		} else if !retryable || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// attempt attempts to download the rest of the content; it returns whether or
+// not it's worth attempting again if it fails.
+func (download *Download) attempt(ctx context.Context, sink sink, state *downloadState) (bool, error) {
+	request, err := http.NewRequest(http.MethodGet, download.url, nil)
+	if err != nil {
+		return false, err
+	}
+	request = request.WithContext(ctx)
+	for key, values := range download.header {
+		request.Header[key] = values
+	}
+	if state.offset > 0 && state.validator != "" {
+		request.Header.Set(rangeHeaderKey, "bytes="+strconv.FormatInt(state.offset, 10)+"-")
+		request.Header.Set(ifRangeHeaderKey, state.validator)
+	}
+
+	response, err := download.httpClient.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusPartialContent && request.Header.Get(rangeHeaderKey) != "":
+		start, total, err := parseContentRange(response.Header.Get(contentRangeHeaderKey))
+		if err != nil {
+			return false, err
+		}
+		if start != state.offset {
+			return false, fmt.Errorf("server resumed at byte %d instead of %d", start, state.offset)
+		}
+		state.total = total
+	case response.StatusCode == http.StatusOK:
+		if state.offset > 0 { // the content changed, or the server does not support ranges
+			if err := sink.restart(); err != nil {
+				return false, err
+			}
+			state.offset = 0
+			if state.hash != nil {
+				state.hash.Reset()
+			}
+		}
+		state.total = response.ContentLength
+		state.validator = getValidator(response.Header)
+	default:
+		return isRetryableStatusCode(response.StatusCode), newHTTPError(response)
+	}
+	return download.copy(response.Body, sink, state)
+}
+
+func (download *Download) copy(body io.Reader, sink sink, state *downloadState) (bool, error) {
+	buffer := make([]byte, bufferSize)
+	for {
+		n, readErr := body.Read(buffer)
+		if n > 0 {
+			if _, err := sink.Write(buffer[:n]); err != nil {
+				return false, err
+			}
+			if state.hash != nil {
+				state.hash.Write(buffer[:n])
+			}
+			state.offset += int64(n)
+			state.reporter.add(int64(n), state.offset, state.total)
+		}
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			return true, readErr
+		}
+	}
+	if state.total >= 0 && state.offset < state.total {
+		return true, io.ErrUnexpectedEOF
+	}
+	return false, nil
+}
+
+func (download *Download) verify(state *downloadState) error {
+	if state.hash == nil {
+		return nil
+	}
+	if actual := hex.EncodeToString(state.hash.Sum(nil)); actual != download.expectedChecksum {
+		return &ChecksumMismatchError{Expected: download.expectedChecksum, Actual: actual}
+	}
+	return nil
+}
+
+// getValidator returns the validator to use in If-Range headers: the strong
+// ETag of the content if any (weak ones are not allowed), or else its
+// Last-Modified time.
+func getValidator(header http.Header) string {
+	if eTag := header.Get(eTagHeaderKey); eTag != "" && !strings.HasPrefix(eTag, weakETagPrefix) {
+		return eTag
+	}
+	return header.Get(lastModifiedHeaderKey)
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes start-end/total", where total may be "*" (returned as -1).
+func parseContentRange(contentRange string) (start int64, total int64, err error) {
+	invalid := errors.New("invalid Content-Range header: " + contentRange)
+	if !strings.HasPrefix(contentRange, "bytes ") {
+		return 0, 0, invalid
+	}
+	parts := strings.SplitN(strings.TrimPrefix(contentRange, "bytes "), "/", 2)
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(parts) != 2 || len(bounds) != 2 {
+		return 0, 0, invalid
+	}
+	if start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+		return 0, 0, invalid
+	}
+	if parts[1] == "*" {
+		return start, -1, nil
+	}
+	if total, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, invalid
+	}
+	return start, total, nil
+}
+
+// sink is where downloaded content is written.
+type sink interface {
+	io.Writer
+
+	// restart discards the content written so far.
+	restart() error
+}
+
+type writerSink struct {
+	io.Writer
+	written bool
+}
+
+func (sink *writerSink) Write(b []byte) (int, error) {
+	sink.written = true
+	return sink.Writer.Write(b)
+}
+
+func (sink *writerSink) restart() error {
+	if sink.written {
+		return errCannotRestart
+	}
+	return nil
+}
+
+type fileSink struct {
+	file *os.File
+}
+
+func (sink *fileSink) Write(b []byte) (int, error) {
+	return sink.file.Write(b)
+}
+
+func (sink *fileSink) restart() error {
+	if err := sink.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := sink.file.Seek(0, io.SeekStart)
+	return err
+}
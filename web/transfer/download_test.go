@@ -0,0 +1,185 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/web"
+	"github.com/voicera/tester/assert"
+)
+
+var (
+	content         = []byte(strings.Repeat("0123456789", 10000))
+	contentChecksum = checksum(content)
+)
+
+func TestDownload_ToWriter(t *testing.T) {
+	server, requests := newDownloadServer(t, 0, false)
+	defer server.Close()
+
+	var progresses []*Progress
+	buffer := &bytes.Buffer{}
+	err := NewDownload(server.Client(), server.URL).
+		WithHeader("Authorization", "secret").
+		WithChecksum(sha256.New, strings.ToUpper(contentChecksum)).
+		WithProgress(func(progress *Progress) { progresses = append(progresses, progress) }).
+		ToWriter(context.Background(), buffer)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(buffer.Bytes()).Equals(content)
+	assert.For(t).ThatActual(len(requests())).Equals(1)
+	assert.For(t).ThatActual(requests()[0].Header.Get("Authorization")).Equals("secret")
+
+	if assert.For(t).ThatActual(len(progresses) > 1).IsTrue().Passed() {
+		last := progresses[len(progresses)-1]
+		assert.For(t).ThatActual(last.IsComplete()).IsTrue()
+		assert.For(t).ThatActual(last.URL).Equals(server.URL)
+		assert.For(t).ThatActual(last.BytesDone).Equals(int64(len(content)))
+		assert.For(t).ThatActual(last.BytesTotal).Equals(int64(len(content)))
+		assert.For(t).ThatActual(progresses[0].IsComplete()).IsFalse()
+	}
+}
+
+func TestDownload_resumes(t *testing.T) {
+	server, requests := newDownloadServer(t, 1, false)
+	defer server.Close()
+
+	buffer := &bytes.Buffer{}
+	err := NewDownload(server.Client(), server.URL).
+		WithChecksum(sha256.New, contentChecksum).
+		WithRetryDelay(time.Millisecond).
+		ToWriter(context.Background(), buffer)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(buffer.Bytes()).Equals(content)
+	if assert.For(t).ThatActual(len(requests())).Equals(2).Passed() {
+		resumed := requests()[1]
+		assert.For(t).ThatActual(resumed.Header.Get("Range")).Equals("bytes=50000-")
+		assert.For(t).ThatActual(resumed.Header.Get("If-Range")).Equals(`"v1"`)
+	}
+}
+
+func TestDownload_restartsWhenContentChanges(t *testing.T) {
+	server, _ := newDownloadServer(t, 1, true)
+	defer server.Close()
+	download := NewDownload(server.Client(), server.URL).WithRetryDelay(time.Millisecond)
+
+	directory, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "content")
+	assert.For(t).ThatActual(download.ToFile(context.Background(), path)).IsNil()
+	downloaded, err := ioutil.ReadFile(path)
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(downloaded).Equals(content)
+
+	server, _ = newDownloadServer(t, 1, true)
+	defer server.Close()
+	err = NewDownload(server.Client(), server.URL).
+		WithRetryDelay(time.Millisecond).
+		ToWriter(context.Background(), ioutil.Discard)
+	assert.For(t).ThatActual(err).Equals(errCannotRestart)
+}
+
+func TestDownload_failures(t *testing.T) {
+	server, _ := newDownloadServer(t, 0, false)
+	defer server.Close()
+	err := NewDownload(server.Client(), server.URL).
+		WithChecksum(sha256.New, checksum([]byte("other"))).
+		ToWriter(context.Background(), ioutil.Discard)
+	assert.For(t).ThatActual(err).Equals(&ChecksumMismatchError{Expected: checksum([]byte("other")), Actual: contentChecksum})
+
+	cases := []struct {
+		statusCode       int
+		expectedRequests int
+	}{
+		{404, 1},
+		{503, 3},
+	}
+
+	for _, c := range cases {
+		requestCount := 0
+		statusCode := c.statusCode
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			requestCount++
+			writer.WriteHeader(statusCode)
+			writer.Write([]byte("nope"))
+		}))
+		err := NewDownload(server.Client(), server.URL).
+			WithMaxAttempts(3).
+			WithRetryDelay(time.Millisecond).
+			ToWriter(context.Background(), ioutil.Discard)
+		server.Close()
+		assert.For(t, c.statusCode).ThatActual(err).Equals(&web.HTTPError{StatusCode: c.statusCode, Body: "nope"})
+		assert.For(t, c.statusCode).ThatActual(requestCount).Equals(c.expectedRequests)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	cases := []struct {
+		contentRange  string
+		expectedStart int64
+		expectedTotal int64
+		expectError   bool
+	}{
+		{"bytes 100-199/200", 100, 200, false},
+		{"bytes 100-199/*", 100, -1, false},
+		{"bytes */200", 0, 0, true},
+		{"items 0-1/2", 0, 0, true},
+		{"bytes 0-1", 0, 0, true},
+	}
+
+	for _, c := range cases {
+		start, total, err := parseContentRange(c.contentRange)
+		assert.For(t, c.contentRange).ThatActual(start).Equals(c.expectedStart)
+		assert.For(t, c.contentRange).ThatActual(total).Equals(c.expectedTotal)
+		assert.For(t, c.contentRange).ThatActual(err != nil).Equals(c.expectError)
+	}
+}
+
+// newDownloadServer creates a server that serves the content with support for
+// range requests; the first failures requests fail midway, and the content
+// changes after the first request if changes is true. It returns a function
+// that returns the requests made so far.
+func newDownloadServer(t *testing.T, failures int, changes bool) (*httptest.Server, func() []*http.Request) {
+	mutex := sync.Mutex{}
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		mutex.Lock()
+		requests = append(requests, request)
+		requestCount := len(requests)
+		mutex.Unlock()
+
+		writer.Header().Set("ETag", `"v1"`)
+		if changes && requestCount > 1 {
+			writer.Header().Set("ETag", `"v2"`)
+		}
+		if requestCount <= failures {
+			writer.Header().Set("Content-Length", "100000")
+			writer.Write(content[:len(content)/2])
+			panic(http.ErrAbortHandler) // drops the connection
+		}
+		http.ServeContent(writer, request, "content", time.Time{}, bytes.NewReader(content))
+	}))
+	return server, func() []*http.Request {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]*http.Request(nil), requests...)
+	}
+}
+
+func checksum(b []byte) string {
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
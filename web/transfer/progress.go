@@ -0,0 +1,90 @@
+package transfer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+)
+
+// Progress represents the progress of a transfer.
+type Progress struct {
+	// URL is the URL of the transferred content.
+	URL string
+
+	// BytesDone is the number of bytes transferred so far.
+	BytesDone int64
+
+	// BytesTotal is the size of the transferred content, or -1 if unknown.
+	BytesTotal int64
+
+	// Rate is the average transfer rate in bytes per second.
+	Rate float64
+
+	// Elapsed is the duration of the transfer so far.
+	Elapsed time.Duration
+}
+
+// IsComplete checks whether or not all bytes have been transferred.
+func (progress *Progress) IsComplete() bool {
+	return progress.BytesTotal >= 0 && progress.BytesDone >= progress.BytesTotal
+}
+
+// ProgressFunc is called as a transfer makes progress.
+type ProgressFunc func(progress *Progress)
+
+// NewProgressLogger creates a progress callback that uses the specified logger
+// to log the progress of transfers at the specified level, at most once per
+// the specified interval (and once more on completion).
+func NewProgressLogger(logger log.LeveledLogger, level log.Level, interval time.Duration) ProgressFunc {
+	mutex := sync.Mutex{}
+	lastLogged := map[string]time.Time{}
+	return func(progress *Progress) {
+		mutex.Lock()
+		now := time.Now()
+		complete := progress.IsComplete()
+		shouldLog := complete || now.Sub(lastLogged[progress.URL]) >= interval
+		if complete {
+			delete(lastLogged, progress.URL)
+		} else if shouldLog {
+			lastLogged[progress.URL] = now
+		}
+		mutex.Unlock()
+
+		if shouldLog {
+			level.Log(logger, "Transfer progress",
+				"url", progress.URL,
+				"bytesDone", progress.BytesDone,
+				"bytesTotal", progress.BytesTotal,
+				"rate", progress.Rate,
+				"elapsed", progress.Elapsed)
+		}
+	}
+}
+
+// progressReporter calculates the progress of a transfer.
+type progressReporter struct {
+	url         string
+	report      ProgressFunc
+	start       time.Time
+	transferred int64
+}
+
+func newProgressReporter(url string, report ProgressFunc) *progressReporter {
+	return &progressReporter{url: url, report: report, start: time.Now()}
+}
+
+// add records that the specified number of bytes was transferred (in this
+// session) and reports the progress of the transfer.
+func (reporter *progressReporter) add(transferred, done, total int64) {
+	reporter.transferred += transferred
+	if reporter.report == nil {
+		return
+	}
+	elapsed := time.Since(reporter.start)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(reporter.transferred) / elapsed.Seconds()
+	}
+	reporter.report(&Progress{URL: reporter.url, BytesDone: done, BytesTotal: total, Rate: rate, Elapsed: elapsed})
+}
@@ -0,0 +1,34 @@
+package transfer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/voicera/gooseberry/log"
+	"github.com/voicera/gooseberry/testutil"
+	"github.com/voicera/tester/assert"
+)
+
+func TestNewProgressLogger(t *testing.T) {
+	logger := testutil.NewLogCapturer(false)
+	progress := NewProgressLogger(logger, log.InfoLevel, time.Hour)
+	progress(&Progress{URL: "a", BytesDone: 1, BytesTotal: 3})
+	progress(&Progress{URL: "a", BytesDone: 2, BytesTotal: 3}) // throttled
+	progress(&Progress{URL: "b", BytesDone: 1, BytesTotal: -1})
+	progress(&Progress{URL: "a", BytesDone: 3, BytesTotal: 3, Rate: 1.5, Elapsed: 2 * time.Second})
+
+	assert.For(t).ThatActual(logger.InfoCaptures).Equals([]*testutil.CapturedLogEntry{
+		{Message: "Transfer progress", Arguments: []interface{}{
+			"url", "a", "bytesDone", int64(1), "bytesTotal", int64(3), "rate", 0.0, "elapsed", time.Duration(0)}},
+		{Message: "Transfer progress", Arguments: []interface{}{
+			"url", "b", "bytesDone", int64(1), "bytesTotal", int64(-1), "rate", 0.0, "elapsed", time.Duration(0)}},
+		{Message: "Transfer progress", Arguments: []interface{}{
+			"url", "a", "bytesDone", int64(3), "bytesTotal", int64(3), "rate", 1.5, "elapsed", 2 * time.Second}},
+	}).ThenDiffOnFail()
+}
+
+func TestProgress_IsComplete(t *testing.T) {
+	assert.For(t).ThatActual((&Progress{BytesDone: 3, BytesTotal: 3}).IsComplete()).IsTrue()
+	assert.For(t).ThatActual((&Progress{BytesDone: 2, BytesTotal: 3}).IsComplete()).IsFalse()
+	assert.For(t).ThatActual((&Progress{BytesDone: 3, BytesTotal: -1}).IsComplete()).IsFalse()
+}
@@ -0,0 +1,53 @@
+package transfer
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/voicera/gooseberry/web"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultRetryDelay  = time.Second
+	bufferSize         = 32 << 10
+
+	contentRangeHeaderKey = "Content-Range"
+	rangeHeaderKey        = "Range"
+)
+
+// retryPolicy configures how many times, and how often, transfers retry.
+type retryPolicy struct {
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+// wait waits for the retry delay to pass or for the context to be done.
+func (policy *retryPolicy) wait(ctx context.Context) error {
+	timer := time.NewTimer(policy.retryDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newHTTPError reads the body of the specified response into an HTTP error.
+func newHTTPError(response *http.Response) error {
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	return &web.HTTPError{StatusCode: response.StatusCode, Body: string(body)}
+}
+
+// isRetryableStatusCode checks whether or not a request that failed with
+// the specified status code is worth retrying.
+func isRetryableStatusCode(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError ||
+		statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+}
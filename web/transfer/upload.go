@@ -0,0 +1,203 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultChunkSize = 8 << 20
+
+	// statusResumeIncomplete is the status code that resumable upload servers
+	// use to acknowledge chunks (and to report received bytes) before
+	// the upload completes.
+	statusResumeIncomplete = http.StatusPermanentRedirect
+)
+
+// Upload represents a chunked, resumable upload of content to a URL. It's
+// configured using its With* methods, which return the modified upload
+// to allow chaining.
+//
+// Content is uploaded in chunks, each in a request with a Content-Range header
+// (e.g., "bytes 0-1023/4096"). Servers acknowledge chunks with a 308 (Resume
+// Incomplete) or 2xx status code and acknowledge the last chunk with a 2xx
+// status code. When a chunk fails, the upload asks the server how many bytes
+// it has received, with an empty request whose Content-Range header is
+// "bytes */4096", and resumes from there; servers respond with a 308 status
+// code and a Range header (e.g., "bytes=0-1023"), if any bytes were received.
+type Upload struct {
+	retryPolicy
+	httpClient *http.Client
+	method     string
+	url        string
+	header     http.Header
+	content    io.ReaderAt
+	size       int64
+	chunkSize  int64
+	progress   ProgressFunc
+}
+
+// NewUpload creates an upload of the specified content (of the specified size)
+// to the specified URL using the specified client. By default, content is put
+// in chunks of 8 MiB, and each chunk is attempted up to 3 times, 1 second
+// apart.
+func NewUpload(httpClient *http.Client, url string, content io.ReaderAt, size int64) *Upload {
+	return &Upload{
+		retryPolicy: retryPolicy{maxAttempts: defaultMaxAttempts, retryDelay: defaultRetryDelay},
+		httpClient:  httpClient,
+		method:      http.MethodPut,
+		url:         url,
+		header:      http.Header{},
+		content:     content,
+		size:        size,
+		chunkSize:   defaultChunkSize,
+	}
+}
+
+// WithMethod configures the HTTP method used to upload chunks (PUT by default).
+func (upload *Upload) WithMethod(method string) *Upload {
+	upload.method = method
+	return upload
+}
+
+// WithHeader adds the specified header to the upload's requests.
+func (upload *Upload) WithHeader(key, value string) *Upload {
+	upload.header.Add(key, value)
+	return upload
+}
+
+// WithChunkSize configures the maximum size of chunks in bytes.
+func (upload *Upload) WithChunkSize(chunkSize int64) *Upload {
+	upload.chunkSize = chunkSize
+	return upload
+}
+
+// WithMaxAttempts configures the maximum number of attempts per chunk.
+func (upload *Upload) WithMaxAttempts(maxAttempts int) *Upload {
+	upload.maxAttempts = maxAttempts
+	return upload
+}
+
+// WithRetryDelay configures the delay between attempts to upload a chunk.
+func (upload *Upload) WithRetryDelay(retryDelay time.Duration) *Upload {
+	upload.retryDelay = retryDelay
+	return upload
+}
+
+// WithProgress configures the callback to call as the upload makes progress.
+func (upload *Upload) WithProgress(progress ProgressFunc) *Upload {
+	upload.progress = progress
+	return upload
+}
+
+// Run uploads the content; it returns nil once the server acknowledges that
+// it has received all of it.
+func (upload *Upload) Run(ctx context.Context) error {
+	reporter := newProgressReporter(upload.url, upload.progress)
+	offset, failedAttempts := int64(0), 0
+	for {
+		end := offset + upload.chunkSize
+		if end > upload.size {
+			end = upload.size
+		}
+		received, complete, retryable, err := upload.send(ctx, offset, end)
+		if err != nil {
+			if failedAttempts++; !retryable || failedAttempts >= upload.maxAttempts || ctx.Err() != nil {
+				return err
+			}
+			if err := upload.wait(ctx); err != nil {
+				return err
+			}
+			if received, complete, _, err = upload.send(ctx, -1, -1); err != nil {
+				continue // retries the chunk as is
+			}
+		} else if !complete && received <= offset {
+			if failedAttempts++; failedAttempts >= upload.maxAttempts {
+				return fmt.Errorf("server received no bytes beyond byte %d", offset)
+			}
+		}
+
+		if complete {
+			reporter.add(upload.size-offset, upload.size, upload.size)
+			return nil
+		}
+		if received > offset {
+			reporter.add(received-offset, received, upload.size)
+			failedAttempts = 0
+		}
+		offset = received
+	}
+}
+
+// send sends the bytes of the content in [start, end), or queries the server
+// for the number of bytes it has received if start is negative. It returns
+// the number of bytes received by the server, whether or not the upload is
+// complete, and whether or not it's worth retrying in case of failure.
+func (upload *Upload) send(
+	ctx context.Context, start, end int64) (received int64, complete bool, retryable bool, err error) {
+	var body io.Reader
+	contentRange := "bytes */" + strconv.FormatInt(upload.size, 10)
+	if start >= 0 && end > start {
+		body = io.NewSectionReader(upload.content, start, end-start)
+		contentRange = fmt.Sprintf("bytes %d-%d/%d", start, end-1, upload.size)
+	}
+	request, err := http.NewRequest(upload.method, upload.url, body)
+	if err != nil {
+		return 0, false, false, err
+	}
+	// set explicitly, as the lengths of section readers are unknown to
+	// http.NewRequest, and servers commonly reject chunked transfer encoding
+	request.ContentLength = 0
+	if body != nil {
+		request.ContentLength = end - start
+	}
+	request = request.WithContext(ctx)
+	for key, values := range upload.header {
+		request.Header[key] = values
+	}
+	request.Header.Set(contentRangeHeaderKey, contentRange)
+
+	response, err := upload.httpClient.Do(request)
+	if err != nil {
+		return 0, false, true, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode/100 == 2:
+		io.Copy(ioutil.Discard, response.Body)
+		if start < 0 || end >= upload.size {
+			return upload.size, true, false, nil
+		}
+		return end, false, false, nil
+	case response.StatusCode == statusResumeIncomplete:
+		received, err := parseReceivedRange(response.Header.Get(rangeHeaderKey))
+		return received, false, false, err
+	default:
+		return 0, false, isRetryableStatusCode(response.StatusCode), newHTTPError(response)
+	}
+}
+
+// parseReceivedRange parses a Range header of the form "bytes=0-end" that's
+// sent by servers to report the bytes they've received; an empty header means
+// that no bytes were received.
+func parseReceivedRange(receivedRange string) (int64, error) {
+	if receivedRange == "" {
+		return 0, nil
+	}
+	bounds := strings.SplitN(strings.TrimPrefix(receivedRange, "bytes="), "-", 2)
+	if len(bounds) != 2 || bounds[0] != "0" {
+		return 0, fmt.Errorf("invalid Range header: %s", receivedRange)
+	}
+	end, err := strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Range header: %s", receivedRange)
+	}
+	return end + 1, nil
+}
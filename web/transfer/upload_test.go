@@ -0,0 +1,163 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/voicera/tester/assert"
+)
+
+func TestUpload_Run(t *testing.T) {
+	for _, size := range []int{0, 100, 30000, len(content)} {
+		server := newUploadServer(t, nil)
+		var progresses []*Progress
+		err := NewUpload(server.Client(), server.URL, bytes.NewReader(content[:size]), int64(size)).
+			WithChunkSize(16<<10).
+			WithHeader("Authorization", "secret").
+			WithProgress(func(progress *Progress) { progresses = append(progresses, progress) }).
+			Run(context.Background())
+		server.Close()
+
+		assert.For(t, size).ThatActual(err).IsNil()
+		assert.For(t, size).ThatActual(server.received()).Equals(content[:size])
+		if assert.For(t, size).ThatActual(len(progresses)).Equals((size+(16<<10)-1)/(16<<10) + btoi(size == 0)).Passed() {
+			assert.For(t, size).ThatActual(progresses[len(progresses)-1].IsComplete()).IsTrue()
+		}
+	}
+}
+
+func TestUpload_resumes(t *testing.T) {
+	failures := 0
+	server := newUploadServer(t, func(start int64, chunk []byte) ([]byte, int) {
+		if start == 16<<10 && failures < 2 { // keeps none, and then half, of the chunk and fails
+			failures++
+			return chunk[:len(chunk)*(failures-1)/2], http.StatusServiceUnavailable
+		}
+		return chunk, 0
+	})
+	defer server.Close()
+
+	err := NewUpload(server.Client(), server.URL, bytes.NewReader(content), int64(len(content))).
+		WithChunkSize(16 << 10).
+		WithRetryDelay(time.Millisecond).
+		Run(context.Background())
+	assert.For(t).ThatActual(err).IsNil()
+	assert.For(t).ThatActual(failures).Equals(2)
+	assert.For(t).ThatActual(server.received()).Equals(content)
+}
+
+func TestUpload_failures(t *testing.T) {
+	cases := []struct {
+		id         string
+		statusCode int
+	}{
+		{"non-retryable", http.StatusBadRequest},
+		{"too many attempts", http.StatusServiceUnavailable},
+	}
+
+	for _, c := range cases {
+		statusCode := c.statusCode
+		server := newUploadServer(t, func(start int64, chunk []byte) ([]byte, int) {
+			return nil, statusCode
+		})
+		err := NewUpload(server.Client(), server.URL, bytes.NewReader(content), int64(len(content))).
+			WithMaxAttempts(2).
+			WithRetryDelay(time.Millisecond).
+			Run(context.Background())
+		server.Close()
+		assert.For(t, c.id).ThatActual(err).IsNotNil()
+	}
+}
+
+func TestParseReceivedRange(t *testing.T) {
+	cases := []struct {
+		receivedRange string
+		expected      int64
+		expectError   bool
+	}{
+		{"", 0, false},
+		{"bytes=0-99", 100, false},
+		{"bytes=10-99", 0, true},
+		{"bytes=0-x", 0, true},
+	}
+
+	for _, c := range cases {
+		received, err := parseReceivedRange(c.receivedRange)
+		assert.For(t, c.receivedRange).ThatActual(received).Equals(c.expected)
+		assert.For(t, c.receivedRange).ThatActual(err != nil).Equals(c.expectError)
+	}
+}
+
+type uploadServer struct {
+	*httptest.Server
+	mutex  sync.Mutex
+	buffer []byte
+}
+
+// newUploadServer creates a resumable upload server; the specified function,
+// if any, decides which part of each chunk to keep and whether to fail with
+// a status code (if not zero).
+func newUploadServer(t *testing.T, handleChunk func(start int64, chunk []byte) ([]byte, int)) *uploadServer {
+	server := &uploadServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		assert.For(t).ThatActual(request.Method).Equals("PUT")
+		chunk, err := ioutil.ReadAll(request.Body)
+		assert.For(t).ThatActual(err).IsNil()
+		assert.For(t).ThatActual(request.ContentLength).Equals(int64(len(chunk)))
+		assert.For(t).ThatActual(len(request.TransferEncoding)).Equals(0)
+
+		contentRange := strings.TrimPrefix(request.Header.Get("Content-Range"), "bytes ")
+		parts := strings.Split(contentRange, "/")
+		total, _ := strconv.Atoi(parts[1])
+		if parts[0] != "*" {
+			start, _ := strconv.ParseInt(strings.Split(parts[0], "-")[0], 10, 64)
+			assert.For(t).ThatActual(start).Equals(int64(len(server.buffer)))
+			statusCode := 0
+			if handleChunk != nil {
+				chunk, statusCode = handleChunk(start, chunk)
+			}
+			server.buffer = append(server.buffer, chunk...)
+			if statusCode != 0 {
+				writer.WriteHeader(statusCode)
+				return
+			}
+		}
+
+		if len(server.buffer) == total {
+			writer.WriteHeader(http.StatusCreated)
+			return
+		}
+		if len(server.buffer) > 0 {
+			writer.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(server.buffer)-1))
+		}
+		writer.WriteHeader(http.StatusPermanentRedirect)
+	}))
+	return server
+}
+
+func (server *uploadServer) received() []byte {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.buffer == nil {
+		return content[:0]
+	}
+	return server.buffer
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
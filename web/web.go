@@ -2,22 +2,10 @@ package web
 
 import (
 	"net/http"
-	"net/http/httputil"
-	"regexp"
 
 	"github.com/voicera/gooseberry/log"
 )
 
-const (
-	strippedOutHeaderValue = "*******STRIPPED OUT*******"
-	tokenReplacement       = `token":"` + strippedOutHeaderValue + `"`
-)
-
-var (
-	tokenReplacer           = regexp.MustCompile(`token":".*?"`)
-	sensitiveDataHeaderKeys = []string{"Authorization", "Cookie"}
-)
-
 // NewBasicAuthRoundTripper creates a RoundTripper that decorates another
 // round tripper by adding basic auth using the specified username and password.
 func NewBasicAuthRoundTripper(roundTripper http.RoundTripper, username, password string) http.RoundTripper {
@@ -56,13 +44,24 @@ func (roundTripper *customHeadersRoundTripper) RoundTrip(request *http.Request)
 // NewLeveledLoggerRoundTripper creates a RoundTripper that decorates another
 // round tripper by using the specified leveled logger to log requests
 // and responses only in the debug log level; and errors in all logging levels.
+// Sensitive data is censored using the default redactor (see
+// NewDefaultRedactor).
 func NewLeveledLoggerRoundTripper(roundTripper http.RoundTripper, logger log.LeveledLogger) http.RoundTripper {
-	return &loggingRoundTripper{innerRoundTripper: roundTripper, logger: logger}
+	return NewRedactingLeveledLoggerRoundTripper(roundTripper, logger, NewDefaultRedactor())
+}
+
+// NewRedactingLeveledLoggerRoundTripper creates a RoundTripper that logs like
+// the one created by NewLeveledLoggerRoundTripper, except that it uses
+// the specified redactor to censor sensitive data in requests and responses.
+func NewRedactingLeveledLoggerRoundTripper(
+	roundTripper http.RoundTripper, logger log.LeveledLogger, redactor *Redactor) http.RoundTripper {
+	return &loggingRoundTripper{innerRoundTripper: roundTripper, logger: logger, redactor: redactor}
 }
 
 type loggingRoundTripper struct {
 	innerRoundTripper http.RoundTripper
 	logger            log.LeveledLogger
+	redactor          *Redactor
 }
 
 func (roundTripper *loggingRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
@@ -74,20 +73,9 @@ func (roundTripper *loggingRoundTripper) RoundTrip(request *http.Request) (*http
 
 func (roundTripper *loggingRoundTripper) debugLogRequest(request *http.Request) {
 	if roundTripper.logger.IsDebugEnabled() {
-		censoredHeaders := map[string]string{}
-		for _, headerKey := range sensitiveDataHeaderKeys {
-			headerValue := request.Header.Get(headerKey)
-			if headerValue != "" {
-				request.Header.Set(headerKey, strippedOutHeaderValue)
-				censoredHeaders[headerKey] = headerValue
-			}
-		}
-		dump, err := httputil.DumpRequestOut(request, true)
-		for key, value := range censoredHeaders { // restore censored headers
-			request.Header.Set(key, value)
-		}
+		dump, err := roundTripper.redactor.DumpRequest(request)
 		if err == nil {
-			roundTripper.logger.Debug("Request", "request", string(dump))
+			roundTripper.logger.Debug("Request", "request", dump)
 		}
 	}
 }
@@ -95,21 +83,17 @@ func (roundTripper *loggingRoundTripper) debugLogRequest(request *http.Request)
 func (roundTripper *loggingRoundTripper) debugLogResponse(response *http.Response, responseError error) {
 	if responseError != nil {
 		if response != nil {
-			dump, err := httputil.DumpResponse(response, true)
+			dump, err := roundTripper.redactor.DumpResponse(response)
 			if err == nil {
-				roundTripper.logger.Error("Response error", "responseError", responseError, "response", string(dump))
+				roundTripper.logger.Error("Response error", "responseError", responseError, "response", dump)
 			}
 		} else {
 			roundTripper.logger.Error("Response error", "responseError", responseError)
 		}
 	} else if roundTripper.logger.IsDebugEnabled() {
-		dump, err := httputil.DumpResponse(response, true)
+		dump, err := roundTripper.redactor.DumpResponse(response)
 		if err == nil {
-			roundTripper.logger.Debug("Response", "response", stripOutSensitiveData(string(dump)))
+			roundTripper.logger.Debug("Response", "response", dump)
 		}
 	}
 }
-
-func stripOutSensitiveData(s string) string {
-	return tokenReplacer.ReplaceAllString(s, tokenReplacement)
-}